@@ -0,0 +1,14 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// handleServiceCommand rejects the -service flag outside Windows; the
+// systemd integration covers Linux deployments.
+func handleServiceCommand(cmd string, stop context.CancelFunc) error {
+	return fmt.Errorf("-service %s: service control is only supported on Windows", cmd)
+}
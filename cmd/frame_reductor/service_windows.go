@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// Windows service control manager integration, so the reductor can run
+// unattended on substation PCs. `-service install` registers the binary
+// (started with `-service run` plus the current flags), `-service remove`
+// deletes the registration, and `-service run` answers SCM control
+// requests while the normal pipeline runs.
+
+const serviceName = "frame_reductor"
+
+var (
+	advapi32                         = syscall.NewLazyDLL("advapi32.dll")
+	procStartServiceCtrlDispatcherW  = advapi32.NewProc("StartServiceCtrlDispatcherW")
+	procRegisterServiceCtrlHandlerEx = advapi32.NewProc("RegisterServiceCtrlHandlerExW")
+	procSetServiceStatus             = advapi32.NewProc("SetServiceStatus")
+	procOpenSCManagerW               = advapi32.NewProc("OpenSCManagerW")
+	procCreateServiceW               = advapi32.NewProc("CreateServiceW")
+	procOpenServiceW                 = advapi32.NewProc("OpenServiceW")
+	procDeleteService                = advapi32.NewProc("DeleteService")
+	procCloseServiceHandle           = advapi32.NewProc("CloseServiceHandle")
+)
+
+const (
+	serviceWin32OwnProcess = 0x10
+	serviceAutoStart       = 2
+	serviceErrorNormal     = 1
+	serviceAcceptStop      = 0x1
+	serviceAcceptShutdown  = 0x4
+	serviceControlStop     = 1
+	serviceControlShutdown = 5
+	serviceStopPending     = 3
+	serviceRunning         = 4
+	scManagerAllAccess     = 0xF003F
+	serviceAllAccess       = 0xF01FF
+)
+
+type serviceStatus struct {
+	Type             uint32
+	State            uint32
+	ControlsAccepted uint32
+	ExitCode         uint32
+	SpecificExitCode uint32
+	CheckPoint       uint32
+	WaitHint         uint32
+}
+
+var (
+	svcHandle uintptr
+	svcCancel context.CancelFunc
+)
+
+// handleServiceCommand dispatches the -service flag.
+func handleServiceCommand(cmd string, stop context.CancelFunc) error {
+	switch cmd {
+	case "install":
+		return installService()
+	case "remove":
+		return removeService()
+	case "run":
+		svcCancel = stop
+		go runServiceDispatcher()
+		return nil
+	}
+	return fmt.Errorf("unknown -service command %q (want install, remove or run)", cmd)
+}
+
+// runServiceDispatcher connects to the SCM; it blocks for the lifetime of
+// the service, so it runs alongside the pipeline.
+func runServiceDispatcher() {
+	name, _ := syscall.UTF16PtrFromString(serviceName)
+	table := []struct {
+		name *uint16
+		proc uintptr
+	}{
+		{name, syscall.NewCallback(serviceMain)},
+		{nil, 0},
+	}
+	r, _, err := procStartServiceCtrlDispatcherW.Call(uintptr(unsafe.Pointer(&table[0])))
+	if r == 0 {
+		log.Printf("service dispatcher failed (started from a console?): %v", err)
+	}
+}
+
+// serviceMain registers the control handler and reports RUNNING. The
+// process exiting after the stop-triggered shutdown completes the
+// STOPPED transition for the SCM.
+func serviceMain(argc uint32, argv uintptr) uintptr {
+	name, _ := syscall.UTF16PtrFromString(serviceName)
+	h, _, _ := procRegisterServiceCtrlHandlerEx.Call(
+		uintptr(unsafe.Pointer(name)), syscall.NewCallback(serviceControl), 0)
+	if h == 0 {
+		return 1
+	}
+	svcHandle = h
+	setServiceState(serviceRunning)
+	return 0
+}
+
+// serviceControl answers SCM control requests: stop and shutdown cancel
+// the pipeline context, everything else is acknowledged unchanged.
+func serviceControl(control, eventType uint32, eventData, context uintptr) uintptr {
+	switch control {
+	case serviceControlStop, serviceControlShutdown:
+		setServiceState(serviceStopPending)
+		if svcCancel != nil {
+			svcCancel()
+		}
+	}
+	return 0
+}
+
+func setServiceState(state uint32) {
+	st := serviceStatus{
+		Type:             serviceWin32OwnProcess,
+		State:            state,
+		ControlsAccepted: serviceAcceptStop | serviceAcceptShutdown,
+		WaitHint:         10000,
+	}
+	procSetServiceStatus.Call(svcHandle, uintptr(unsafe.Pointer(&st)))
+}
+
+// installService registers the binary with the SCM, preserving the
+// current command line and swapping -service install for -service run.
+func installService() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := `"` + exe + `" -service run`
+	for i := 1; i < len(os.Args); i++ {
+		if os.Args[i] == "-service" || os.Args[i] == "--service" {
+			i++ // skip the install argument as well
+			continue
+		}
+		cmd += ` "` + os.Args[i] + `"`
+	}
+	scm, err := openSCManager()
+	if err != nil {
+		return err
+	}
+	defer procCloseServiceHandle.Call(scm)
+	name, _ := syscall.UTF16PtrFromString(serviceName)
+	display, _ := syscall.UTF16PtrFromString("C37.118 frame reductor")
+	path, _ := syscall.UTF16PtrFromString(cmd)
+	h, _, callErr := procCreateServiceW.Call(scm,
+		uintptr(unsafe.Pointer(name)), uintptr(unsafe.Pointer(display)),
+		serviceAllAccess, serviceWin32OwnProcess, serviceAutoStart,
+		serviceErrorNormal, uintptr(unsafe.Pointer(path)), 0, 0, 0, 0, 0)
+	if h == 0 {
+		return fmt.Errorf("cannot create service: %v", callErr)
+	}
+	procCloseServiceHandle.Call(h)
+	log.Printf("installed service %s: %s", serviceName, cmd)
+	return nil
+}
+
+// removeService deletes the SCM registration.
+func removeService() error {
+	scm, err := openSCManager()
+	if err != nil {
+		return err
+	}
+	defer procCloseServiceHandle.Call(scm)
+	name, _ := syscall.UTF16PtrFromString(serviceName)
+	h, _, callErr := procOpenServiceW.Call(scm, uintptr(unsafe.Pointer(name)), serviceAllAccess)
+	if h == 0 {
+		return fmt.Errorf("cannot open service: %v", callErr)
+	}
+	defer procCloseServiceHandle.Call(h)
+	if r, _, callErr := procDeleteService.Call(h); r == 0 {
+		return fmt.Errorf("cannot delete service: %v", callErr)
+	}
+	log.Printf("removed service %s", serviceName)
+	return nil
+}
+
+func openSCManager() (uintptr, error) {
+	h, _, err := procOpenSCManagerW.Call(0, 0, scManagerAllAccess)
+	if h == 0 {
+		return 0, fmt.Errorf("cannot open service control manager: %v", err)
+	}
+	return h, nil
+}
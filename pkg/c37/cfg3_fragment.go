@@ -0,0 +1,136 @@
+package c37
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// lastFragment is the CONT_IDX value marking the final fragment of a
+// fragmented CFG-3 frame.
+const lastFragment = 0xFFFF
+
+// MaxCfg3Size bounds the reassembled CFG-3 size so a broken sender cannot
+// make the reassembler grow without limit.
+const MaxCfg3Size = 1 << 20
+
+// Cfg3Reassembler reassembles CFG-3 frames that were fragmented for the
+// transport MTU using CONT_IDX continuation semantics: the first fragment
+// carries CONT_IDX 1, following fragments count up, and the last fragment
+// carries 0xFFFF. One reassembler serves one stream.
+type Cfg3Reassembler struct {
+	header  Header
+	payload []byte
+	next    uint16
+}
+
+// Add feeds one received CFG-3 frame. When the frame is complete — either
+// unfragmented or the final fragment just arrived — the assembled frame is
+// returned with done true. Out-of-order fragments reset the reassembler
+// and return an error.
+func (r *Cfg3Reassembler) Add(data []byte) (frame []byte, done bool, err error) {
+	h, err := DecodeHeader(data)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(data) < CommonHeaderSize+4 {
+		return nil, false, fmt.Errorf("CFG-3 fragment too short: %d bytes", len(data))
+	}
+	if err := VerifyCRC(data); err != nil {
+		return nil, false, fmt.Errorf("CFG-3 fragment: %w", err)
+	}
+	contIdx := binary.BigEndian.Uint16(data[14:16])
+	chunk := data[16 : len(data)-2]
+
+	switch {
+	case contIdx == 0:
+		// Unfragmented frame, pass through untouched.
+		r.reset()
+		return data, true, nil
+	case contIdx == 1:
+		r.header = *h
+		r.payload = append(r.payload[:0], chunk...)
+		r.next = 2
+		return nil, false, nil
+	case contIdx == r.next || (contIdx == lastFragment && r.next > 1):
+		if r.next <= 1 {
+			r.reset()
+			return nil, false, fmt.Errorf("CFG-3 fragment %d received without a first fragment", contIdx)
+		}
+		if len(r.payload)+len(chunk) > MaxCfg3Size {
+			r.reset()
+			return nil, false, fmt.Errorf("reassembled CFG-3 exceeds %d bytes", MaxCfg3Size)
+		}
+		r.payload = append(r.payload, chunk...)
+		if contIdx != lastFragment {
+			r.next++
+			return nil, false, nil
+		}
+		frame = r.assemble()
+		r.reset()
+		return frame, true, nil
+	default:
+		r.reset()
+		return nil, false, fmt.Errorf("unexpected CFG-3 fragment index %d", contIdx)
+	}
+}
+
+// assemble builds a complete single CFG-3 frame (CONT_IDX 0) from the
+// collected fragments.
+func (r *Cfg3Reassembler) assemble() []byte {
+	buf := make([]byte, CommonHeaderSize, CommonHeaderSize+2+len(r.payload)+2)
+	buf = binary.BigEndian.AppendUint16(buf, 0) // CONT_IDX
+	buf = append(buf, r.payload...)
+	h := r.header
+	h.FrameSize = uint16(len(buf) + 2)
+	h.Encode(buf)
+	return AppendCRC(buf)
+}
+
+func (r *Cfg3Reassembler) reset() {
+	r.payload = r.payload[:0]
+	r.next = 0
+}
+
+// FragmentCfg3 splits an assembled CFG-3 frame (CONT_IDX 0) into fragments
+// of at most mtu bytes each, numbered per the CONT_IDX continuation
+// scheme. A frame that already fits within mtu is returned unchanged as a
+// single element.
+func FragmentCfg3(frame []byte, mtu int) ([][]byte, error) {
+	const overhead = CommonHeaderSize + 2 + 2 // header, CONT_IDX, CHK
+	if mtu <= overhead {
+		return nil, fmt.Errorf("MTU %d leaves no room for CFG-3 payload", mtu)
+	}
+	if len(frame) <= mtu {
+		return [][]byte{frame}, nil
+	}
+	h, err := DecodeHeader(frame)
+	if err != nil {
+		return nil, err
+	}
+	if len(frame) < overhead {
+		return nil, fmt.Errorf("CFG-3 frame too short to fragment: %d bytes", len(frame))
+	}
+	payload := frame[CommonHeaderSize+2 : len(frame)-2]
+	chunkSize := mtu - overhead
+	var frags [][]byte
+	for off := 0; off < len(payload); off += chunkSize {
+		end := off + chunkSize
+		last := false
+		if end >= len(payload) {
+			end = len(payload)
+			last = true
+		}
+		idx := uint16(len(frags) + 1)
+		if last {
+			idx = lastFragment
+		}
+		buf := make([]byte, CommonHeaderSize, overhead+end-off)
+		buf = binary.BigEndian.AppendUint16(buf, idx)
+		buf = append(buf, payload[off:end]...)
+		fh := *h
+		fh.FrameSize = uint16(len(buf) + 2)
+		fh.Encode(buf)
+		frags = append(frags, AppendCRC(buf))
+	}
+	return frags, nil
+}
@@ -0,0 +1,246 @@
+package c37
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// PhasorScale is a CFG-3 PHSCALE entry.
+type PhasorScale struct {
+	// Flags is the first 4-byte word: modification flags in the lower half,
+	// phasor type and component in the upper half.
+	Flags       uint32
+	Scale       float32 // magnitude scale factor
+	AngleOffset float32 // radians, added to the measured angle
+}
+
+// IsCurrent reports whether the phasor type bits mark a current phasor.
+func (s PhasorScale) IsCurrent() bool {
+	return s.Flags&0x00080000 != 0
+}
+
+// AnalogScale is a CFG-3 ANSCALE entry: value = raw*Scale + Offset.
+type AnalogScale struct {
+	Scale  float32
+	Offset float32
+}
+
+// PMUConfig3 describes one PMU block of a CFG-3 frame.
+type PMUConfig3 struct {
+	StationName  string
+	GlobalPMUID  [16]byte
+	IDCode       uint16
+	Format       Format
+	NumPhasors   uint16
+	NumAnalogs   uint16
+	NumDigitals  uint16
+	PhasorNames  []string
+	AnalogNames  []string
+	DigitalNames []string
+	PhasorScales []PhasorScale
+	AnalogScales []AnalogScale
+	DigitalUnits []DigitalUnit
+	Latitude     float32
+	Longitude    float32
+	Elevation    float32
+	ServiceClass byte // 'M' or 'P'
+	Window       uint32
+	GroupDelay   uint32
+	FNom         uint16
+	CfgCount     uint16
+}
+
+// CfgFrame3 is a decoded CFG-3 configuration frame.
+type CfgFrame3 struct {
+	Header   Header
+	ContIdx  uint16
+	TimeBase uint32
+	NumPMU   uint16
+	PMUs     []PMUConfig3
+	DataRate int16
+	Raw      []byte
+}
+
+func decodeVarName(data []byte, off int) (string, int, error) {
+	if off >= len(data) {
+		return "", off, fmt.Errorf("name length byte past end of frame")
+	}
+	n := int(data[off])
+	off++
+	if off+n > len(data) {
+		return "", off, fmt.Errorf("name of %d bytes past end of frame", n)
+	}
+	return string(data[off : off+n]), off + n, nil
+}
+
+// DecodeConfigFrame3 parses a complete CFG-3 frame including CRC check.
+// The frame must be fully assembled (CONT_IDX 0).
+func DecodeConfigFrame3(data []byte) (*CfgFrame3, error) {
+	h, err := DecodeHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := VerifyCRC(data); err != nil {
+		return nil, fmt.Errorf("CFG-3 frame: %w", err)
+	}
+	if len(data) < CommonHeaderSize+8 {
+		return nil, fmt.Errorf("CFG-3 frame too short: %d bytes", len(data))
+	}
+	cfg := &CfgFrame3{
+		Header:   *h,
+		ContIdx:  binary.BigEndian.Uint16(data[14:16]),
+		TimeBase: binary.BigEndian.Uint32(data[16:20]) & 0x00FFFFFF,
+		NumPMU:   binary.BigEndian.Uint16(data[20:22]),
+		Raw:      append([]byte(nil), data...),
+	}
+	if cfg.NumPMU > MaxPMUs {
+		return nil, fmt.Errorf("CFG-3 frame declares %d PMUs, limit is %d", cfg.NumPMU, MaxPMUs)
+	}
+	off := 22
+	for i := 0; i < int(cfg.NumPMU); i++ {
+		var pmu PMUConfig3
+		pmu.StationName, off, err = decodeVarName(data, off)
+		if err != nil {
+			return nil, fmt.Errorf("CFG-3 PMU block %d STN: %w", i, err)
+		}
+		if len(data) < off+26 {
+			return nil, fmt.Errorf("CFG-3 frame truncated in PMU block %d", i)
+		}
+		copy(pmu.GlobalPMUID[:], data[off:off+16])
+		off += 16
+		pmu.IDCode = binary.BigEndian.Uint16(data[off : off+2])
+		pmu.Format = DecodeFormat(binary.BigEndian.Uint16(data[off+2 : off+4]))
+		pmu.NumPhasors = binary.BigEndian.Uint16(data[off+4 : off+6])
+		pmu.NumAnalogs = binary.BigEndian.Uint16(data[off+6 : off+8])
+		pmu.NumDigitals = binary.BigEndian.Uint16(data[off+8 : off+10])
+		if pmu.NumPhasors > MaxPhasors || pmu.NumAnalogs > MaxAnalogs || pmu.NumDigitals > MaxDigitals {
+			return nil, fmt.Errorf("CFG-3 PMU block %d channel counts out of range: %d phasors, %d analogs, %d digitals",
+				i, pmu.NumPhasors, pmu.NumAnalogs, pmu.NumDigitals)
+		}
+		off += 10
+		for j := 0; j < int(pmu.NumPhasors); j++ {
+			var n string
+			n, off, err = decodeVarName(data, off)
+			if err != nil {
+				return nil, fmt.Errorf("CFG-3 PMU block %d phasor name %d: %w", i, j, err)
+			}
+			pmu.PhasorNames = append(pmu.PhasorNames, n)
+		}
+		for j := 0; j < int(pmu.NumAnalogs); j++ {
+			var n string
+			n, off, err = decodeVarName(data, off)
+			if err != nil {
+				return nil, fmt.Errorf("CFG-3 PMU block %d analog name %d: %w", i, j, err)
+			}
+			pmu.AnalogNames = append(pmu.AnalogNames, n)
+		}
+		for j := 0; j < 16*int(pmu.NumDigitals); j++ {
+			var n string
+			n, off, err = decodeVarName(data, off)
+			if err != nil {
+				return nil, fmt.Errorf("CFG-3 PMU block %d digital name %d: %w", i, j, err)
+			}
+			pmu.DigitalNames = append(pmu.DigitalNames, n)
+		}
+		need := 12*int(pmu.NumPhasors) + 8*int(pmu.NumAnalogs) + 4*int(pmu.NumDigitals) + 25
+		if len(data) < off+need {
+			return nil, fmt.Errorf("CFG-3 frame truncated in scale factors of PMU block %d", i)
+		}
+		for j := 0; j < int(pmu.NumPhasors); j++ {
+			pmu.PhasorScales = append(pmu.PhasorScales, PhasorScale{
+				Flags:       binary.BigEndian.Uint32(data[off : off+4]),
+				Scale:       math.Float32frombits(binary.BigEndian.Uint32(data[off+4 : off+8])),
+				AngleOffset: math.Float32frombits(binary.BigEndian.Uint32(data[off+8 : off+12])),
+			})
+			off += 12
+		}
+		for j := 0; j < int(pmu.NumAnalogs); j++ {
+			pmu.AnalogScales = append(pmu.AnalogScales, AnalogScale{
+				Scale:  math.Float32frombits(binary.BigEndian.Uint32(data[off : off+4])),
+				Offset: math.Float32frombits(binary.BigEndian.Uint32(data[off+4 : off+8])),
+			})
+			off += 8
+		}
+		for j := 0; j < int(pmu.NumDigitals); j++ {
+			pmu.DigitalUnits = append(pmu.DigitalUnits, DigitalUnit{
+				Normal: binary.BigEndian.Uint16(data[off : off+2]),
+				Valid:  binary.BigEndian.Uint16(data[off+2 : off+4]),
+			})
+			off += 4
+		}
+		pmu.Latitude = math.Float32frombits(binary.BigEndian.Uint32(data[off : off+4]))
+		pmu.Longitude = math.Float32frombits(binary.BigEndian.Uint32(data[off+4 : off+8]))
+		pmu.Elevation = math.Float32frombits(binary.BigEndian.Uint32(data[off+8 : off+12]))
+		pmu.ServiceClass = data[off+12]
+		pmu.Window = binary.BigEndian.Uint32(data[off+13 : off+17])
+		pmu.GroupDelay = binary.BigEndian.Uint32(data[off+17 : off+21])
+		pmu.FNom = binary.BigEndian.Uint16(data[off+21 : off+23])
+		pmu.CfgCount = binary.BigEndian.Uint16(data[off+23 : off+25])
+		off += 25
+		cfg.PMUs = append(cfg.PMUs, pmu)
+	}
+	if len(data) < off+4 {
+		return nil, fmt.Errorf("CFG-3 frame truncated before DATA_RATE")
+	}
+	cfg.DataRate = int16(binary.BigEndian.Uint16(data[off : off+2]))
+	return cfg, nil
+}
+
+// EncodeConfigFrame3 serializes a CFG-3 frame, fixing up FRAMESIZE and
+// appending the CRC.
+func EncodeConfigFrame3(cfg *CfgFrame3) []byte {
+	buf := make([]byte, CommonHeaderSize, 256)
+	buf = binary.BigEndian.AppendUint16(buf, cfg.ContIdx)
+	buf = binary.BigEndian.AppendUint32(buf, cfg.TimeBase)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(cfg.PMUs)))
+	appendName := func(n string) {
+		if len(n) > 255 {
+			n = n[:255]
+		}
+		buf = append(buf, byte(len(n)))
+		buf = append(buf, n...)
+	}
+	for i := range cfg.PMUs {
+		pc := &cfg.PMUs[i]
+		appendName(pc.StationName)
+		buf = append(buf, pc.GlobalPMUID[:]...)
+		buf = binary.BigEndian.AppendUint16(buf, pc.IDCode)
+		buf = binary.BigEndian.AppendUint16(buf, pc.Format.Encode())
+		buf = binary.BigEndian.AppendUint16(buf, pc.NumPhasors)
+		buf = binary.BigEndian.AppendUint16(buf, pc.NumAnalogs)
+		buf = binary.BigEndian.AppendUint16(buf, pc.NumDigitals)
+		for _, lists := range [][]string{pc.PhasorNames, pc.AnalogNames, pc.DigitalNames} {
+			for _, n := range lists {
+				appendName(n)
+			}
+		}
+		for _, s := range pc.PhasorScales {
+			buf = binary.BigEndian.AppendUint32(buf, s.Flags)
+			buf = binary.BigEndian.AppendUint32(buf, math.Float32bits(s.Scale))
+			buf = binary.BigEndian.AppendUint32(buf, math.Float32bits(s.AngleOffset))
+		}
+		for _, s := range pc.AnalogScales {
+			buf = binary.BigEndian.AppendUint32(buf, math.Float32bits(s.Scale))
+			buf = binary.BigEndian.AppendUint32(buf, math.Float32bits(s.Offset))
+		}
+		for _, u := range pc.DigitalUnits {
+			buf = binary.BigEndian.AppendUint16(buf, u.Normal)
+			buf = binary.BigEndian.AppendUint16(buf, u.Valid)
+		}
+		buf = binary.BigEndian.AppendUint32(buf, math.Float32bits(pc.Latitude))
+		buf = binary.BigEndian.AppendUint32(buf, math.Float32bits(pc.Longitude))
+		buf = binary.BigEndian.AppendUint32(buf, math.Float32bits(pc.Elevation))
+		buf = append(buf, pc.ServiceClass)
+		buf = binary.BigEndian.AppendUint32(buf, pc.Window)
+		buf = binary.BigEndian.AppendUint32(buf, pc.GroupDelay)
+		buf = binary.BigEndian.AppendUint16(buf, pc.FNom)
+		buf = binary.BigEndian.AppendUint16(buf, pc.CfgCount)
+	}
+	buf = binary.BigEndian.AppendUint16(buf, uint16(cfg.DataRate))
+	h := cfg.Header
+	h.Type = TypeCfg3
+	h.FrameSize = uint16(len(buf) + 2)
+	h.Encode(buf)
+	return AppendCRC(buf)
+}
@@ -0,0 +1,125 @@
+package c37
+
+import "math"
+
+// Cfg3FromCfg2 synthesizes a CFG-3 configuration from a CFG-2 one for
+// consumers that demand the 2011 format. Channel names carry over, the
+// integer PHUNIT/ANUNIT factors become the matching scale factors, and
+// the fields CFG-2 does not carry — location, service class — are filled
+// from the arguments (window and group delay stay zero, the global PMU ID
+// is derived from the IDCODE).
+func Cfg3FromCfg2(cfg *CfgFrame2, lat, lon float32, svcClass byte) *CfgFrame3 {
+	out := &CfgFrame3{
+		Header:   cfg.Header,
+		TimeBase: cfg.TimeBase,
+		NumPMU:   cfg.NumPMU,
+		DataRate: cfg.DataRate,
+	}
+	out.Header.Type = TypeCfg3
+	for i := range cfg.PMUs {
+		pc := &cfg.PMUs[i]
+		p3 := PMUConfig3{
+			StationName:  pc.StationName,
+			IDCode:       pc.IDCode,
+			Format:       pc.Format,
+			NumPhasors:   pc.NumPhasors,
+			NumAnalogs:   pc.NumAnalogs,
+			NumDigitals:  pc.NumDigitals,
+			PhasorNames:  pc.PhasorNames,
+			AnalogNames:  pc.AnalogNames,
+			DigitalNames: pc.DigitalNames,
+			DigitalUnits: pc.DigitalUnits,
+			Latitude:     lat,
+			Longitude:    lon,
+			ServiceClass: svcClass,
+			FNom:         pc.FNom,
+			CfgCount:     pc.CfgCount,
+		}
+		p3.GlobalPMUID[14] = byte(pc.IDCode >> 8)
+		p3.GlobalPMUID[15] = byte(pc.IDCode)
+		for _, u := range pc.PhasorUnits {
+			scale := PhasorScale{Scale: 1}
+			if u.IsCurrent {
+				scale.Flags |= 0x00080000
+			}
+			if !pc.Format.PHASORS {
+				scale.Scale = float32(u.Factor) * 1e-5
+			}
+			p3.PhasorScales = append(p3.PhasorScales, scale)
+		}
+		for _, u := range pc.AnalogUnits {
+			scale := AnalogScale{Scale: 1}
+			if !pc.Format.ANALOGS && u.Factor != 0 {
+				scale.Scale = float32(u.Factor)
+			}
+			p3.AnalogScales = append(p3.AnalogScales, scale)
+		}
+		out.PMUs = append(out.PMUs, p3)
+	}
+	return out
+}
+
+// name16 truncates a CFG-3 variable-length name to the 16 bytes a CFG-2
+// name field holds.
+func name16(s string) string {
+	if len(s) > 16 {
+		return s[:16]
+	}
+	return s
+}
+
+func names16(names []string) []string {
+	out := make([]string, len(names))
+	for i, n := range names {
+		out[i] = name16(n)
+	}
+	return out
+}
+
+// Cfg2FromCfg3 derives a best-effort CFG-2 configuration from a CFG-3
+// one so legacy consumers can decode the stream. Variable-length names
+// are truncated to 16 bytes and the float scale factors become the
+// nearest integer PHUNIT/ANUNIT conversion factors; the location, service
+// class and filter fields have no CFG-2 counterpart and are dropped.
+func Cfg2FromCfg3(cfg *CfgFrame3) *CfgFrame2 {
+	out := &CfgFrame2{
+		Header:   cfg.Header,
+		TimeBase: cfg.TimeBase,
+		NumPMU:   cfg.NumPMU,
+		DataRate: cfg.DataRate,
+	}
+	out.Header.Type = TypeCfg2
+	for i := range cfg.PMUs {
+		pc := &cfg.PMUs[i]
+		p2 := PMUConfig{
+			StationName:  name16(pc.StationName),
+			IDCode:       pc.IDCode,
+			Format:       pc.Format,
+			NumPhasors:   pc.NumPhasors,
+			NumAnalogs:   pc.NumAnalogs,
+			NumDigitals:  pc.NumDigitals,
+			PhasorNames:  names16(pc.PhasorNames),
+			AnalogNames:  names16(pc.AnalogNames),
+			DigitalNames: names16(pc.DigitalNames),
+			DigitalUnits: pc.DigitalUnits,
+			FNom:         pc.FNom,
+			CfgCount:     pc.CfgCount,
+		}
+		for _, s := range pc.PhasorScales {
+			u := PhasorUnit{IsCurrent: s.IsCurrent()}
+			if !pc.Format.PHASORS {
+				u.Factor = uint32(math.Round(float64(s.Scale)*1e5)) & 0x00FFFFFF
+			}
+			p2.PhasorUnits = append(p2.PhasorUnits, u)
+		}
+		for _, s := range pc.AnalogScales {
+			var u AnalogUnit
+			if !pc.Format.ANALOGS {
+				u.Factor = int32(math.Round(float64(s.Scale)))
+			}
+			p2.AnalogUnits = append(p2.AnalogUnits, u)
+		}
+		out.PMUs = append(out.PMUs, p2)
+	}
+	return out
+}
@@ -0,0 +1,282 @@
+package c37
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// Format holds the decoded FORMAT word of a configuration frame.
+type Format struct {
+	FREQ_DFREQ bool // true: 32-bit float, false: 16-bit integer
+	ANALOGS    bool // true: 32-bit float, false: 16-bit integer
+	PHASORS    bool // true: 32-bit float, false: 16-bit integer
+	POLAR      bool // true: magnitude/angle, false: rectangular
+}
+
+// DecodeFormat parses a FORMAT word.
+func DecodeFormat(w uint16) Format {
+	return Format{
+		FREQ_DFREQ: w&0x8 != 0,
+		ANALOGS:    w&0x4 != 0,
+		PHASORS:    w&0x2 != 0,
+		POLAR:      w&0x1 != 0,
+	}
+}
+
+// Encode returns the FORMAT word for f.
+func (f Format) Encode() uint16 {
+	var w uint16
+	if f.FREQ_DFREQ {
+		w |= 0x8
+	}
+	if f.ANALOGS {
+		w |= 0x4
+	}
+	if f.PHASORS {
+		w |= 0x2
+	}
+	if f.POLAR {
+		w |= 0x1
+	}
+	return w
+}
+
+// PhasorUnit is a CFG-2 PHUNIT entry: conversion factor and voltage/current flag.
+type PhasorUnit struct {
+	IsCurrent bool
+	// Factor is the raw 24-bit conversion factor; unit conversion is
+	// factor * 1e-5 V or A per LSB for integer data.
+	Factor uint32
+}
+
+// AnalogUnit is a CFG-2 ANUNIT entry.
+type AnalogUnit struct {
+	Type   uint8 // 0: single point-on-wave, 1: rms, 2: peak, 65-255: user defined
+	Factor int32 // signed 24-bit user-defined scaling
+}
+
+// TypeName returns a human-readable name for the analog type code.
+func (a AnalogUnit) TypeName() string {
+	switch a.Type {
+	case 0:
+		return "SinglePointOnWave"
+	case 1:
+		return "RMS"
+	case 2:
+		return "Peak"
+	}
+	if a.Type >= 65 {
+		return "UserDefined"
+	}
+	return fmt.Sprintf("Reserved(%d)", a.Type)
+}
+
+// DigitalUnit is a CFG-2 DIGUNIT entry: normal status and valid-inputs masks.
+type DigitalUnit struct {
+	Normal uint16
+	Valid  uint16
+}
+
+// PMUConfig describes one PMU block inside a configuration frame.
+type PMUConfig struct {
+	StationName string
+	IDCode      uint16
+	Format      Format
+	NumPhasors  uint16
+	NumAnalogs  uint16
+	NumDigitals uint16
+	// Channel names: phasors, then analogs, then 16 per digital word.
+	PhasorNames  []string
+	AnalogNames  []string
+	DigitalNames []string
+	PhasorUnits  []PhasorUnit
+	AnalogUnits  []AnalogUnit
+	DigitalUnits []DigitalUnit
+	FNom         uint16 // 0: 60 Hz, 1: 50 Hz
+	CfgCount     uint16
+}
+
+// DataBlockSize returns the byte length of this PMU's block in a data
+// frame, as implied by the channel counts and FORMAT.
+func (p *PMUConfig) DataBlockSize() int {
+	size := 2 // STAT
+	if p.Format.PHASORS {
+		size += 8 * int(p.NumPhasors)
+	} else {
+		size += 4 * int(p.NumPhasors)
+	}
+	if p.Format.FREQ_DFREQ {
+		size += 8
+	} else {
+		size += 4
+	}
+	if p.Format.ANALOGS {
+		size += 4 * int(p.NumAnalogs)
+	} else {
+		size += 2 * int(p.NumAnalogs)
+	}
+	size += 2 * int(p.NumDigitals)
+	return size
+}
+
+// checkChannelCounts rejects channel counts beyond the sane limits before
+// they drive allocations or offsets.
+func (p *PMUConfig) checkChannelCounts() error {
+	if p.NumPhasors > MaxPhasors || p.NumAnalogs > MaxAnalogs || p.NumDigitals > MaxDigitals {
+		return fmt.Errorf("channel counts out of range: %d phasors, %d analogs, %d digitals",
+			p.NumPhasors, p.NumAnalogs, p.NumDigitals)
+	}
+	return nil
+}
+
+// NominalFreq returns the nominal frequency in Hz.
+func (p *PMUConfig) NominalFreq() float64 {
+	if p.FNom&0x1 != 0 {
+		return 50
+	}
+	return 60
+}
+
+// CfgFrame2 is a decoded CFG-1 or CFG-2 configuration frame.
+type CfgFrame2 struct {
+	Header   Header
+	TimeBase uint32
+	NumPMU   uint16
+	PMUs     []PMUConfig
+	DataRate int16
+	// Raw holds the frame bytes as received, so a verbatim copy can be
+	// forwarded without re-encoding.
+	Raw []byte
+}
+
+// Format returns the FORMAT of the first PMU, the common single-PMU case.
+func (c *CfgFrame2) Format() Format {
+	if len(c.PMUs) == 0 {
+		return Format{}
+	}
+	return c.PMUs[0].Format
+}
+
+func decodeName16(data []byte) string {
+	return strings.TrimRight(string(data[:16]), " \x00")
+}
+
+// DecodeConfigFrame2 parses a complete CFG-1/CFG-2 frame including CRC check.
+func DecodeConfigFrame2(data []byte) (*CfgFrame2, error) {
+	cfg, _, err := decodeConfigFrame2(data, false)
+	return cfg, err
+}
+
+// DecodeConfigFrame2Lenient parses a CFG-1/CFG-2 frame tolerantly: CRC and
+// FRAMESIZE mismatches and trailing vendor padding are recorded as warnings
+// instead of aborting the decode.
+func DecodeConfigFrame2Lenient(data []byte) (*CfgFrame2, []string, error) {
+	return decodeConfigFrame2(data, true)
+}
+
+func decodeConfigFrame2(data []byte, lenient bool) (*CfgFrame2, []string, error) {
+	var warnings []string
+	h, err := DecodeHeader(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := VerifyCRC(data); err != nil {
+		if !lenient {
+			return nil, nil, fmt.Errorf("config frame: %w", err)
+		}
+		warnings = append(warnings, err.Error())
+	}
+	if int(h.FrameSize) != len(data) && lenient {
+		warnings = append(warnings,
+			fmt.Sprintf("FRAMESIZE %d does not match received %d bytes", h.FrameSize, len(data)))
+	}
+	if len(data) < CommonHeaderSize+6 {
+		return nil, warnings, fmt.Errorf("config frame too short: %d bytes", len(data))
+	}
+	cfg := &CfgFrame2{
+		Header:   *h,
+		TimeBase: binary.BigEndian.Uint32(data[14:18]) & 0x00FFFFFF,
+		NumPMU:   binary.BigEndian.Uint16(data[18:20]),
+		Raw:      append([]byte(nil), data...),
+	}
+	if cfg.NumPMU > MaxPMUs {
+		return nil, warnings, fmt.Errorf("config frame declares %d PMUs, limit is %d", cfg.NumPMU, MaxPMUs)
+	}
+	off := 20
+	for i := 0; i < int(cfg.NumPMU); i++ {
+		if len(data) < off+26 {
+			return nil, warnings, fmt.Errorf("config frame truncated in PMU block %d", i)
+		}
+		pmu := PMUConfig{
+			StationName: decodeName16(data[off : off+16]),
+			IDCode:      binary.BigEndian.Uint16(data[off+16 : off+18]),
+			Format:      DecodeFormat(binary.BigEndian.Uint16(data[off+18 : off+20])),
+			NumPhasors:  binary.BigEndian.Uint16(data[off+20 : off+22]),
+			NumAnalogs:  binary.BigEndian.Uint16(data[off+22 : off+24]),
+			NumDigitals: binary.BigEndian.Uint16(data[off+24 : off+26]),
+		}
+		if err := pmu.checkChannelCounts(); err != nil {
+			return nil, warnings, fmt.Errorf("config frame PMU block %d: %w", i, err)
+		}
+		off += 26
+		names := int(pmu.NumPhasors) + int(pmu.NumAnalogs) + 16*int(pmu.NumDigitals)
+		if len(data) < off+16*names {
+			return nil, warnings, fmt.Errorf("config frame truncated in CHNAM of PMU block %d", i)
+		}
+		for j := 0; j < int(pmu.NumPhasors); j++ {
+			pmu.PhasorNames = append(pmu.PhasorNames, decodeName16(data[off:]))
+			off += 16
+		}
+		for j := 0; j < int(pmu.NumAnalogs); j++ {
+			pmu.AnalogNames = append(pmu.AnalogNames, decodeName16(data[off:]))
+			off += 16
+		}
+		for j := 0; j < 16*int(pmu.NumDigitals); j++ {
+			pmu.DigitalNames = append(pmu.DigitalNames, decodeName16(data[off:]))
+			off += 16
+		}
+		units := 4 * (int(pmu.NumPhasors) + int(pmu.NumAnalogs) + int(pmu.NumDigitals))
+		if len(data) < off+units+4 {
+			return nil, warnings, fmt.Errorf("config frame truncated in units of PMU block %d", i)
+		}
+		for j := 0; j < int(pmu.NumPhasors); j++ {
+			pmu.PhasorUnits = append(pmu.PhasorUnits, PhasorUnit{
+				IsCurrent: data[off] == 1,
+				Factor:    binary.BigEndian.Uint32(data[off:off+4]) & 0x00FFFFFF,
+			})
+			off += 4
+		}
+		for j := 0; j < int(pmu.NumAnalogs); j++ {
+			raw := binary.BigEndian.Uint32(data[off : off+4])
+			factor := int32(raw & 0x00FFFFFF)
+			if factor&0x00800000 != 0 {
+				factor |= -0x01000000 // sign-extend 24 bits
+			}
+			pmu.AnalogUnits = append(pmu.AnalogUnits, AnalogUnit{
+				Type:   uint8(raw >> 24),
+				Factor: factor,
+			})
+			off += 4
+		}
+		for j := 0; j < int(pmu.NumDigitals); j++ {
+			pmu.DigitalUnits = append(pmu.DigitalUnits, DigitalUnit{
+				Normal: binary.BigEndian.Uint16(data[off : off+2]),
+				Valid:  binary.BigEndian.Uint16(data[off+2 : off+4]),
+			})
+			off += 4
+		}
+		pmu.FNom = binary.BigEndian.Uint16(data[off : off+2])
+		pmu.CfgCount = binary.BigEndian.Uint16(data[off+2 : off+4])
+		off += 4
+		cfg.PMUs = append(cfg.PMUs, pmu)
+	}
+	if len(data) < off+4 {
+		return nil, warnings, fmt.Errorf("config frame truncated before DATA_RATE")
+	}
+	cfg.DataRate = int16(binary.BigEndian.Uint16(data[off : off+2]))
+	if extra := len(data) - (off + 4); extra > 0 && lenient {
+		warnings = append(warnings, fmt.Sprintf("%d unexpected trailing bytes after DATA_RATE", extra))
+	}
+	return cfg, warnings, nil
+}
@@ -0,0 +1,182 @@
+package c37
+
+import (
+	"math"
+	"testing"
+)
+
+// testConfig builds a single-PMU configuration with two phasors, one
+// analog and one digital word in the given format.
+func testConfig(f Format) *CfgFrame2 {
+	return &CfgFrame2{
+		Header:   Header{Version: Version2011, IDCode: 7, SOC: 1_700_000_000},
+		TimeBase: 1_000_000,
+		NumPMU:   1,
+		DataRate: 50,
+		PMUs: []PMUConfig{{
+			StationName: "STATION A",
+			IDCode:      7,
+			Format:      f,
+			NumPhasors:  2,
+			NumAnalogs:  1,
+			NumDigitals: 1,
+			PhasorNames: []string{"VA", "IA"},
+			AnalogNames: []string{"ANALOG1"},
+			DigitalNames: func() []string {
+				names := make([]string, 16)
+				for i := range names {
+					names[i] = "BREAKER"
+				}
+				return names
+			}(),
+			PhasorUnits: []PhasorUnit{
+				{Factor: 100000},
+				{IsCurrent: true, Factor: 100000},
+			},
+			AnalogUnits:  []AnalogUnit{{Type: 1, Factor: -5}},
+			DigitalUnits: []DigitalUnit{{Normal: 0x0001, Valid: 0xFFFF}},
+			FNom:         1, // 50 Hz
+			CfgCount:     1,
+		}},
+	}
+}
+
+func TestConfigFrame2RoundTrip(t *testing.T) {
+	cfg := testConfig(Format{FREQ_DFREQ: true, ANALOGS: true, PHASORS: true, POLAR: true})
+	frame := EncodeConfigFrame2(cfg)
+	got, err := DecodeConfigFrame2(frame)
+	if err != nil {
+		t.Fatalf("DecodeConfigFrame2: %v", err)
+	}
+	if got.TimeBase != cfg.TimeBase || got.NumPMU != cfg.NumPMU || got.DataRate != cfg.DataRate {
+		t.Errorf("frame fields mismatch: got %d/%d/%d, want %d/%d/%d",
+			got.TimeBase, got.NumPMU, got.DataRate, cfg.TimeBase, cfg.NumPMU, cfg.DataRate)
+	}
+	gp, wp := &got.PMUs[0], &cfg.PMUs[0]
+	if gp.StationName != wp.StationName || gp.IDCode != wp.IDCode || gp.Format != wp.Format {
+		t.Errorf("PMU identity mismatch: got %q/%d/%+v, want %q/%d/%+v",
+			gp.StationName, gp.IDCode, gp.Format, wp.StationName, wp.IDCode, wp.Format)
+	}
+	if gp.NumPhasors != wp.NumPhasors || gp.NumAnalogs != wp.NumAnalogs || gp.NumDigitals != wp.NumDigitals {
+		t.Errorf("channel counts mismatch: got %d/%d/%d, want %d/%d/%d",
+			gp.NumPhasors, gp.NumAnalogs, gp.NumDigitals, wp.NumPhasors, wp.NumAnalogs, wp.NumDigitals)
+	}
+	for i, n := range wp.PhasorNames {
+		if gp.PhasorNames[i] != n {
+			t.Errorf("phasor name %d = %q, want %q", i, gp.PhasorNames[i], n)
+		}
+	}
+	for i, u := range wp.PhasorUnits {
+		if gp.PhasorUnits[i] != u {
+			t.Errorf("PHUNIT %d = %+v, want %+v", i, gp.PhasorUnits[i], u)
+		}
+	}
+	if gp.AnalogUnits[0] != wp.AnalogUnits[0] {
+		t.Errorf("ANUNIT = %+v, want %+v (sign extension)", gp.AnalogUnits[0], wp.AnalogUnits[0])
+	}
+	if gp.DigitalUnits[0] != wp.DigitalUnits[0] {
+		t.Errorf("DIGUNIT = %+v, want %+v", gp.DigitalUnits[0], wp.DigitalUnits[0])
+	}
+	if gp.FNom != wp.FNom || gp.CfgCount != wp.CfgCount {
+		t.Errorf("FNOM/CFGCNT mismatch: got %d/%d, want %d/%d", gp.FNom, gp.CfgCount, wp.FNom, wp.CfgCount)
+	}
+	if gp.NominalFreq() != 50 {
+		t.Errorf("NominalFreq = %v, want 50", gp.NominalFreq())
+	}
+}
+
+func testDataFrame() *DataFrame {
+	return &DataFrame{
+		Header: Header{Version: Version2011, IDCode: 7, SOC: 1_700_000_000, FracSec: 0x04<<24 | 250_000},
+		PMUs: []PMUData{{
+			Stat: DecodeStat(0x0800),
+			Phasors: []Phasor{
+				{Magnitude: 1234, Angle: 0.5},
+				{Magnitude: 99.5, Angle: -1.25},
+			},
+			Freq:     50.25,
+			DFreq:    -0.05,
+			Analogs:  []float64{42},
+			Digitals: []uint16{0xBEEF},
+		}},
+	}
+}
+
+// closeTo compares within tol plus a small relative term for the float32
+// precision lost in encoding.
+func closeTo(got, want, tol float64) bool {
+	return math.Abs(got-want) <= tol+1e-5*math.Abs(want)
+}
+
+func TestDataFrameRoundTrip(t *testing.T) {
+	// Integer phasors quantize to one count per component (one unit of the
+	// PHUNIT scale), so their magnitude tolerance is wider than float32's.
+	tests := []struct {
+		name           string
+		f              Format
+		magTol, angTol float64
+	}{
+		{"float-polar", Format{FREQ_DFREQ: true, ANALOGS: true, PHASORS: true, POLAR: true}, 1e-3, 1e-3},
+		{"float-rect", Format{FREQ_DFREQ: true, ANALOGS: true, PHASORS: true}, 1e-3, 1e-3},
+		{"int-polar", Format{POLAR: true}, 0.75, 1e-3},
+		{"int-rect", Format{}, 0.75, 0.01},
+		{"mixed-phasor", Format{FREQ_DFREQ: true, ANALOGS: true, POLAR: true}, 0.75, 1e-3},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := testConfig(tc.f)
+			df := testDataFrame()
+			frame := EncodeDataFrame(df, cfg)
+			if err := VerifyCRC(frame); err != nil {
+				t.Fatalf("encoded frame: %v", err)
+			}
+			got, err := DecodeDataFrame(frame, cfg)
+			if err != nil {
+				t.Fatalf("DecodeDataFrame: %v", err)
+			}
+			if got.Header.Type != TypeData || got.Header.IDCode != df.Header.IDCode ||
+				got.Header.SOC != df.Header.SOC || got.Header.FracSec != df.Header.FracSec {
+				t.Errorf("header mismatch: got %+v, want %+v", got.Header, df.Header)
+			}
+			if int(got.Header.FrameSize) != len(frame) {
+				t.Errorf("FRAMESIZE %d does not match %d encoded bytes", got.Header.FrameSize, len(frame))
+			}
+			gp, wp := &got.PMUs[0], &df.PMUs[0]
+			if gp.Stat.Raw != wp.Stat.Raw || !gp.Stat.PMUTrigger {
+				t.Errorf("STAT mismatch: got %+v, want %+v", gp.Stat, wp.Stat)
+			}
+			for i, ph := range wp.Phasors {
+				if !closeTo(gp.Phasors[i].Magnitude, ph.Magnitude, tc.magTol) ||
+					!closeTo(gp.Phasors[i].Angle, ph.Angle, tc.angTol) {
+					t.Errorf("phasor %d = %+v, want %+v", i, gp.Phasors[i], ph)
+				}
+			}
+			if !closeTo(gp.Freq, wp.Freq, 1e-3) || !closeTo(gp.DFreq, wp.DFreq, 1e-3) {
+				t.Errorf("FREQ/DFREQ = %v/%v, want %v/%v", gp.Freq, gp.DFreq, wp.Freq, wp.DFreq)
+			}
+			if !closeTo(gp.Analogs[0], wp.Analogs[0], 1e-3) {
+				t.Errorf("analog = %v, want %v", gp.Analogs[0], wp.Analogs[0])
+			}
+			if gp.Digitals[0] != wp.Digitals[0] {
+				t.Errorf("digital = 0x%04X, want 0x%04X", gp.Digitals[0], wp.Digitals[0])
+			}
+		})
+	}
+}
+
+func TestDecodeDataFrameCorrupt(t *testing.T) {
+	cfg := testConfig(Format{FREQ_DFREQ: true, ANALOGS: true, PHASORS: true, POLAR: true})
+	frame := EncodeDataFrame(testDataFrame(), cfg)
+	frame[CommonHeaderSize] ^= 0x80
+	if _, err := DecodeDataFrame(frame, cfg); err == nil {
+		t.Fatal("DecodeDataFrame accepted a frame with a bad CRC")
+	}
+	if _, warnings, err := DecodeDataFrameLenient(frame, cfg); err != nil {
+		t.Fatalf("DecodeDataFrameLenient: %v", err)
+	} else if len(warnings) == 0 {
+		t.Error("lenient decode of a corrupted frame produced no warnings")
+	}
+	if _, err := DecodeDataFrame(frame[:CommonHeaderSize+4], cfg); err == nil {
+		t.Fatal("DecodeDataFrame accepted a truncated frame")
+	}
+}
@@ -0,0 +1,61 @@
+package c37
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// Command codes carried in the CMD field of a command frame.
+const (
+	CmdDataOff  uint16 = 1
+	CmdDataOn   uint16 = 2
+	CmdSendHdr  uint16 = 3
+	CmdSendCfg1 uint16 = 4
+	CmdSendCfg2 uint16 = 5
+	CmdSendCfg3 uint16 = 6
+)
+
+// CmdFrame is a decoded command frame.
+type CmdFrame struct {
+	Header  Header
+	Command uint16
+	ExtData []byte
+}
+
+// DecodeCommandFrame parses a command frame including CRC check.
+func DecodeCommandFrame(data []byte) (*CmdFrame, error) {
+	h, err := DecodeHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := VerifyCRC(data); err != nil {
+		return nil, fmt.Errorf("command frame: %w", err)
+	}
+	if len(data) < CommonHeaderSize+4 {
+		return nil, fmt.Errorf("command frame too short: %d bytes", len(data))
+	}
+	return &CmdFrame{
+		Header:  *h,
+		Command: binary.BigEndian.Uint16(data[14:16]),
+		ExtData: append([]byte(nil), data[16:len(data)-2]...),
+	}, nil
+}
+
+// BuildCommandFrame creates a command frame addressed to idCode, stamped
+// with the current time.
+func BuildCommandFrame(idCode uint16, command uint16) []byte {
+	now := time.Now().UTC()
+	buf := make([]byte, CommonHeaderSize, CommonHeaderSize+4)
+	buf = binary.BigEndian.AppendUint16(buf, command)
+	h := Header{
+		Type:      TypeCmd,
+		Version:   1,
+		FrameSize: uint16(len(buf) + 2),
+		IDCode:    idCode,
+		SOC:       uint32(now.Unix()),
+		FracSec:   uint32(now.Nanosecond() / 1000), // TIME_BASE 1e6 convention
+	}
+	h.Encode(buf)
+	return AppendCRC(buf)
+}
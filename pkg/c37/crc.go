@@ -0,0 +1,42 @@
+package c37
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// CalculateCRC computes the CRC-CCITT checksum (polynomial X^16+X^12+X^5+1,
+// initial value 0xFFFF) used by C37.118 for the CHK field.
+func CalculateCRC(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// VerifyCRC checks the trailing CHK field of a complete frame.
+func VerifyCRC(frame []byte) error {
+	if len(frame) < 2 {
+		return fmt.Errorf("frame too short for CRC check")
+	}
+	want := binary.BigEndian.Uint16(frame[len(frame)-2:])
+	got := CalculateCRC(frame[:len(frame)-2])
+	if want != got {
+		return fmt.Errorf("CRC mismatch: frame carries 0x%04X, computed 0x%04X", want, got)
+	}
+	return nil
+}
+
+// AppendCRC computes the checksum over frame and appends the CHK field.
+func AppendCRC(frame []byte) []byte {
+	crc := CalculateCRC(frame)
+	return append(frame, byte(crc>>8), byte(crc))
+}
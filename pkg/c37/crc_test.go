@@ -0,0 +1,34 @@
+package c37
+
+import "testing"
+
+func TestCalculateCRC(t *testing.T) {
+	// Known CRC-CCITT (0xFFFF) check values.
+	tests := []struct {
+		data string
+		want uint16
+	}{
+		{"", 0xFFFF},
+		{"A", 0xB915},
+		{"123456789", 0x29B1},
+	}
+	for _, tt := range tests {
+		if got := CalculateCRC([]byte(tt.data)); got != tt.want {
+			t.Errorf("CalculateCRC(%q) = 0x%04X, want 0x%04X", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestAppendVerifyCRC(t *testing.T) {
+	frame := AppendCRC([]byte{0xAA, 0x01, 0x00, 0x10, 0x12, 0x34})
+	if err := VerifyCRC(frame); err != nil {
+		t.Fatalf("VerifyCRC on freshly appended frame: %v", err)
+	}
+	frame[2] ^= 0x01
+	if err := VerifyCRC(frame); err == nil {
+		t.Fatal("VerifyCRC accepted a corrupted frame")
+	}
+	if err := VerifyCRC([]byte{0xAA}); err == nil {
+		t.Fatal("VerifyCRC accepted a one-byte frame")
+	}
+}
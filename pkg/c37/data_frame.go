@@ -0,0 +1,195 @@
+package c37
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Stat holds the decoded STAT word of one PMU data block.
+type Stat struct {
+	Raw          uint16
+	DataError    uint8 // bits 15-14: 0 good, 1 PMU error, 2 test, 3 error
+	PMUSync      bool  // bit 13: set when PMU is NOT in sync with UTC
+	DataSorting  bool  // bit 12: sorted by arrival instead of timestamp
+	PMUTrigger   bool  // bit 11
+	CfgChange    bool  // bit 10: configuration change advised
+	DataModified bool  // bit 9
+	TimeQuality  uint8 // bits 8-6: PMU time quality
+	UnlockedTime uint8 // bits 5-4: 0 <10s, 1 <100s, 2 <1000s, 3 >1000s
+	TriggerCode  uint8 // bits 3-0
+}
+
+// DecodeStat parses a STAT word per the 2011 revision.
+func DecodeStat(w uint16) Stat {
+	return Stat{
+		Raw:          w,
+		DataError:    uint8(w >> 14),
+		PMUSync:      w&0x2000 != 0,
+		DataSorting:  w&0x1000 != 0,
+		PMUTrigger:   w&0x0800 != 0,
+		CfgChange:    w&0x0400 != 0,
+		DataModified: w&0x0200 != 0,
+		TimeQuality:  uint8(w >> 6 & 0x7),
+		UnlockedTime: uint8(w >> 4 & 0x3),
+		TriggerCode:  uint8(w & 0xF),
+	}
+}
+
+// DecodeStatVersion parses a STAT word according to the stream's C37.118
+// version. Bits 9-6 were reserved until the 2011 revision assigned them
+// the data-modified flag and the PMU time quality, so for 2005 streams
+// they are left unset rather than interpreted.
+func DecodeStatVersion(w uint16, version uint8) Stat {
+	s := DecodeStat(w)
+	if version < Version2011 {
+		s.DataModified = false
+		s.TimeQuality = 0
+	}
+	return s
+}
+
+// Phasor is one phasor measurement in polar form. Rectangular input is
+// converted on decode so the rest of the pipeline works in a single form.
+type Phasor struct {
+	Magnitude float64
+	Angle     float64 // radians
+}
+
+// Rectangular returns the real/imaginary representation of the phasor.
+func (p Phasor) Rectangular() (re, im float64) {
+	return p.Magnitude * math.Cos(p.Angle), p.Magnitude * math.Sin(p.Angle)
+}
+
+// PMUData is the decoded measurement block of one PMU in a data frame.
+type PMUData struct {
+	Stat     Stat
+	Phasors  []Phasor
+	Freq     float64 // Hz
+	DFreq    float64 // Hz/s (ROCOF)
+	Analogs  []float64
+	Digitals []uint16
+}
+
+// DataFrame is a decoded C37.118 data frame.
+type DataFrame struct {
+	Header Header
+	PMUs   []PMUData
+	// Raw holds the frame bytes as received for verbatim forwarding.
+	Raw []byte
+}
+
+// DecodeDataFrame parses a data frame using the layout described by cfg.
+func DecodeDataFrame(data []byte, cfg *CfgFrame2) (*DataFrame, error) {
+	df, _, err := decodeDataFrame(data, cfg, false)
+	return df, err
+}
+
+// DecodeDataFrameLenient parses a data frame tolerantly: CRC and FRAMESIZE
+// mismatches are recorded as warnings instead of aborting the decode.
+func DecodeDataFrameLenient(data []byte, cfg *CfgFrame2) (*DataFrame, []string, error) {
+	return decodeDataFrame(data, cfg, true)
+}
+
+func decodeDataFrame(data []byte, cfg *CfgFrame2, lenient bool) (*DataFrame, []string, error) {
+	var warnings []string
+	h, err := DecodeHeader(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := VerifyCRC(data); err != nil {
+		if !lenient {
+			return nil, nil, fmt.Errorf("data frame: %w", err)
+		}
+		warnings = append(warnings, err.Error())
+	}
+	if int(h.FrameSize) != len(data) && lenient {
+		warnings = append(warnings,
+			fmt.Sprintf("FRAMESIZE %d does not match received %d bytes", h.FrameSize, len(data)))
+	}
+	need := CommonHeaderSize + 2
+	for i := range cfg.PMUs {
+		need += cfg.PMUs[i].DataBlockSize()
+	}
+	if len(data) < need {
+		return nil, warnings, fmt.Errorf("data frame too short: %d bytes, configuration requires %d", len(data), need)
+	}
+	df := &DataFrame{Header: *h, Raw: append([]byte(nil), data...)}
+	off := CommonHeaderSize
+	for i := range cfg.PMUs {
+		pc := &cfg.PMUs[i]
+		if int(pc.NumPhasors) > len(pc.PhasorUnits) && !pc.Format.PHASORS {
+			return nil, warnings, fmt.Errorf("PMU block %d: %d phasors but only %d PHUNIT entries",
+				i, pc.NumPhasors, len(pc.PhasorUnits))
+		}
+		pd := PMUData{Stat: DecodeStatVersion(binary.BigEndian.Uint16(data[off:off+2]), h.Version)}
+		off += 2
+		for j := 0; j < int(pc.NumPhasors); j++ {
+			var ph Phasor
+			if pc.Format.PHASORS {
+				a := math.Float32frombits(binary.BigEndian.Uint32(data[off : off+4]))
+				b := math.Float32frombits(binary.BigEndian.Uint32(data[off+4 : off+8]))
+				off += 8
+				if pc.Format.POLAR {
+					ph = Phasor{Magnitude: float64(a), Angle: float64(b)}
+				} else {
+					ph = rectToPolar(float64(a), float64(b))
+				}
+			} else {
+				if pc.Format.POLAR {
+					mag := binary.BigEndian.Uint16(data[off : off+2])
+					ang := int16(binary.BigEndian.Uint16(data[off+2 : off+4]))
+					ph = Phasor{
+						Magnitude: float64(mag) * float64(pc.PhasorUnits[j].Factor) * 1e-5,
+						Angle:     float64(ang) * 1e-4,
+					}
+				} else {
+					re := int16(binary.BigEndian.Uint16(data[off : off+2]))
+					im := int16(binary.BigEndian.Uint16(data[off+2 : off+4]))
+					scale := float64(pc.PhasorUnits[j].Factor) * 1e-5
+					ph = rectToPolar(float64(re)*scale, float64(im)*scale)
+				}
+				off += 4
+			}
+			pd.Phasors = append(pd.Phasors, ph)
+		}
+		pd.Freq, off = decodeFreqWord(data, off, pc, true)
+		pd.DFreq, off = decodeFreqWord(data, off, pc, false)
+		for j := 0; j < int(pc.NumAnalogs); j++ {
+			if pc.Format.ANALOGS {
+				v := math.Float32frombits(binary.BigEndian.Uint32(data[off : off+4]))
+				pd.Analogs = append(pd.Analogs, float64(v))
+				off += 4
+			} else {
+				v := int16(binary.BigEndian.Uint16(data[off : off+2]))
+				pd.Analogs = append(pd.Analogs, float64(v))
+				off += 2
+			}
+		}
+		for j := 0; j < int(pc.NumDigitals); j++ {
+			pd.Digitals = append(pd.Digitals, binary.BigEndian.Uint16(data[off:off+2]))
+			off += 2
+		}
+		df.PMUs = append(df.PMUs, pd)
+	}
+	return df, warnings, nil
+}
+
+func rectToPolar(re, im float64) Phasor {
+	return Phasor{Magnitude: math.Hypot(re, im), Angle: math.Atan2(im, re)}
+}
+
+// decodeFreqWord decodes FREQ or DFREQ at off and returns the value in Hz
+// (or Hz/s) together with the new offset. Integer FREQ is a deviation from
+// nominal in millihertz; integer DFREQ is in units of 0.01 Hz/s.
+func decodeFreqWord(data []byte, off int, pc *PMUConfig, isFreq bool) (float64, int) {
+	if pc.Format.FREQ_DFREQ {
+		v := math.Float32frombits(binary.BigEndian.Uint32(data[off : off+4]))
+		return float64(v), off + 4
+	}
+	v := int16(binary.BigEndian.Uint16(data[off : off+2]))
+	if isFreq {
+		return pc.NominalFreq() + float64(v)/1000, off + 2
+	}
+	return float64(v) / 100, off + 2
+}
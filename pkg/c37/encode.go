@@ -0,0 +1,147 @@
+package c37
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+func encodeName16(buf []byte, name string) {
+	for i := 0; i < 16; i++ {
+		if i < len(name) {
+			buf[i] = name[i]
+		} else {
+			buf[i] = ' '
+		}
+	}
+}
+
+// EncodePhasor serializes one phasor according to the PMU format and the
+// matching PHUNIT (used for integer scaling) and appends it to buf.
+func EncodePhasor(buf []byte, ph Phasor, pc *PMUConfig, unit PhasorUnit) []byte {
+	if pc.Format.PHASORS {
+		var a, b float64
+		if pc.Format.POLAR {
+			a, b = ph.Magnitude, ph.Angle
+		} else {
+			a, b = ph.Rectangular()
+		}
+		buf = binary.BigEndian.AppendUint32(buf, math.Float32bits(float32(a)))
+		return binary.BigEndian.AppendUint32(buf, math.Float32bits(float32(b)))
+	}
+	scale := float64(unit.Factor) * 1e-5
+	if scale == 0 {
+		scale = 1
+	}
+	if pc.Format.POLAR {
+		buf = binary.BigEndian.AppendUint16(buf, uint16(math.Round(ph.Magnitude/scale)))
+		return binary.BigEndian.AppendUint16(buf, uint16(int16(math.Round(ph.Angle*1e4))))
+	}
+	re, im := ph.Rectangular()
+	buf = binary.BigEndian.AppendUint16(buf, uint16(int16(math.Round(re/scale))))
+	return binary.BigEndian.AppendUint16(buf, uint16(int16(math.Round(im/scale))))
+}
+
+// EncodeFrequency serializes the FREQ field according to the PMU format
+// and appends it to buf. Integer FREQ is the deviation from nominal in
+// millihertz, mirroring decodeFreqWord.
+func EncodeFrequency(buf []byte, freq float64, pc *PMUConfig) []byte {
+	if pc.Format.FREQ_DFREQ {
+		return binary.BigEndian.AppendUint32(buf, math.Float32bits(float32(freq)))
+	}
+	dev := (freq - pc.NominalFreq()) * 1000
+	return binary.BigEndian.AppendUint16(buf, uint16(int16(math.Round(dev))))
+}
+
+// EncodeDFreq serializes the DFREQ (ROCOF) field according to the PMU
+// format and appends it to buf. Integer DFREQ is in units of 0.01 Hz/s.
+func EncodeDFreq(buf []byte, dfreq float64, pc *PMUConfig) []byte {
+	if pc.Format.FREQ_DFREQ {
+		return binary.BigEndian.AppendUint32(buf, math.Float32bits(float32(dfreq)))
+	}
+	return binary.BigEndian.AppendUint16(buf, uint16(int16(math.Round(dfreq*100))))
+}
+
+// EncodeDataFrame serializes a decoded data frame using the layout described
+// by cfg, fixing up FRAMESIZE and appending the CRC.
+func EncodeDataFrame(df *DataFrame, cfg *CfgFrame2) []byte {
+	buf := make([]byte, CommonHeaderSize, 64)
+	for i := range df.PMUs {
+		pc := &cfg.PMUs[i]
+		pd := &df.PMUs[i]
+		buf = binary.BigEndian.AppendUint16(buf, pd.Stat.Raw)
+		for j, ph := range pd.Phasors {
+			buf = EncodePhasor(buf, ph, pc, pc.PhasorUnits[j])
+		}
+		buf = EncodeFrequency(buf, pd.Freq, pc)
+		buf = EncodeDFreq(buf, pd.DFreq, pc)
+		for _, v := range pd.Analogs {
+			if pc.Format.ANALOGS {
+				buf = binary.BigEndian.AppendUint32(buf, math.Float32bits(float32(v)))
+			} else {
+				buf = binary.BigEndian.AppendUint16(buf, uint16(int16(math.Round(v))))
+			}
+		}
+		for _, v := range pd.Digitals {
+			buf = binary.BigEndian.AppendUint16(buf, v)
+		}
+	}
+	h := df.Header
+	h.Type = TypeData
+	h.FrameSize = uint16(len(buf) + 2)
+	h.Encode(buf)
+	return AppendCRC(buf)
+}
+
+// EncodeConfigFrame2 serializes a CFG-2 frame, fixing up FRAMESIZE and
+// appending the CRC.
+func EncodeConfigFrame2(cfg *CfgFrame2) []byte {
+	buf := make([]byte, CommonHeaderSize, 256)
+	buf = binary.BigEndian.AppendUint32(buf, cfg.TimeBase)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(cfg.PMUs)))
+	for i := range cfg.PMUs {
+		buf = AppendPMUConfig(buf, &cfg.PMUs[i])
+	}
+	buf = binary.BigEndian.AppendUint16(buf, uint16(cfg.DataRate))
+	h := cfg.Header
+	h.Type = TypeCfg2
+	h.FrameSize = uint16(len(buf) + 2)
+	h.Encode(buf)
+	return AppendCRC(buf)
+}
+
+// AppendPMUConfig serializes one CFG-2 PMU block and returns the extended
+// buffer. PHUNIT and ANUNIT entries are packed as one type byte followed
+// by a 24-bit factor, per the standard.
+func AppendPMUConfig(buf []byte, pc *PMUConfig) []byte {
+	name := make([]byte, 16)
+	encodeName16(name, pc.StationName)
+	buf = append(buf, name...)
+	buf = binary.BigEndian.AppendUint16(buf, pc.IDCode)
+	buf = binary.BigEndian.AppendUint16(buf, pc.Format.Encode())
+	buf = binary.BigEndian.AppendUint16(buf, pc.NumPhasors)
+	buf = binary.BigEndian.AppendUint16(buf, pc.NumAnalogs)
+	buf = binary.BigEndian.AppendUint16(buf, pc.NumDigitals)
+	for _, lists := range [][]string{pc.PhasorNames, pc.AnalogNames, pc.DigitalNames} {
+		for _, n := range lists {
+			encodeName16(name, n)
+			buf = append(buf, name...)
+		}
+	}
+	for _, u := range pc.PhasorUnits {
+		w := u.Factor & 0x00FFFFFF
+		if u.IsCurrent {
+			w |= 1 << 24
+		}
+		buf = binary.BigEndian.AppendUint32(buf, w)
+	}
+	for _, u := range pc.AnalogUnits {
+		w := uint32(u.Type)<<24 | uint32(u.Factor)&0x00FFFFFF
+		buf = binary.BigEndian.AppendUint32(buf, w)
+	}
+	for _, u := range pc.DigitalUnits {
+		buf = binary.BigEndian.AppendUint16(buf, u.Normal)
+		buf = binary.BigEndian.AppendUint16(buf, u.Valid)
+	}
+	buf = binary.BigEndian.AppendUint16(buf, pc.FNom)
+	return binary.BigEndian.AppendUint16(buf, pc.CfgCount)
+}
@@ -0,0 +1,197 @@
+// Package c37 contains the IEEE C37.118.2-2011 frame definitions together
+// with their decoders and encoders. It has no dependencies on the rest of
+// the reductor and can be imported on its own.
+package c37
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// SyncLead is the leading byte of every C37.118 frame.
+const SyncLead = 0xAA
+
+// Version nibbles carried in the SYNC word. Version 1 is the 2005
+// standard, version 2 the 2011 revision that added CFG-3 and the extra
+// STAT bits.
+const (
+	Version2005 uint8 = 1
+	Version2011 uint8 = 2
+)
+
+// FrameType identifies the frame kind carried in the SYNC word.
+type FrameType uint8
+
+const (
+	TypeData   FrameType = 0
+	TypeHeader FrameType = 1
+	TypeCfg1   FrameType = 2
+	TypeCfg2   FrameType = 3
+	TypeCmd    FrameType = 4
+	TypeCfg3   FrameType = 5
+)
+
+func (t FrameType) String() string {
+	switch t {
+	case TypeData:
+		return "DATA"
+	case TypeHeader:
+		return "HEADER"
+	case TypeCfg1:
+		return "CFG-1"
+	case TypeCfg2:
+		return "CFG-2"
+	case TypeCmd:
+		return "CMD"
+	case TypeCfg3:
+		return "CFG-3"
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", uint8(t))
+}
+
+// Header is the common frame header shared by every C37.118 frame type.
+type Header struct {
+	Sync      uint16
+	Type      FrameType
+	Version   uint8
+	FrameSize uint16
+	IDCode    uint16
+	SOC       uint32
+	FracSec   uint32 // lower 24 bits: fraction of second; upper byte: time quality
+}
+
+// CommonHeaderSize is the byte length of the common frame header.
+const CommonHeaderSize = 14
+
+// DecodeHeader parses the common 14-byte header from the start of data.
+func DecodeHeader(data []byte) (*Header, error) {
+	if len(data) < CommonHeaderSize {
+		return nil, fmt.Errorf("frame too short for header: %d bytes", len(data))
+	}
+	sync := binary.BigEndian.Uint16(data[0:2])
+	h := &Header{
+		Sync:      sync,
+		Type:      FrameType((sync >> 4) & 0x7),
+		Version:   uint8(sync & 0xF),
+		FrameSize: binary.BigEndian.Uint16(data[2:4]),
+		IDCode:    binary.BigEndian.Uint16(data[4:6]),
+		SOC:       binary.BigEndian.Uint32(data[6:10]),
+		FracSec:   binary.BigEndian.Uint32(data[10:14]),
+	}
+	return h, nil
+}
+
+// Encode serializes the common header into the first 14 bytes of buf.
+func (h *Header) Encode(buf []byte) {
+	binary.BigEndian.PutUint16(buf[0:2], uint16(SyncLead)<<8|uint16(h.Type)<<4|uint16(h.Version))
+	binary.BigEndian.PutUint16(buf[2:4], h.FrameSize)
+	binary.BigEndian.PutUint16(buf[4:6], h.IDCode)
+	binary.BigEndian.PutUint32(buf[6:10], h.SOC)
+	binary.BigEndian.PutUint32(buf[10:14], h.FracSec)
+}
+
+// FracSecValue returns the fraction-of-second count (lower 24 bits).
+func (h *Header) FracSecValue() uint32 {
+	return h.FracSec & 0x00FFFFFF
+}
+
+// TimeQuality returns the message time quality byte (upper 8 bits of FRACSEC).
+func (h *Header) TimeQuality() uint8 {
+	return uint8(h.FracSec >> 24)
+}
+
+// LeapSecondPending reports bit 28 of FRACSEC: a leap second is coming
+// within the next 60 s. It clears in the second after the event.
+func (h *Header) LeapSecondPending() bool {
+	return h.FracSec&0x10000000 != 0
+}
+
+// LeapSecondOccurred reports bit 29 of FRACSEC, set at the start of a
+// leap second event and held for up to 24 h afterwards.
+func (h *Header) LeapSecondOccurred() bool {
+	return h.FracSec&0x20000000 != 0
+}
+
+// LeapSecondDelete reports bit 30 of FRACSEC: the leap second event is a
+// deletion rather than the usual insertion.
+func (h *Header) LeapSecondDelete() bool {
+	return h.FracSec&0x40000000 != 0
+}
+
+// TimeQualityInfo is the decoded message time-quality byte from the upper
+// 8 bits of FRACSEC: the three leap-second flags and the 4-bit time
+// quality indicator code.
+type TimeQualityInfo struct {
+	LeapPending  bool
+	LeapOccurred bool
+	LeapDelete   bool
+	Code         uint8 // 0: clock locked, 1-11: growing max error, 15: fault
+}
+
+// TimeQualityInfo decodes the message time-quality byte.
+func (h *Header) TimeQualityInfo() TimeQualityInfo {
+	return TimeQualityInfo{
+		LeapPending:  h.LeapSecondPending(),
+		LeapOccurred: h.LeapSecondOccurred(),
+		LeapDelete:   h.LeapSecondDelete(),
+		Code:         h.TimeQuality() & 0x0F,
+	}
+}
+
+// Encode returns the time-quality byte for q.
+func (q TimeQualityInfo) Encode() uint8 {
+	b := q.Code & 0x0F
+	if q.LeapPending {
+		b |= 0x10
+	}
+	if q.LeapOccurred {
+		b |= 0x20
+	}
+	if q.LeapDelete {
+		b |= 0x40
+	}
+	return b
+}
+
+// MaxError returns the maximum time error the indicator code guarantees:
+// code n bounds the error below 10^(n-10) seconds. Zero means the clock
+// is locked; a fault code (or a reserved one) returns a negative duration
+// meaning the error is unbounded.
+func (q TimeQualityInfo) MaxError() time.Duration {
+	if q.Code == 0 {
+		return 0
+	}
+	if q.Code > 11 {
+		return -1
+	}
+	err := time.Nanosecond
+	for n := q.Code; n > 1; n-- {
+		err *= 10
+	}
+	return err
+}
+
+// SetTimeQuality regenerates the upper FRACSEC byte from q, preserving
+// the fraction-of-second count.
+func (h *Header) SetTimeQuality(q TimeQualityInfo) {
+	h.FracSec = h.FracSec&0x00FFFFFF | uint32(q.Encode())<<24
+}
+
+// Timestamp converts SOC and FRACSEC into a time.Time using the given
+// TIME_BASE from the active configuration frame. An inserted leap second
+// arrives as a repeat of 23:59:59 with the occurred bit already set while
+// the pending bit has not cleared yet; those frames are pushed into the
+// next second so the converted series stays monotonic. A deleted leap
+// second needs no correction since Unix time skips along with UTC.
+func (h *Header) Timestamp(timeBase uint32) time.Time {
+	if timeBase == 0 {
+		timeBase = 1000000
+	}
+	nanos := int64(h.FracSecValue()) * int64(time.Second) / int64(timeBase)
+	t := time.Unix(int64(h.SOC), nanos).UTC()
+	if h.LeapSecondOccurred() && h.LeapSecondPending() && !h.LeapSecondDelete() {
+		t = t.Add(time.Second)
+	}
+	return t
+}
@@ -0,0 +1,96 @@
+package c37
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHeaderEncodeDecode(t *testing.T) {
+	h := Header{
+		Type:      TypeCfg3,
+		Version:   Version2011,
+		FrameSize: 512,
+		IDCode:    1234,
+		SOC:       1_700_000_000,
+		FracSec:   0x15<<24 | 123456,
+	}
+	buf := make([]byte, CommonHeaderSize)
+	h.Encode(buf)
+	got, err := DecodeHeader(buf)
+	if err != nil {
+		t.Fatalf("DecodeHeader: %v", err)
+	}
+	if got.Type != h.Type || got.Version != h.Version || got.FrameSize != h.FrameSize ||
+		got.IDCode != h.IDCode || got.SOC != h.SOC || got.FracSec != h.FracSec {
+		t.Errorf("round trip mismatch: got %+v, want %+v", got, h)
+	}
+	if got.Sync>>8 != SyncLead {
+		t.Errorf("SYNC lead byte = 0x%02X, want 0x%02X", got.Sync>>8, SyncLead)
+	}
+	if got.FracSecValue() != 123456 {
+		t.Errorf("FracSecValue = %d, want 123456", got.FracSecValue())
+	}
+	if !got.LeapSecondPending() {
+		t.Error("LeapSecondPending not set")
+	}
+
+	if _, err := DecodeHeader(buf[:CommonHeaderSize-1]); err == nil {
+		t.Error("DecodeHeader accepted a truncated header")
+	}
+}
+
+func TestTimeQualityRoundTrip(t *testing.T) {
+	tests := []TimeQualityInfo{
+		{},
+		{Code: 4},
+		{LeapPending: true, Code: 6},
+		{LeapOccurred: true, LeapDelete: true, Code: 11},
+		{LeapPending: true, LeapOccurred: true, LeapDelete: true, Code: 15},
+	}
+	for _, q := range tests {
+		var h Header
+		h.FracSec = 0x00ABCDEF
+		h.SetTimeQuality(q)
+		if h.FracSecValue() != 0x00ABCDEF {
+			t.Errorf("SetTimeQuality(%+v) clobbered FracSec: %06X", q, h.FracSecValue())
+		}
+		if got := h.TimeQualityInfo(); got != q {
+			t.Errorf("round trip mismatch: got %+v, want %+v", got, q)
+		}
+	}
+}
+
+func TestTimeQualityMaxError(t *testing.T) {
+	tests := []struct {
+		code uint8
+		want time.Duration
+	}{
+		{0, 0},
+		{1, time.Nanosecond},
+		{4, time.Microsecond},
+		{7, time.Millisecond},
+		{10, time.Second},
+		{11, 10 * time.Second},
+		{12, -1},
+		{15, -1},
+	}
+	for _, tt := range tests {
+		if got := (TimeQualityInfo{Code: tt.code}).MaxError(); got != tt.want {
+			t.Errorf("MaxError(code %d) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestHeaderTimestamp(t *testing.T) {
+	h := Header{SOC: 1_700_000_000, FracSec: 500_000}
+	want := time.Unix(1_700_000_000, 500_000_000).UTC()
+	if got := h.Timestamp(1_000_000); !got.Equal(want) {
+		t.Errorf("Timestamp = %v, want %v", got, want)
+	}
+	// Inserted leap second: occurred and pending both set pushes the frame
+	// into the next second.
+	h.FracSec |= 0x30000000
+	if got := h.Timestamp(1_000_000); !got.Equal(want.Add(time.Second)) {
+		t.Errorf("leap-second Timestamp = %v, want %v", got, want.Add(time.Second))
+	}
+}
@@ -0,0 +1,170 @@
+package c37
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// IEEE 1344-1995 legacy frame support. 1344 frames carry neither a SYNC
+// byte nor an IDCODE: they open directly with SOC followed by a sample
+// count word whose top three bits hold the frame type. The decoders here
+// translate them into the C37.118 model so the rest of the pipeline stays
+// version-agnostic. The CRC is the same CRC-CCITT as C37.118.
+
+// IEEE 1344 frame types, from bits 15-13 of the sample count word.
+const (
+	Type1344Data   = 0
+	Type1344Header = 1
+	Type1344Cfg    = 2
+)
+
+// Classify1344 returns the 1344 frame type carried in the sample count
+// word.
+func Classify1344(data []byte) (int, error) {
+	if len(data) < 8 {
+		return 0, fmt.Errorf("1344 frame too short: %d bytes", len(data))
+	}
+	return int(data[4] >> 5), nil
+}
+
+// Decode1344Config parses a 1344 configuration frame into a CFG-2. The
+// caller supplies the IDCODE, since 1344 links identify the PMU by the
+// circuit rather than in-band. Phasors are 16-bit rectangular and FREQ is
+// a 16-bit deviation, so the synthesized FORMAT is all-integer.
+func Decode1344Config(data []byte, idCode uint16) (*CfgFrame2, error) {
+	if err := VerifyCRC(data); err != nil {
+		return nil, fmt.Errorf("1344 config frame: %w", err)
+	}
+	pc := PMUConfig{IDCode: idCode}
+	off := 6
+	need := func(n int) error {
+		if len(data) < off+n+2 { // +2: trailing CRC
+			return fmt.Errorf("1344 config frame truncated at offset %d", off)
+		}
+		return nil
+	}
+	if err := need(20); err != nil {
+		return nil, err
+	}
+	pc.StationName = decodeName16(data[off : off+16])
+	off += 16
+	pc.NumPhasors = binary.BigEndian.Uint16(data[off : off+2])
+	pc.NumDigitals = binary.BigEndian.Uint16(data[off+2 : off+4])
+	off += 4
+	if err := need(16*int(pc.NumPhasors) + 16*int(pc.NumDigitals)); err != nil {
+		return nil, err
+	}
+	for i := 0; i < int(pc.NumPhasors); i++ {
+		pc.PhasorNames = append(pc.PhasorNames, decodeName16(data[off:off+16]))
+		off += 16
+	}
+	// 1344 names one 16-char label per digital word, against 16 per word
+	// in C37.118; the word label is replicated across its bits.
+	for i := 0; i < int(pc.NumDigitals); i++ {
+		name := decodeName16(data[off : off+16])
+		off += 16
+		for b := 0; b < 16; b++ {
+			pc.DigitalNames = append(pc.DigitalNames, name)
+		}
+	}
+	if err := need(4*int(pc.NumPhasors) + 4*int(pc.NumDigitals) + 4); err != nil {
+		return nil, err
+	}
+	for i := 0; i < int(pc.NumPhasors); i++ {
+		pc.PhasorUnits = append(pc.PhasorUnits, PhasorUnit{
+			IsCurrent: data[off] == 1,
+			Factor:    binary.BigEndian.Uint32(data[off:off+4]) & 0xFFFFFF,
+		})
+		off += 4
+	}
+	for i := 0; i < int(pc.NumDigitals); i++ {
+		pc.DigitalUnits = append(pc.DigitalUnits, DigitalUnit{
+			Normal: binary.BigEndian.Uint16(data[off : off+2]),
+			Valid:  binary.BigEndian.Uint16(data[off+2 : off+4]),
+		})
+		off += 4
+	}
+	pc.FNom = binary.BigEndian.Uint16(data[off:off+2]) & 1
+	rate := int16(binary.BigEndian.Uint16(data[off+2 : off+4]))
+	return &CfgFrame2{
+		Header: Header{
+			Type:    TypeCfg2,
+			Version: Version2011,
+			IDCode:  idCode,
+			SOC:     binary.BigEndian.Uint32(data[0:4]),
+		},
+		TimeBase: 1_000_000,
+		NumPMU:   1,
+		PMUs:     []PMUConfig{pc},
+		DataRate: rate,
+	}, nil
+}
+
+// Decode1344Data parses a 1344 data frame using a configuration produced
+// by Decode1344Config. FRACSEC is reconstructed from the sample count and
+// the data rate.
+func Decode1344Data(data []byte, cfg *CfgFrame2) (*DataFrame, error) {
+	if err := VerifyCRC(data); err != nil {
+		return nil, fmt.Errorf("1344 data frame: %w", err)
+	}
+	if len(cfg.PMUs) != 1 {
+		return nil, fmt.Errorf("1344 data frame needs a single-PMU configuration, have %d", len(cfg.PMUs))
+	}
+	pc := &cfg.PMUs[0]
+	need := 6 + 2 + 4*int(pc.NumPhasors) + 2 + 2*int(pc.NumDigitals) + 2
+	if len(data) < need {
+		return nil, fmt.Errorf("1344 data frame too short: %d bytes, configuration requires %d", len(data), need)
+	}
+	smpCnt := binary.BigEndian.Uint16(data[4:6]) & 0x1FFF
+	var fracSec uint32
+	if cfg.DataRate > 0 {
+		fracSec = uint32(uint64(smpCnt) * uint64(cfg.TimeBase) / uint64(cfg.DataRate))
+	}
+	df := &DataFrame{Header: Header{
+		Type:    TypeData,
+		Version: Version2011,
+		IDCode:  cfg.Header.IDCode,
+		SOC:     binary.BigEndian.Uint32(data[0:4]),
+		FracSec: fracSec,
+	}}
+	off := 6
+	pd := PMUData{Stat: stat1344(binary.BigEndian.Uint16(data[off : off+2]))}
+	off += 2
+	for j := 0; j < int(pc.NumPhasors); j++ {
+		re := float64(int16(binary.BigEndian.Uint16(data[off : off+2])))
+		im := float64(int16(binary.BigEndian.Uint16(data[off+2 : off+4])))
+		off += 4
+		var scale float64 = 1
+		if j < len(pc.PhasorUnits) && pc.PhasorUnits[j].Factor != 0 {
+			scale = float64(pc.PhasorUnits[j].Factor) * 1e-5
+		}
+		pd.Phasors = append(pd.Phasors, Phasor{
+			Magnitude: math.Hypot(re, im) * scale,
+			Angle:     math.Atan2(im, re),
+		})
+	}
+	dev := float64(int16(binary.BigEndian.Uint16(data[off : off+2])))
+	pd.Freq = pc.NominalFreq() + dev/1000
+	off += 2
+	for j := 0; j < int(pc.NumDigitals); j++ {
+		pd.Digitals = append(pd.Digitals, binary.BigEndian.Uint16(data[off:off+2]))
+		off += 2
+	}
+	df.PMUs = append(df.PMUs, pd)
+	return df, nil
+}
+
+// stat1344 translates a 1344 STAT word into the C37.118 model. Only the
+// trigger and sync-lost bits have direct equivalents; the remaining 1344
+// status bits have no C37 meaning and are dropped.
+func stat1344(w uint16) Stat {
+	var raw uint16
+	if w&0x8000 != 0 { // trigger detected
+		raw |= 0x0800
+	}
+	if w&0x2000 != 0 { // time synchronization lost
+		raw |= 0x2000
+	}
+	return DecodeStat(raw)
+}
@@ -0,0 +1,83 @@
+package c37
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// build1344Config assembles a minimal 1344 configuration frame with one
+// phasor and one digital word.
+func build1344Config() []byte {
+	buf := binary.BigEndian.AppendUint32(nil, 1_700_000_000)          // SOC
+	buf = binary.BigEndian.AppendUint16(buf, uint16(Type1344Cfg)<<13) // sample count word
+	name := make([]byte, 16)
+	encodeName16(name, "LEGACY PMU")
+	buf = append(buf, name...)
+	buf = binary.BigEndian.AppendUint16(buf, 1) // phasors
+	buf = binary.BigEndian.AppendUint16(buf, 1) // digitals
+	encodeName16(name, "VA")
+	buf = append(buf, name...)
+	encodeName16(name, "STATUS")
+	buf = append(buf, name...)
+	buf = binary.BigEndian.AppendUint32(buf, 100000) // PHUNIT: voltage, factor 100000
+	buf = binary.BigEndian.AppendUint16(buf, 0x0001) // DIGUNIT normal
+	buf = binary.BigEndian.AppendUint16(buf, 0xFFFF) // DIGUNIT valid
+	buf = binary.BigEndian.AppendUint16(buf, 1)      // FNOM: 50 Hz
+	buf = binary.BigEndian.AppendUint16(buf, 30)     // data rate
+	return AppendCRC(buf)
+}
+
+func TestDecode1344Config(t *testing.T) {
+	frame := build1344Config()
+	if typ, err := Classify1344(frame); err != nil || typ != Type1344Cfg {
+		t.Fatalf("Classify1344 = %d, %v, want %d", typ, err, Type1344Cfg)
+	}
+	cfg, err := Decode1344Config(frame, 42)
+	if err != nil {
+		t.Fatalf("Decode1344Config: %v", err)
+	}
+	if cfg.Header.IDCode != 42 || cfg.Header.SOC != 1_700_000_000 || cfg.Header.Type != TypeCfg2 {
+		t.Errorf("header = %+v, want IDCODE 42, SOC 1700000000, CFG-2", cfg.Header)
+	}
+	if cfg.TimeBase != 1_000_000 || cfg.NumPMU != 1 || cfg.DataRate != 30 {
+		t.Errorf("frame fields = %d/%d/%d, want 1000000/1/30", cfg.TimeBase, cfg.NumPMU, cfg.DataRate)
+	}
+	pc := &cfg.PMUs[0]
+	if pc.StationName != "LEGACY PMU" || pc.IDCode != 42 {
+		t.Errorf("PMU identity = %q/%d, want %q/42", pc.StationName, pc.IDCode, "LEGACY PMU")
+	}
+	if pc.NumPhasors != 1 || pc.NumDigitals != 1 || pc.NumAnalogs != 0 {
+		t.Errorf("channel counts = %d/%d/%d, want 1/0/1", pc.NumPhasors, pc.NumAnalogs, pc.NumDigitals)
+	}
+	if pc.Format != (Format{}) {
+		t.Errorf("synthesized FORMAT = %+v, want all-integer rectangular", pc.Format)
+	}
+	if pc.PhasorNames[0] != "VA" {
+		t.Errorf("phasor name = %q, want VA", pc.PhasorNames[0])
+	}
+	// The single 1344 word label is replicated across all 16 bits.
+	if len(pc.DigitalNames) != 16 || pc.DigitalNames[0] != "STATUS" || pc.DigitalNames[15] != "STATUS" {
+		t.Errorf("digital names = %v, want 16 x STATUS", pc.DigitalNames)
+	}
+	if pc.PhasorUnits[0] != (PhasorUnit{Factor: 100000}) {
+		t.Errorf("PHUNIT = %+v, want voltage factor 100000", pc.PhasorUnits[0])
+	}
+	if pc.DigitalUnits[0] != (DigitalUnit{Normal: 0x0001, Valid: 0xFFFF}) {
+		t.Errorf("DIGUNIT = %+v", pc.DigitalUnits[0])
+	}
+	if pc.NominalFreq() != 50 {
+		t.Errorf("NominalFreq = %v, want 50", pc.NominalFreq())
+	}
+}
+
+func TestDecode1344ConfigErrors(t *testing.T) {
+	frame := build1344Config()
+	frame[6] ^= 0x01
+	if _, err := Decode1344Config(frame, 42); err == nil {
+		t.Error("Decode1344Config accepted a frame with a bad CRC")
+	}
+	short := AppendCRC(append([]byte(nil), build1344Config()[:20]...))
+	if _, err := Decode1344Config(short, 42); err == nil {
+		t.Error("Decode1344Config accepted a truncated frame")
+	}
+}
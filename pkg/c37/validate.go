@@ -0,0 +1,95 @@
+package c37
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Violation identifies one class of frame non-conformance found by the
+// validators.
+type Violation string
+
+const (
+	ViolationSyncByte     Violation = "sync_byte"     // leading byte is not 0xAA
+	ViolationReservedBits Violation = "reserved_bits" // reserved bits in SYNC or FRACSEC are set
+	ViolationFrameSize    Violation = "frame_size"    // FRAMESIZE does not match the received length
+	ViolationFrameType    Violation = "frame_type"    // unassigned frame type code
+	ViolationChannelCount Violation = "channel_count" // NUM_PH/AN/DG outside sane limits
+	ViolationCRC          Violation = "crc"           // CHK field mismatch
+)
+
+// Sane upper bounds for the per-PMU channel counts. The standard's fields
+// are 16-bit, but real devices stay far below these; anything larger is
+// treated as a malformed or hostile frame.
+const (
+	MaxPhasors  = 256
+	MaxAnalogs  = 256
+	MaxDigitals = 64
+	MaxPMUs     = 128
+)
+
+// ValidateFrame checks the common header of a received frame against the
+// standard: SYNC leading byte, reserved bits, declared FRAMESIZE and CRC.
+// It returns all violations found; an empty slice means the envelope
+// conforms.
+func ValidateFrame(data []byte) []Violation {
+	var v []Violation
+	if len(data) < CommonHeaderSize+2 {
+		return append(v, ViolationFrameSize)
+	}
+	if data[0] != SyncLead {
+		v = append(v, ViolationSyncByte)
+	}
+	// Bit 7 of the SYNC type byte and bit 31 of FRACSEC are reserved.
+	if data[1]&0x80 != 0 || data[10]&0x80 != 0 {
+		v = append(v, ViolationReservedBits)
+	}
+	if t := FrameType(data[1] >> 4 & 0x7); t > TypeCfg3 {
+		v = append(v, ViolationFrameType)
+	}
+	if int(binary.BigEndian.Uint16(data[2:4])) != len(data) {
+		v = append(v, ViolationFrameSize)
+	}
+	if VerifyCRC(data) != nil {
+		v = append(v, ViolationCRC)
+	}
+	return v
+}
+
+// Validate checks the decoded configuration for channel counts a sane
+// device would report.
+func (c *CfgFrame2) Validate() []Violation {
+	var v []Violation
+	for i := range c.PMUs {
+		pc := &c.PMUs[i]
+		if pc.NumPhasors > MaxPhasors || pc.NumAnalogs > MaxAnalogs || pc.NumDigitals > MaxDigitals {
+			v = append(v, ViolationChannelCount)
+		}
+	}
+	return v
+}
+
+// Validate checks the decoded CFG-3 for channel counts a sane device
+// would report.
+func (c *CfgFrame3) Validate() []Violation {
+	var v []Violation
+	for i := range c.PMUs {
+		pc := &c.PMUs[i]
+		if pc.NumPhasors > MaxPhasors || pc.NumAnalogs > MaxAnalogs || pc.NumDigitals > MaxDigitals {
+			v = append(v, ViolationChannelCount)
+		}
+	}
+	return v
+}
+
+func (v Violation) String() string {
+	return string(v)
+}
+
+// ViolationError wraps violations into an error for strict-mode rejection.
+func ViolationError(vs []Violation) error {
+	if len(vs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("frame violates C37.118 conformance: %v", vs)
+}
@@ -0,0 +1,159 @@
+package pipeline
+
+import (
+	"encoding/binary"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// outputConfig rewrites a received configuration for the output stream:
+// DATA_RATE is replaced with the reduced rate and the IDCODE and station
+// overrides are applied.
+func outputConfig(cfg *c37.CfgFrame2, outRate int) *c37.CfgFrame2 {
+	out := *cfg
+	if outRate != 0 {
+		out.DataRate = int16(outRate)
+	}
+	if OutputIDCode != 0 || OutputStation != "" {
+		out.PMUs = append([]c37.PMUConfig(nil), out.PMUs...)
+	}
+	if OutputIDCode != 0 {
+		out.Header.IDCode = OutputIDCode
+		// A single-PMU stream also carries the device ID in its one PMU
+		// block; rewrite it too so CFG and data stay consistent.
+		if len(out.PMUs) == 1 {
+			out.PMUs[0].IDCode = OutputIDCode
+		}
+	}
+	if OutputStation != "" {
+		for i := range out.PMUs {
+			out.PMUs[i].StationName = OutputStation
+		}
+	}
+	stampFixedTQ(&out.Header)
+	return &out
+}
+
+// ConvertConfigurationFrame rewrites a received configuration frame for the
+// output stream: DATA_RATE is replaced with the reduced rate and the frame
+// is re-encoded with a fresh CRC. Streams with a quirk profile get their
+// vendor's trailing padding appended after the CRC.
+func ConvertConfigurationFrame(cfg *c37.CfgFrame2, outRate int) []byte {
+	frame := c37.EncodeConfigFrame2(outputConfig(cfg, outRate))
+	return append(frame, cfgPadding(cfg.Header.IDCode)...)
+}
+
+// BuildAggregatedConfigFrame merges the PMU blocks of several source
+// configuration frames into one multi-PMU configuration describing the
+// aggregated output stream.
+func BuildAggregatedConfigFrame(cfgs []*c37.CfgFrame2, outRate int) []byte {
+	if len(cfgs) == 0 {
+		return nil
+	}
+	buf := make([]byte, c37.CommonHeaderSize, 512)
+	buf = binary.BigEndian.AppendUint32(buf, cfgs[0].TimeBase)
+	numPMU := 0
+	for _, c := range cfgs {
+		numPMU += len(c.PMUs)
+	}
+	buf = binary.BigEndian.AppendUint16(buf, uint16(numPMU))
+	for _, c := range cfgs {
+		for i := range c.PMUs {
+			buf = c37.AppendPMUConfig(buf, &c.PMUs[i])
+		}
+	}
+	buf = binary.BigEndian.AppendUint16(buf, uint16(int16(outRate)))
+	h := cfgs[0].Header
+	if OutputIDCode != 0 {
+		h.IDCode = OutputIDCode
+	}
+	stampFixedTQ(&h)
+	h.Type = c37.TypeCfg2
+	h.FrameSize = uint16(len(buf) + 2)
+	h.Encode(buf)
+	return c37.AppendCRC(buf)
+}
+
+// StatMerge selects how the STAT words of an aggregated frame are
+// derived: "keep" leaves each source PMU's STAT as received, "or"
+// bitwise-ORs all source STAT words into every block, and "worst" ORs the
+// flag bits but takes the worst value of the multi-bit data-error,
+// time-quality and unlock-time fields.
+var StatMerge = "keep"
+
+// BuildAggregatedDataFrame concatenates the PMU blocks of data frames that
+// share one timestamp into a single multi-PMU data frame. The raw PMU
+// blocks are copied verbatim, so the matching aggregated configuration must
+// list the PMUs in the same order the frames were received. With a STAT
+// merge policy other than "keep", the combined STAT replaces each block's
+// own word.
+func BuildAggregatedDataFrame(dfs []*c37.DataFrame) []byte {
+	if len(dfs) == 0 {
+		return nil
+	}
+	buf := make([]byte, c37.CommonHeaderSize, 256)
+	for _, df := range dfs {
+		// PMU blocks sit between the common header and the trailing CRC.
+		buf = append(buf, df.Raw[c37.CommonHeaderSize:len(df.Raw)-2]...)
+	}
+	if StatMerge != "keep" {
+		applyMergedStat(buf, dfs)
+	}
+	h := dfs[0].Header
+	if OutputIDCode != 0 {
+		h.IDCode = OutputIDCode
+	}
+	if tqPolicy == tqWorst {
+		h.FracSec = h.FracSec&0x00FFFFFF | uint32(aggregateTQ(dfs))<<24
+	}
+	stampFixedTQ(&h)
+	h.FrameSize = uint16(len(buf) + 2)
+	h.Encode(buf)
+	return c37.AppendCRC(buf)
+}
+
+// aggregateStat derives the combined STAT word per the StatMerge policy.
+func aggregateStat(dfs []*c37.DataFrame) uint16 {
+	var or, dataErr, tq, unlocked uint16
+	for _, df := range dfs {
+		for i := range df.PMUs {
+			w := df.PMUs[i].Stat.Raw
+			or |= w
+			if e := w >> 14; e > dataErr {
+				dataErr = e
+			}
+			if q := w >> 6 & 0x7; q > tq {
+				tq = q
+			}
+			if u := w >> 4 & 0x3; u > unlocked {
+				unlocked = u
+			}
+		}
+	}
+	if StatMerge == "or" {
+		return or
+	}
+	// "worst": single-bit flags keep the OR, the data-error, time-quality
+	// and unlock-time fields take their worst value across the sources.
+	return or&0x3E0F | dataErr<<14 | tq<<6 | unlocked<<4
+}
+
+// applyMergedStat overwrites the STAT word of every PMU block in the
+// aggregated payload with the combined value. Block offsets come from the
+// source streams' configurations; a stream without one keeps its blocks
+// untouched since their layout is unknown.
+func applyMergedStat(buf []byte, dfs []*c37.DataFrame) {
+	stat := aggregateStat(dfs)
+	off := c37.CommonHeaderSize
+	for _, df := range dfs {
+		st := streamFor(df.Header.IDCode)
+		if st.Cfg == nil || len(st.Cfg.PMUs) != len(df.PMUs) {
+			off += len(df.Raw) - c37.CommonHeaderSize - 2
+			continue
+		}
+		for i := range st.Cfg.PMUs {
+			binary.BigEndian.PutUint16(buf[off:off+2], stat)
+			off += st.Cfg.PMUs[i].DataBlockSize()
+		}
+	}
+}
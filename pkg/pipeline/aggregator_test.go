@@ -0,0 +1,156 @@
+package pipeline
+
+import (
+	"math"
+	"testing"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// aggTestFrame builds one decoded single-PMU frame with Raw populated,
+// the shape bufferFrame hands to the aggregator.
+func aggTestFrame(t *testing.T, id uint16, cfg *c37.CfgFrame2, stat uint16, mag float64) *c37.DataFrame {
+	t.Helper()
+	df := &c37.DataFrame{
+		Header: c37.Header{Version: c37.Version2011, IDCode: id, SOC: 1_700_000_000, FracSec: 1000},
+		PMUs: []c37.PMUData{{
+			Stat:    c37.DecodeStat(stat),
+			Phasors: []c37.Phasor{{Magnitude: mag, Angle: 0.1}, {Magnitude: mag / 100, Angle: -0.2}},
+			Freq:    50.01,
+		}},
+	}
+	out, err := c37.DecodeDataFrame(c37.EncodeDataFrame(df, cfg), cfg)
+	if err != nil {
+		t.Fatalf("building frame for IDCODE %d: %v", id, err)
+	}
+	return out
+}
+
+// mergedTestCfg is the multi-PMU configuration describing the aggregate
+// of the given single-PMU configurations, in arrival order.
+func mergedTestCfg(cfgs ...*c37.CfgFrame2) *c37.CfgFrame2 {
+	out := *cfgs[0]
+	out.PMUs = nil
+	for _, c := range cfgs {
+		out.PMUs = append(out.PMUs, c.PMUs...)
+	}
+	out.NumPMU = uint16(len(out.PMUs))
+	return &out
+}
+
+func TestBuildAggregatedDataFrame(t *testing.T) {
+	defer func() { OutputIDCode = 0 }()
+	cfgA, cfgB := floatTestCfg(1), floatTestCfg(2)
+	dfA := aggTestFrame(t, 1, cfgA, 0x0000, 230000)
+	dfB := aggTestFrame(t, 2, cfgB, 0x4800, 115000) // PMU error, trigger
+
+	out := BuildAggregatedDataFrame([]*c37.DataFrame{dfA, dfB})
+	if err := c37.VerifyCRC(out); err != nil {
+		t.Fatalf("aggregated frame: %v", err)
+	}
+	agg, err := c37.DecodeDataFrame(out, mergedTestCfg(cfgA, cfgB))
+	if err != nil {
+		t.Fatalf("decoding aggregated frame: %v", err)
+	}
+	if agg.Header.IDCode != 1 || agg.Header.SOC != dfA.Header.SOC {
+		t.Errorf("header = %+v, want the first source's IDCODE and timestamp", agg.Header)
+	}
+	if int(agg.Header.FrameSize) != len(out) {
+		t.Errorf("FRAMESIZE %d does not match %d encoded bytes", agg.Header.FrameSize, len(out))
+	}
+	if len(agg.PMUs) != 2 {
+		t.Fatalf("aggregated frame has %d PMU blocks, want 2", len(agg.PMUs))
+	}
+	if agg.PMUs[0].Stat.Raw != 0x0000 || agg.PMUs[1].Stat.Raw != 0x4800 {
+		t.Errorf("STAT words = 0x%04X/0x%04X, want the sources' own (keep policy)",
+			agg.PMUs[0].Stat.Raw, agg.PMUs[1].Stat.Raw)
+	}
+	if math.Abs(agg.PMUs[0].Phasors[0].Magnitude-230000) > 1 ||
+		math.Abs(agg.PMUs[1].Phasors[0].Magnitude-115000) > 1 {
+		t.Errorf("magnitudes = %v/%v, want 230000/115000",
+			agg.PMUs[0].Phasors[0].Magnitude, agg.PMUs[1].Phasors[0].Magnitude)
+	}
+
+	OutputIDCode = 99
+	out = BuildAggregatedDataFrame([]*c37.DataFrame{dfA, dfB})
+	if h, _ := c37.DecodeHeader(out); h.IDCode != 99 {
+		t.Errorf("aggregated IDCODE = %d, want the 99 override", h.IDCode)
+	}
+
+	if BuildAggregatedDataFrame(nil) != nil {
+		t.Error("aggregating zero frames did not return nil")
+	}
+}
+
+func TestBuildAggregatedConfigFrame(t *testing.T) {
+	cfgA, cfgB := floatTestCfg(1), floatTestCfg(2)
+	pc := &cfgB.PMUs[0]
+	pc.NumAnalogs = 1
+	pc.AnalogNames = []string{"SETPOINT"}
+	pc.AnalogUnits = []c37.AnalogUnit{{Type: 1, Factor: -5}}
+
+	out := BuildAggregatedConfigFrame([]*c37.CfgFrame2{cfgA, cfgB}, 10)
+	dec, err := c37.DecodeConfigFrame2(out)
+	if err != nil {
+		t.Fatalf("decoding aggregated config: %v", err)
+	}
+	if dec.NumPMU != 2 || dec.DataRate != 10 || dec.TimeBase != cfgA.TimeBase {
+		t.Errorf("frame fields = %d/%d/%d, want 2 PMUs, rate 10, TIME_BASE %d",
+			dec.NumPMU, dec.DataRate, dec.TimeBase, cfgA.TimeBase)
+	}
+	if dec.PMUs[0].StationName != "FLOAT PMU" || dec.PMUs[1].IDCode != 2 {
+		t.Errorf("PMU blocks lost their identity: %q / IDCODE %d",
+			dec.PMUs[0].StationName, dec.PMUs[1].IDCode)
+	}
+	// PHUNIT and ANUNIT entries survive the one-type-byte-plus-24-bit
+	// packing, including the voltage/current flag and negative factors.
+	if !dec.PMUs[1].PhasorUnits[1].IsCurrent || dec.PMUs[1].PhasorUnits[0].IsCurrent {
+		t.Errorf("PHUNIT flags = %+v, want voltage then current", dec.PMUs[1].PhasorUnits)
+	}
+	if dec.PMUs[1].AnalogUnits[0] != (c37.AnalogUnit{Type: 1, Factor: -5}) {
+		t.Errorf("ANUNIT = %+v, want RMS with factor -5", dec.PMUs[1].AnalogUnits[0])
+	}
+
+	if BuildAggregatedConfigFrame(nil, 10) != nil {
+		t.Error("aggregating zero configs did not return nil")
+	}
+}
+
+func TestAggregateStat(t *testing.T) {
+	dfs := []*c37.DataFrame{
+		{PMUs: []c37.PMUData{{Stat: c37.DecodeStat(0x4000)}}}, // data error 1
+		{PMUs: []c37.PMUData{{Stat: c37.DecodeStat(0x8010)}}}, // data error 2, unlocked <100s
+	}
+	defer func() { StatMerge = "keep" }()
+	StatMerge = "or"
+	if got := aggregateStat(dfs); got != 0xC010 {
+		t.Errorf("or-merged STAT = 0x%04X, want 0xC010", got)
+	}
+	// "worst" must not OR the multi-bit data-error field into 3 (error):
+	// the worst source value is 2 (test data).
+	StatMerge = "worst"
+	if got := aggregateStat(dfs); got != 0x8010 {
+		t.Errorf("worst-merged STAT = 0x%04X, want 0x8010", got)
+	}
+}
+
+func TestBuildAggregatedDataFrameMergedStat(t *testing.T) {
+	defer resetStreams()
+	defer func() { StatMerge = "keep" }()
+	cfgA, cfgB := floatTestCfg(1), floatTestCfg(2)
+	streamFor(1).Cfg = cfgA
+	streamFor(2).Cfg = cfgB
+	dfA := aggTestFrame(t, 1, cfgA, 0x4000, 230000)
+	dfB := aggTestFrame(t, 2, cfgB, 0x8010, 115000)
+
+	StatMerge = "worst"
+	out := BuildAggregatedDataFrame([]*c37.DataFrame{dfA, dfB})
+	agg, err := c37.DecodeDataFrame(out, mergedTestCfg(cfgA, cfgB))
+	if err != nil {
+		t.Fatalf("decoding aggregated frame: %v", err)
+	}
+	if agg.PMUs[0].Stat.Raw != 0x8010 || agg.PMUs[1].Stat.Raw != 0x8010 {
+		t.Errorf("merged STAT words = 0x%04X/0x%04X, want 0x8010 in every block",
+			agg.PMUs[0].Stat.Raw, agg.PMUs[1].Stat.Raw)
+	}
+}
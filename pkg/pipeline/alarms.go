@@ -0,0 +1,181 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// AlarmLimits are per-PMU thresholds for the disturbance watcher. A zero
+// limit disables that check.
+type AlarmLimits struct {
+	FreqDev float64 // Hz deviation from nominal
+	ROCOF   float64 // Hz/s magnitude
+}
+
+// AlarmWebhook is POSTed a JSON alarm event on every limit violation
+// (empty disables the webhook).
+var AlarmWebhook string
+
+var (
+	alarmMu     sync.Mutex
+	alarmLimits = make(map[uint16]AlarmLimits)
+	// alarmActive tracks which alarms are currently raised so a sustained
+	// excursion logs once instead of once per frame.
+	alarmActive = make(map[string]bool)
+	alarmCounts = make(map[string]uint64)
+)
+
+// ParseAlarmLimits parses the -alarm flag: comma-separated
+// "IDCODE=freqdev:rocof" entries, e.g. "41=0.2:1.0,42=0.5:2.5". IDCODE 0
+// sets the default for streams without their own entry.
+func ParseAlarmLimits(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		id, limits, ok := strings.Cut(entry, "=")
+		if !ok {
+			return fmt.Errorf("invalid alarm entry %q, want IDCODE=freqdev:rocof", entry)
+		}
+		idCode, err := strconv.ParseUint(id, 10, 16)
+		if err != nil {
+			return fmt.Errorf("invalid IDCODE in alarm entry %q: %v", entry, err)
+		}
+		fd, rc, ok := strings.Cut(limits, ":")
+		if !ok {
+			return fmt.Errorf("invalid alarm limits %q, want freqdev:rocof", limits)
+		}
+		var lim AlarmLimits
+		if lim.FreqDev, err = strconv.ParseFloat(fd, 64); err != nil {
+			return fmt.Errorf("invalid frequency deviation in alarm entry %q: %v", entry, err)
+		}
+		if lim.ROCOF, err = strconv.ParseFloat(rc, 64); err != nil {
+			return fmt.Errorf("invalid ROCOF limit in alarm entry %q: %v", entry, err)
+		}
+		alarmLimits[uint16(idCode)] = lim
+	}
+	return nil
+}
+
+// AlarmCounts returns a snapshot of the per-alarm trip counters, keyed
+// "IDCODE/station/kind".
+func AlarmCounts() map[string]uint64 {
+	alarmMu.Lock()
+	defer alarmMu.Unlock()
+	out := make(map[string]uint64, len(alarmCounts))
+	for k, n := range alarmCounts {
+		out[k] = n
+	}
+	return out
+}
+
+// alarmEvent is the webhook payload.
+type alarmEvent struct {
+	IDCode  uint16  `json:"idcode"`
+	Station string  `json:"station"`
+	Kind    string  `json:"kind"` // "freq" or "rocof"
+	Value   float64 `json:"value"`
+	Limit   float64 `json:"limit"`
+	SOC     uint32  `json:"soc"`
+	FracSec uint32  `json:"fracsec"`
+}
+
+// checkAlarms runs the decoded frame through the configured limits,
+// raising and clearing alarms per PMU.
+func checkAlarms(st *StreamContext, df *c37.DataFrame) {
+	alarmMu.Lock()
+	lim, ok := alarmLimits[st.IDCode]
+	if !ok {
+		lim, ok = alarmLimits[0]
+	}
+	alarmMu.Unlock()
+	if !ok {
+		return
+	}
+	for i := range df.PMUs {
+		pmu := &df.PMUs[i]
+		station := ""
+		if st.Cfg != nil && i < len(st.Cfg.PMUs) {
+			station = strings.TrimRight(st.Cfg.PMUs[i].StationName, " ")
+		}
+		if lim.FreqDev > 0 && st.Cfg != nil && i < len(st.Cfg.PMUs) {
+			dev := pmu.Freq - st.Cfg.PMUs[i].NominalFreq()
+			if dev < 0 {
+				dev = -dev
+			}
+			updateAlarm(st, df, station, "freq", pmu.Freq, dev, lim.FreqDev)
+		}
+		if lim.ROCOF > 0 {
+			mag := pmu.DFreq
+			if mag < 0 {
+				mag = -mag
+			}
+			updateAlarm(st, df, station, "rocof", pmu.DFreq, mag, lim.ROCOF)
+		}
+	}
+}
+
+// updateAlarm raises or clears one per-PMU alarm depending on whether
+// magnitude exceeds limit.
+func updateAlarm(st *StreamContext, df *c37.DataFrame, station, kind string, value, magnitude, limit float64) {
+	key := fmt.Sprintf("%d/%s/%s", st.IDCode, station, kind)
+	transitionAlarm(key, alarmEvent{
+		IDCode:  st.IDCode,
+		Station: station,
+		Kind:    kind,
+		Value:   value,
+		Limit:   limit,
+		SOC:     df.Header.SOC,
+		FracSec: df.Header.FracSecValue(),
+	}, magnitude, limit)
+}
+
+// transitionAlarm raises or clears the alarm under key, logging only on
+// state transitions and posting the webhook on a raise.
+func transitionAlarm(key string, ev alarmEvent, magnitude, limit float64) {
+	alarmMu.Lock()
+	active := alarmActive[key]
+	if magnitude > limit && !active {
+		alarmActive[key] = true
+		alarmCounts[key]++
+	} else if magnitude <= limit && active {
+		alarmActive[key] = false
+	} else {
+		alarmMu.Unlock()
+		return
+	}
+	alarmMu.Unlock()
+
+	if magnitude > limit {
+		log.Printf("ALARM %s: %.4f exceeds limit %.4f (IDCODE %d, %s)", ev.Kind, ev.Value, limit, ev.IDCode, ev.Station)
+		TriggerEvent("alarm " + ev.Kind)
+		if AlarmWebhook != "" {
+			go postAlarm(ev)
+		}
+	} else {
+		log.Printf("alarm cleared %s: %.4f back within limit %.4f (IDCODE %d, %s)", ev.Kind, ev.Value, limit, ev.IDCode, ev.Station)
+	}
+}
+
+func postAlarm(ev alarmEvent) {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Post(AlarmWebhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("alarm webhook: %v", err)
+		return
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,75 @@
+package pipeline
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+)
+
+// Allowlist restricts inbound traffic to known PMU source addresses, so
+// spoofed or stray traffic on shared networks never reaches the pipeline.
+type Allowlist struct {
+	nets []*net.IPNet
+}
+
+// ParseAllowlist parses a comma-separated list of IPs and CIDR subnets,
+// e.g. "10.1.2.3,192.168.10.0/24".
+func ParseAllowlist(spec string) (*Allowlist, error) {
+	al := &Allowlist{}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid allowlist entry %q", item)
+			}
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+			item = fmt.Sprintf("%s/%d", ip, bits)
+		}
+		_, ipnet, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowlist entry %q: %w", item, err)
+		}
+		al.nets = append(al.nets, ipnet)
+	}
+	if len(al.nets) == 0 {
+		return nil, fmt.Errorf("allowlist is empty")
+	}
+	return al, nil
+}
+
+// Allowed reports whether ip may send frames to the reductor.
+func (a *Allowlist) Allowed(ip net.IP) bool {
+	for _, n := range a.nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// SourceAllowlist, when set, is consulted for every inbound datagram.
+var SourceAllowlist *Allowlist
+
+// DeniedSourceCount counts frames dropped because their source address was
+// not on the allowlist.
+var DeniedSourceCount uint64
+
+// sourceAllowed applies the allowlist to one inbound frame's source.
+func sourceAllowed(ip net.IP) bool {
+	if SourceAllowlist == nil || ip == nil {
+		return true
+	}
+	if SourceAllowlist.Allowed(ip) {
+		return true
+	}
+	atomic.AddUint64(&DeniedSourceCount, 1)
+	return false
+}
@@ -0,0 +1,99 @@
+package pipeline
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// analogType is the meaning of one user-defined ANUNIT type code
+// (65-255): a label, an engineering unit and a multiplier applied to the
+// decoded values.
+type analogType struct {
+	name  string
+	unit  string
+	scale float64
+}
+
+var analogTypes = make(map[uint8]analogType)
+
+// ParseAnalogTypes parses the -analog_types flag: comma-separated
+// "code=name:unit[:scale]" entries, e.g. "65=POWER:MW:0.1,70=TEMP:degC".
+// The code must be in the user-defined ANUNIT range 65-255.
+func ParseAnalogTypes(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		id, def, ok := strings.Cut(entry, "=")
+		if !ok {
+			return fmt.Errorf("invalid analog type %q, want code=name:unit[:scale]", entry)
+		}
+		code, err := strconv.ParseUint(id, 10, 8)
+		if err != nil || code < 65 {
+			return fmt.Errorf("invalid analog type code in %q, want 65-255", entry)
+		}
+		parts := strings.Split(def, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("invalid analog type %q, want code=name:unit[:scale]", entry)
+		}
+		at := analogType{name: parts[0], unit: parts[1], scale: 1}
+		if len(parts) == 3 {
+			if at.scale, err = strconv.ParseFloat(parts[2], 64); err != nil || at.scale == 0 {
+				return fmt.Errorf("invalid scale in analog type %q", entry)
+			}
+		}
+		analogTypes[uint8(code)] = at
+	}
+	return nil
+}
+
+// labelAnalogChannels rewrites the names of analog channels whose ANUNIT
+// type code has a configured meaning, so the outputs carry
+// "NAME_UNIT" instead of whatever generic label the device sent.
+func labelAnalogChannels(cfg *c37.CfgFrame2) {
+	if len(analogTypes) == 0 {
+		return
+	}
+	for i := range cfg.PMUs {
+		pc := &cfg.PMUs[i]
+		for j := range pc.AnalogUnits {
+			at, ok := analogTypes[pc.AnalogUnits[j].Type]
+			if !ok || j >= len(pc.AnalogNames) {
+				continue
+			}
+			name := at.name + "_" + at.unit
+			if len(name) > 16 {
+				name = name[:16]
+			}
+			pc.AnalogNames[j] = name
+		}
+	}
+}
+
+// applyAnalogTypes scales the analog values of a decoded frame per their
+// configured type; true means the frame must be re-encoded.
+func applyAnalogTypes(st *StreamContext, df *c37.DataFrame) bool {
+	if len(analogTypes) == 0 || st.Cfg == nil {
+		return false
+	}
+	mutated := false
+	for i := range df.PMUs {
+		if i >= len(st.Cfg.PMUs) {
+			break
+		}
+		units := st.Cfg.PMUs[i].AnalogUnits
+		for j := range df.PMUs[i].Analogs {
+			if j >= len(units) {
+				break
+			}
+			if at, ok := analogTypes[units[j].Type]; ok && at.scale != 1 {
+				df.PMUs[i].Analogs[j] *= at.scale
+				mutated = true
+			}
+		}
+	}
+	return mutated
+}
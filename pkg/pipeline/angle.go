@@ -0,0 +1,162 @@
+package pipeline
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// ChannelRef addresses one phasor channel by IDCODE, PMU block index and
+// phasor index within the block.
+type ChannelRef struct {
+	IDCode uint16
+	PMU    int
+	Phasor int
+}
+
+// String returns the flag form of the reference, "IDCODE:pmu:phasor".
+func (r ChannelRef) String() string {
+	return fmt.Sprintf("%d:%d:%d", r.IDCode, r.PMU, r.Phasor)
+}
+
+// AnglePair is one monitored phase angle difference: the angle of channel A
+// minus the angle of channel B, compared against a limit in degrees.
+type AnglePair struct {
+	A, B  ChannelRef
+	Limit float64 // degrees
+}
+
+// angleSample is the most recent angle seen on a monitored channel,
+// together with its timestamp so only same-instant angles are compared.
+type angleSample struct {
+	angle   float64 // radians
+	soc     uint32
+	fracSec uint32
+}
+
+var (
+	angleMu      sync.Mutex
+	anglePairs   []AnglePair
+	angleSamples = make(map[ChannelRef]angleSample)
+)
+
+// ParseAnglePairs parses the -angle_diff flag: comma-separated
+// "A-B=limit" entries where each endpoint is "IDCODE:pmu:phasor" and the
+// limit is in degrees, e.g. "41:0:0-42:0:0=10".
+func ParseAnglePairs(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		pair, lim, ok := strings.Cut(entry, "=")
+		if !ok {
+			return fmt.Errorf("invalid angle pair %q, want A-B=degrees", entry)
+		}
+		a, b, ok := strings.Cut(pair, "-")
+		if !ok {
+			return fmt.Errorf("invalid angle pair %q, want two channels separated by '-'", entry)
+		}
+		var p AnglePair
+		var err error
+		if p.A, err = parseChannelRef(a); err != nil {
+			return fmt.Errorf("invalid angle pair %q: %v", entry, err)
+		}
+		if p.B, err = parseChannelRef(b); err != nil {
+			return fmt.Errorf("invalid angle pair %q: %v", entry, err)
+		}
+		if p.Limit, err = strconv.ParseFloat(lim, 64); err != nil || p.Limit <= 0 {
+			return fmt.Errorf("invalid angle limit %q, want degrees > 0", lim)
+		}
+		anglePairs = append(anglePairs, p)
+	}
+	return nil
+}
+
+func parseChannelRef(s string) (ChannelRef, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return ChannelRef{}, fmt.Errorf("channel %q, want IDCODE:pmu:phasor", s)
+	}
+	idCode, err := strconv.ParseUint(parts[0], 10, 16)
+	if err != nil {
+		return ChannelRef{}, fmt.Errorf("IDCODE in channel %q: %v", s, err)
+	}
+	pmu, err := strconv.Atoi(parts[1])
+	if err != nil || pmu < 0 {
+		return ChannelRef{}, fmt.Errorf("PMU index in channel %q", s)
+	}
+	phasor, err := strconv.Atoi(parts[2])
+	if err != nil || phasor < 0 {
+		return ChannelRef{}, fmt.Errorf("phasor index in channel %q", s)
+	}
+	return ChannelRef{IDCode: uint16(idCode), PMU: pmu, Phasor: phasor}, nil
+}
+
+// checkAngles latches the angles of any monitored channels carried by the
+// frame and evaluates every pair whose endpoints both have a sample from
+// the frame's timestamp. Pairs spanning two streams only trip once both
+// frames for the instant have arrived.
+func checkAngles(st *StreamContext, df *c37.DataFrame) {
+	if len(anglePairs) == 0 {
+		return
+	}
+	soc, fracSec := df.Header.SOC, df.Header.FracSecValue()
+	angleMu.Lock()
+	for _, p := range anglePairs {
+		for _, ref := range [2]ChannelRef{p.A, p.B} {
+			if ref.IDCode != st.IDCode || ref.PMU >= len(df.PMUs) {
+				continue
+			}
+			if ref.Phasor >= len(df.PMUs[ref.PMU].Phasors) {
+				continue
+			}
+			angleSamples[ref] = angleSample{
+				angle:   df.PMUs[ref.PMU].Phasors[ref.Phasor].Angle,
+				soc:     soc,
+				fracSec: fracSec,
+			}
+		}
+	}
+	type tripped struct {
+		pair AnglePair
+		diff float64
+	}
+	var evals []tripped
+	for _, p := range anglePairs {
+		a, okA := angleSamples[p.A]
+		b, okB := angleSamples[p.B]
+		if !okA || !okB || a.soc != soc || b.soc != soc || a.fracSec != fracSec || b.fracSec != fracSec {
+			continue
+		}
+		evals = append(evals, tripped{p, wrapAngle(a.angle-b.angle) * 180 / math.Pi})
+	}
+	angleMu.Unlock()
+
+	for _, e := range evals {
+		key := fmt.Sprintf("%s-%s/angle", e.pair.A, e.pair.B)
+		transitionAlarm(key, alarmEvent{
+			IDCode:  e.pair.A.IDCode,
+			Station: fmt.Sprintf("%s-%s", e.pair.A, e.pair.B),
+			Kind:    "angle",
+			Value:   e.diff,
+			Limit:   e.pair.Limit,
+			SOC:     soc,
+			FracSec: fracSec,
+		}, math.Abs(e.diff), e.pair.Limit)
+	}
+}
+
+// wrapAngle normalizes an angle difference to (-pi, pi].
+func wrapAngle(a float64) float64 {
+	for a > math.Pi {
+		a -= 2 * math.Pi
+	}
+	for a <= -math.Pi {
+		a += 2 * math.Pi
+	}
+	return a
+}
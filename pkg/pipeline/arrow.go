@@ -0,0 +1,157 @@
+package pipeline
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/ipc"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// ArrowBatchSize is how many rows (one row per PMU per data frame) are
+// collected into each Arrow record batch before it is flushed.
+var ArrowBatchSize = 100
+
+// arrowSchema is one row per PMU measurement; the variable-length channel
+// sets become list columns so mixed configurations share one schema.
+var arrowSchema = arrow.NewSchema([]arrow.Field{
+	{Name: "soc", Type: arrow.PrimitiveTypes.Uint32},
+	{Name: "fracsec", Type: arrow.PrimitiveTypes.Uint32},
+	{Name: "idcode", Type: arrow.PrimitiveTypes.Uint32},
+	{Name: "stat", Type: arrow.PrimitiveTypes.Uint32},
+	{Name: "freq", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "dfreq", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "phasor_mag", Type: arrow.ListOf(arrow.PrimitiveTypes.Float64)},
+	{Name: "phasor_ang", Type: arrow.ListOf(arrow.PrimitiveTypes.Float64)},
+	{Name: "analogs", Type: arrow.ListOf(arrow.PrimitiveTypes.Float64)},
+	{Name: "digitals", Type: arrow.ListOf(arrow.PrimitiveTypes.Uint32)},
+}, nil)
+
+// arrowUnwrappedSchema extends arrowSchema with continuous and
+// relative-to-reference angle columns, used when UnwrapAngles is set.
+var arrowUnwrappedSchema = arrow.NewSchema(append(arrowSchema.Fields(),
+	arrow.Field{Name: "phasor_ang_cont", Type: arrow.ListOf(arrow.PrimitiveTypes.Float64)},
+	arrow.Field{Name: "phasor_ang_rel", Type: arrow.ListOf(arrow.PrimitiveTypes.Float64)},
+), nil)
+
+// StartArrowWriter streams decoded data frames as Arrow IPC record
+// batches to out.FilePath, or to stdout when the path is "-", for
+// high-throughput handoff to Python/Julia analysis processes.
+func StartArrowWriter(ctx context.Context, out *OutputStream) error {
+	var sink io.Writer = os.Stdout
+	if out.FilePath != "" && out.FilePath != "-" {
+		f, err := os.OpenFile(out.FilePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		sink = f
+	}
+	schema := arrowSchema
+	if UnwrapAngles {
+		schema = arrowUnwrappedSchema
+	}
+	w := ipc.NewWriter(sink, ipc.WithSchema(schema))
+	defer w.Close()
+	b := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer b.Release()
+	unwrapper := NewAngleUnwrapper()
+	log.Printf("streaming Arrow record batches of %d rows", ArrowBatchSize)
+
+	rows := 0
+	flush := func() error {
+		if rows == 0 {
+			return nil
+		}
+		rec := b.NewRecord()
+		err := w.Write(rec)
+		rec.Release()
+		rows = 0
+		return err
+	}
+	for {
+		var frame []byte
+		select {
+		case frame = <-out.Frames:
+		case <-ctx.Done():
+			flush()
+			return ctx.Err()
+		}
+		h, err := c37.DecodeHeader(frame)
+		if err != nil || h.Type != c37.TypeData {
+			continue
+		}
+		cfg := outputDecodeCfg(h.IDCode)
+		if cfg == nil {
+			continue
+		}
+		df, err := c37.DecodeDataFrame(frame, cfg)
+		if err != nil {
+			continue
+		}
+		var cont, rel [][]float64
+		if UnwrapAngles {
+			cont, rel = unwrapper.UnwrapFrame(h.IDCode, df)
+		}
+		for i := range df.PMUs {
+			var c, r []float64
+			if UnwrapAngles {
+				c, r = cont[i], rel[i]
+			}
+			appendArrowRow(b, h, &df.PMUs[i], c, r)
+			rows++
+		}
+		if rows >= ArrowBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func appendArrowRow(b *array.RecordBuilder, h *c37.Header, pmu *c37.PMUData, cont, rel []float64) {
+	b.Field(0).(*array.Uint32Builder).Append(h.SOC)
+	b.Field(1).(*array.Uint32Builder).Append(h.FracSecValue())
+	b.Field(2).(*array.Uint32Builder).Append(uint32(h.IDCode))
+	b.Field(3).(*array.Uint32Builder).Append(uint32(pmu.Stat.Raw))
+	b.Field(4).(*array.Float64Builder).Append(pmu.Freq)
+	b.Field(5).(*array.Float64Builder).Append(pmu.DFreq)
+
+	mags := b.Field(6).(*array.ListBuilder)
+	mags.Append(true)
+	angs := b.Field(7).(*array.ListBuilder)
+	angs.Append(true)
+	for _, ph := range pmu.Phasors {
+		mags.ValueBuilder().(*array.Float64Builder).Append(ph.Magnitude)
+		angs.ValueBuilder().(*array.Float64Builder).Append(ph.Angle)
+	}
+	analogs := b.Field(8).(*array.ListBuilder)
+	analogs.Append(true)
+	for _, a := range pmu.Analogs {
+		analogs.ValueBuilder().(*array.Float64Builder).Append(a)
+	}
+	digitals := b.Field(9).(*array.ListBuilder)
+	digitals.Append(true)
+	for _, d := range pmu.Digitals {
+		digitals.ValueBuilder().(*array.Uint32Builder).Append(uint32(d))
+	}
+	if !UnwrapAngles {
+		return
+	}
+	conts := b.Field(10).(*array.ListBuilder)
+	conts.Append(true)
+	for _, v := range cont {
+		conts.ValueBuilder().(*array.Float64Builder).Append(v)
+	}
+	rels := b.Field(11).(*array.ListBuilder)
+	rels.Append(true)
+	for _, v := range rel {
+		rels.ValueBuilder().(*array.Float64Builder).Append(v)
+	}
+}
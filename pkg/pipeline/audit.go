@@ -0,0 +1,207 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// Auditor accumulates C37.118.2 conformance findings over a stream or
+// capture: field-range and CRC violations, timestamp monotonicity, rate
+// consistency and CFG/data coherence. The scored report it produces is
+// meant for PMU acceptance testing.
+type Auditor struct {
+	frames   uint64
+	findings map[string]uint64
+	streams  map[uint16]*auditStream
+}
+
+// auditStream is the per-IDCODE state of the audit.
+type auditStream struct {
+	cfg       *c37.CfgFrame2
+	lastStamp uint64
+	haveStamp bool
+	dataCount int
+	firstData time.Time
+	lastData  time.Time
+}
+
+// NewAuditor returns an empty audit.
+func NewAuditor() *Auditor {
+	return &Auditor{
+		findings: make(map[string]uint64),
+		streams:  make(map[uint16]*auditStream),
+	}
+}
+
+func (a *Auditor) finding(kind string) {
+	a.findings[kind]++
+}
+
+// Frame audits one received frame.
+func (a *Auditor) Frame(data []byte) {
+	a.frames++
+	for _, v := range c37.ValidateFrame(data) {
+		a.finding(v.String())
+	}
+	h, err := c37.DecodeHeader(data)
+	if err != nil {
+		a.finding("undecodable header")
+		return
+	}
+	st := a.streams[h.IDCode]
+	if st == nil {
+		st = &auditStream{}
+		a.streams[h.IDCode] = st
+	}
+	switch h.Type {
+	case c37.TypeCfg1, c37.TypeCfg2:
+		cfg, err := c37.DecodeConfigFrame2(data)
+		if err != nil {
+			a.finding("undecodable configuration")
+			return
+		}
+		for _, v := range cfg.Validate() {
+			a.finding(v.String())
+		}
+		st.cfg = cfg
+	case c37.TypeData:
+		if st.cfg == nil {
+			a.finding("data before configuration")
+			return
+		}
+		stamp := uint64(h.SOC)<<24 | uint64(h.FracSecValue())
+		if st.haveStamp && stamp <= st.lastStamp {
+			a.finding("timestamp not monotonic")
+		}
+		st.lastStamp, st.haveStamp = stamp, true
+		now := time.Now()
+		if st.dataCount == 0 {
+			st.firstData = now
+		}
+		st.dataCount++
+		st.lastData = now
+		if _, err := c37.DecodeDataFrame(data, st.cfg); err != nil {
+			a.finding("data does not match configuration")
+		}
+	}
+}
+
+// Report prints the scored audit report: per-finding counts and one
+// overall score, 100 for a fully conformant stream with every finding
+// class deducting in proportion to how often it occurred.
+func (a *Auditor) Report() {
+	fmt.Printf("audited %d frames, %d stream(s)\n", a.frames, len(a.streams))
+	a.checkRates()
+	if len(a.findings) == 0 {
+		fmt.Println("no conformance findings\nscore: 100/100")
+		return
+	}
+	kinds := make([]string, 0, len(a.findings))
+	for k := range a.findings {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	score := 100.0
+	for _, k := range kinds {
+		n := a.findings[k]
+		fmt.Printf("  %-40s %d\n", k, n)
+		deduct := 100 * float64(n) / float64(a.frames)
+		if deduct > 20 {
+			deduct = 20
+		}
+		score -= deduct
+	}
+	if score < 0 {
+		score = 0
+	}
+	fmt.Printf("score: %.0f/100\n", score)
+}
+
+// checkRates compares each stream's measured data rate against its
+// declared DATA_RATE, recording a finding on more than 5%% deviation.
+func (a *Auditor) checkRates() {
+	for idCode, st := range a.streams {
+		if st.cfg == nil || st.dataCount < 10 {
+			continue
+		}
+		span := st.lastData.Sub(st.firstData).Seconds()
+		if span <= 0 {
+			continue
+		}
+		measured := float64(st.dataCount-1) / span
+		num, den := rateRatio(int(st.cfg.DataRate))
+		declared := float64(num) / float64(den)
+		if declared == 0 {
+			continue
+		}
+		if dev := (measured - declared) / declared; dev > 0.05 || dev < -0.05 {
+			a.finding(fmt.Sprintf("rate mismatch IDCODE %d: %.2f fps measured, %.2f declared", idCode, measured, declared))
+		}
+	}
+}
+
+// RunAudit audits a capture file, or a live UDP stream on port for dur,
+// and prints the scored report. Live audits also stop on ctx.
+func RunAudit(ctx context.Context, port int, file string, dur time.Duration) error {
+	a := NewAuditor()
+	if file != "" {
+		if err := auditFile(a, file); err != nil {
+			return err
+		}
+		a.Report()
+		return nil
+	}
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: port})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	fmt.Printf("auditing UDP %s for %v\n", conn.LocalAddr(), dur)
+	deadline := time.Now().Add(dur)
+	conn.SetReadDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	buf := make([]byte, 65535)
+	for time.Now().Before(deadline) {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			break
+		}
+		a.Frame(buf[:n])
+	}
+	a.Report()
+	return ctx.Err()
+}
+
+// auditFile audits a capture file, one hex-encoded frame per line.
+func auditFile(a *Auditor, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for sc.Scan() {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		data, err := hex.DecodeString(text)
+		if err != nil {
+			a.finding("not a hex frame")
+			continue
+		}
+		a.Frame(data)
+	}
+	return sc.Err()
+}
@@ -0,0 +1,143 @@
+package pipeline
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"time"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// RunBench measures decode and encode throughput on the current host with
+// a synthetic stream of pmus PMU blocks of phasors phasors each, running
+// every stage for dur. The numbers size hardware for large PMU counts:
+// the pipeline spends nearly all its time in these two stages.
+func RunBench(pmus, phasors int, dur time.Duration) error {
+	if pmus < 1 || pmus > int(c37.MaxPMUs) {
+		return fmt.Errorf("invalid PMU count %d", pmus)
+	}
+	if phasors < 1 || phasors > int(c37.MaxPhasors) {
+		return fmt.Errorf("invalid phasor count %d", phasors)
+	}
+	cfg := benchConfig(pmus, phasors)
+	frame := c37.EncodeDataFrame(benchDataFrame(cfg), cfg)
+	fmt.Printf("benchmarking %d PMU(s) x %d phasors, %d-byte data frames, %v per stage\n",
+		pmus, phasors, len(frame), dur)
+
+	benchStage("decode", dur, len(frame), func() error {
+		_, err := c37.DecodeDataFrame(frame, cfg)
+		return err
+	})
+	df, err := c37.DecodeDataFrame(frame, cfg)
+	if err != nil {
+		return err
+	}
+	benchStage("encode", dur, len(frame), func() error {
+		c37.EncodeDataFrame(df, cfg)
+		return nil
+	})
+	red := NewReducer(50, 10)
+	benchStage("decode+reduce+encode", dur, len(frame), func() error {
+		d, err := c37.DecodeDataFrame(frame, cfg)
+		if err != nil {
+			return err
+		}
+		if red.Keep() {
+			c37.EncodeDataFrame(d, cfg)
+		}
+		return nil
+	})
+	return nil
+}
+
+// benchStage runs fn in a tight loop for dur and prints throughput and
+// allocation rates.
+func benchStage(name string, dur time.Duration, frameSize int, fn func() error) {
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	frames := 0
+	start := time.Now()
+	deadline := start.Add(dur)
+	for time.Now().Before(deadline) {
+		// Check the clock once per batch, not per frame.
+		for i := 0; i < 1000; i++ {
+			if err := fn(); err != nil {
+				fmt.Printf("%-22s error: %v\n", name, err)
+				return
+			}
+			frames++
+		}
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	fps := float64(frames) / elapsed.Seconds()
+	mbps := fps * float64(frameSize) / (1 << 20)
+	allocs := float64(after.Mallocs-before.Mallocs) / float64(frames)
+	bytes := float64(after.TotalAlloc-before.TotalAlloc) / float64(frames)
+	fmt.Printf("%-22s %12.0f frames/s %9.1f MiB/s %8.1f allocs/frame %9.0f B/frame\n",
+		name, fps, mbps, allocs, bytes)
+}
+
+// benchConfig builds the synthetic configuration the benchmark streams
+// against: float polar phasors, one analog, one digital word per PMU.
+func benchConfig(pmus, phasors int) *c37.CfgFrame2 {
+	cfg := &c37.CfgFrame2{
+		Header: c37.Header{
+			Version: c37.Version2011,
+			IDCode:  1,
+			SOC:     uint32(time.Now().Unix()),
+		},
+		TimeBase: 1_000_000,
+		NumPMU:   uint16(pmus),
+		DataRate: 50,
+	}
+	for i := 0; i < pmus; i++ {
+		pc := c37.PMUConfig{
+			StationName: fmt.Sprintf("BENCH_%03d", i),
+			IDCode:      uint16(i + 1),
+			Format:      c37.Format{FREQ_DFREQ: true, PHASORS: true, POLAR: true},
+			NumPhasors:  uint16(phasors),
+			NumAnalogs:  1,
+			NumDigitals: 1,
+			AnalogNames: []string{"ANALOG01"},
+			AnalogUnits: []c37.AnalogUnit{{Type: 1, Factor: 1}},
+			DigitalUnits: []c37.DigitalUnit{
+				{Normal: 0, Valid: 0xFFFF},
+			},
+			FNom: 1,
+		}
+		for j := 0; j < phasors; j++ {
+			pc.PhasorNames = append(pc.PhasorNames, fmt.Sprintf("PHASOR%02d", j))
+			pc.PhasorUnits = append(pc.PhasorUnits, c37.PhasorUnit{Factor: 915527})
+		}
+		for j := 0; j < 16; j++ {
+			pc.DigitalNames = append(pc.DigitalNames, fmt.Sprintf("DIGITAL%02d", j))
+		}
+		cfg.PMUs = append(cfg.PMUs, pc)
+	}
+	return cfg
+}
+
+// benchDataFrame fills one data frame with plausible measurements.
+func benchDataFrame(cfg *c37.CfgFrame2) *c37.DataFrame {
+	df := &c37.DataFrame{Header: cfg.Header}
+	for i := range cfg.PMUs {
+		pc := &cfg.PMUs[i]
+		pd := c37.PMUData{
+			Freq:     50.01,
+			DFreq:    0.002,
+			Analogs:  []float64{230.5},
+			Digitals: []uint16{0x0001},
+		}
+		for j := 0; j < int(pc.NumPhasors); j++ {
+			pd.Phasors = append(pd.Phasors, c37.Phasor{
+				Magnitude: 230_000,
+				Angle:     2 * math.Pi * float64(j) / 3,
+			})
+		}
+		df.PMUs = append(df.PMUs, pd)
+	}
+	return df
+}
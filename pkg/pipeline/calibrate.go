@@ -0,0 +1,117 @@
+package pipeline
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// calibEntry is the correction of one phasor channel: a fixed angle
+// offset (transformer vector group, CT/PT wiring) and a magnitude
+// calibration factor.
+type calibEntry struct {
+	ref   ChannelRef
+	angle float64 // radians, added to the measured angle
+	mag   float64 // magnitude multiplier
+}
+
+var calibrations = make(map[uint16][]calibEntry)
+
+// ParseCalibrations parses the -calibrate flag: comma-separated
+// "IDCODE:pmu:phasor=degrees[:factor]" entries, e.g.
+// "41:0:0=30,41:0:1=-90:1.02". The offset is applied before forwarding
+// and the synthesized CFG-3 carries the matching adjustment flags.
+func ParseCalibrations(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		ch, adj, ok := strings.Cut(entry, "=")
+		if !ok {
+			return fmt.Errorf("invalid calibration entry %q, want IDCODE:pmu:phasor=degrees[:factor]", entry)
+		}
+		ref, err := parseChannelRef(ch)
+		if err != nil {
+			return fmt.Errorf("invalid channel in calibration entry %q: %v", entry, err)
+		}
+		e := calibEntry{ref: ref, mag: 1}
+		deg := adj
+		if d, f, found := strings.Cut(adj, ":"); found {
+			deg = d
+			if e.mag, err = strconv.ParseFloat(f, 64); err != nil || e.mag <= 0 {
+				return fmt.Errorf("invalid magnitude factor in calibration entry %q", entry)
+			}
+		}
+		degrees, err := strconv.ParseFloat(deg, 64)
+		if err != nil {
+			return fmt.Errorf("invalid angle offset in calibration entry %q", entry)
+		}
+		e.angle = degrees * math.Pi / 180
+		calibrations[ref.IDCode] = append(calibrations[ref.IDCode], e)
+	}
+	return nil
+}
+
+// applyCalibration corrects the calibrated channels of a decoded frame in
+// place; true means the frame must be re-encoded.
+func applyCalibration(st *StreamContext, df *c37.DataFrame) bool {
+	entries := calibrations[st.IDCode]
+	mutated := false
+	for _, e := range entries {
+		if e.ref.PMU >= len(df.PMUs) {
+			continue
+		}
+		pmu := &df.PMUs[e.ref.PMU]
+		if e.ref.Phasor >= len(pmu.Phasors) {
+			continue
+		}
+		ph := &pmu.Phasors[e.ref.Phasor]
+		ph.Magnitude *= e.mag
+		ph.Angle += e.angle
+		for ph.Angle > math.Pi {
+			ph.Angle -= 2 * math.Pi
+		}
+		for ph.Angle < -math.Pi {
+			ph.Angle += 2 * math.Pi
+		}
+		mutated = true
+	}
+	return mutated
+}
+
+// CFG-3 phasor modification flags for applied adjustments.
+const (
+	cfg3MagAdjusted      = 0x0010 // magnitude adjusted by calibration
+	cfg3PhaseAdjusted    = 0x0020 // phase adjusted by calibration
+	cfg3RotationAdjusted = 0x0040 // phase adjusted by rotation
+)
+
+// markCalibration sets the adjustment flags of calibrated channels in a
+// synthesized CFG-3. Offsets that are a multiple of 30 degrees count as
+// vector-group rotation, everything else as phase calibration.
+func markCalibration(idCode uint16, cfg3 *c37.CfgFrame3) {
+	for _, e := range calibrations[idCode] {
+		if e.ref.PMU >= len(cfg3.PMUs) {
+			continue
+		}
+		pc := &cfg3.PMUs[e.ref.PMU]
+		if e.ref.Phasor >= len(pc.PhasorScales) {
+			continue
+		}
+		sc := &pc.PhasorScales[e.ref.Phasor]
+		if e.mag != 1 {
+			sc.Flags |= cfg3MagAdjusted
+		}
+		if e.angle != 0 {
+			deg := e.angle * 180 / math.Pi
+			if rem := math.Mod(deg, 30); math.Abs(rem) < 1e-9 {
+				sc.Flags |= cfg3RotationAdjusted
+			} else {
+				sc.Flags |= cfg3PhaseAdjusted
+			}
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package pipeline
+
+import (
+	"log"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// EmitCfg2 derives a best-effort CFG-2 frame from every CFG-3 the source
+// sends, so legacy PDCs can consume the reduced stream of a CFG-3-only
+// source. The derived configuration also fills in as the decode context
+// when the source never sends its own CFG-2.
+var EmitCfg2 bool
+
+// EmitCfg3 synthesizes a CFG-3 frame from every CFG-2 the source sends,
+// for downstream consumers that demand the 2011 configuration format from
+// sources that only provide CFG-2.
+var EmitCfg3 bool
+
+// Cfg3Location is the latitude/longitude reported in synthesized CFG-3
+// frames, since CFG-2 does not carry a location.
+var Cfg3Location [2]float32
+
+// Cfg3ServiceClass is the service class ('M' or 'P') reported in
+// synthesized CFG-3 frames.
+var Cfg3ServiceClass byte = 'M'
+
+// buildCfg3Frames synthesizes the CFG-3 counterpart of an output CFG-2
+// configuration, fragmented per Cfg3MTU like a forwarded CFG-3.
+func buildCfg3Frames(cfg *c37.CfgFrame2, outRate int) [][]byte {
+	cfg3 := c37.Cfg3FromCfg2(outputConfig(cfg, outRate),
+		Cfg3Location[0], Cfg3Location[1], Cfg3ServiceClass)
+	markCalibration(cfg.Header.IDCode, cfg3)
+	frags, err := c37.FragmentCfg3(c37.EncodeConfigFrame3(cfg3), Cfg3MTU)
+	if err != nil {
+		log.Printf("cannot fragment synthesized CFG-3 for IDCODE %d: %v", cfg.Header.IDCode, err)
+		return nil
+	}
+	return frags
+}
@@ -0,0 +1,69 @@
+package pipeline
+
+import (
+	"log"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// CfgPreference selects which configuration format drives decoding when a
+// PMU sends both CFG-2 and CFG-3: "cfg2" keeps the long-standing behavior
+// of the last CFG-2 winning, "cfg3" prefers the CFG-3-derived context
+// (and its floating-point scaling) whenever one has been received.
+var CfgPreference = "cfg2"
+
+// preferCfg3 reports whether the CFG-3-derived context should drive
+// decoding for the stream.
+func preferCfg3(st *StreamContext) bool {
+	return CfgPreference == "cfg3" && st.Cfg3 != nil
+}
+
+// adoptConfig installs cfg as the stream's decode context, rebuilding the
+// decimation when the input rate changed. A changed DATA_RATE silently
+// breaks the accumulator-based reduction; an unchanged re-sent CFG keeps
+// the reducer's phase.
+func adoptConfig(st *StreamContext, cfg *c37.CfgFrame2, outRate int) {
+	rateChanged := st.Cfg != nil && st.Cfg.DataRate != cfg.DataRate
+	if rateChanged {
+		log.Printf("IDCODE %d: input rate changed from %d to %d fps, rebuilding reduction",
+			st.IDCode, st.Cfg.DataRate, cfg.DataRate)
+	}
+	st.Cfg = cfg
+	if st.Reducer == nil || rateChanged {
+		st.Reducer = NewReducer(int(cfg.DataRate), outRate)
+	}
+}
+
+// checkCfgConsistency compares a PMU's CFG-2 against its CFG-3 and logs
+// structural disagreements. The two frames describe the same data stream,
+// so mismatched rates or channel counts mean one of them is stale or
+// wrong; whichever the preference selects, the operator should know.
+func checkCfgConsistency(st *StreamContext, cfg2 *c37.CfgFrame2, cfg3 *c37.CfgFrame3) {
+	if cfg2.DataRate != cfg3.DataRate {
+		logRepeats("IDCODE %d: CFG-2 declares %d fps but CFG-3 declares %d",
+			st.IDCode, cfg2.DataRate, cfg3.DataRate)
+	}
+	if cfg2.TimeBase != cfg3.TimeBase {
+		logRepeats("IDCODE %d: CFG-2 TIME_BASE %d differs from CFG-3 TIME_BASE %d",
+			st.IDCode, cfg2.TimeBase, cfg3.TimeBase)
+	}
+	if cfg2.NumPMU != cfg3.NumPMU {
+		logRepeats("IDCODE %d: CFG-2 lists %d PMU(s) but CFG-3 lists %d",
+			st.IDCode, cfg2.NumPMU, cfg3.NumPMU)
+	}
+	n := len(cfg2.PMUs)
+	if len(cfg3.PMUs) < n {
+		n = len(cfg3.PMUs)
+	}
+	for i := 0; i < n; i++ {
+		p2, p3 := &cfg2.PMUs[i], &cfg3.PMUs[i]
+		if p2.NumPhasors != p3.NumPhasors || p2.NumAnalogs != p3.NumAnalogs || p2.NumDigitals != p3.NumDigitals {
+			logRepeats("IDCODE %d PMU %d: CFG-2 channel counts %d/%d/%d differ from CFG-3 %d/%d/%d",
+				st.IDCode, i, p2.NumPhasors, p2.NumAnalogs, p2.NumDigitals,
+				p3.NumPhasors, p3.NumAnalogs, p3.NumDigitals)
+		}
+		if p2.Format != p3.Format {
+			logRepeats("IDCODE %d PMU %d: CFG-2 FORMAT differs from CFG-3", st.IDCode, i)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package pipeline
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Chaos mode injects network impairments into the output path for testing
+// how downstream PDCs and the reductor's own buffers behave on degraded
+// links. All probabilities are 0..1; zero values disable that impairment.
+var (
+	ChaosLoss    float64       // probability a frame is silently dropped
+	ChaosDup     float64       // probability a frame is sent twice
+	ChaosReorder float64       // probability a frame is swapped with its successor
+	ChaosJitter  time.Duration // maximum random extra latency per frame
+)
+
+// chaosEnabled reports whether any impairment is configured.
+func chaosEnabled() bool {
+	return ChaosLoss > 0 || ChaosDup > 0 || ChaosReorder > 0 || ChaosJitter > 0
+}
+
+// startChaos interposes the impairment stage between Publish and the
+// stream's sender, the same way the retimer does.
+func (s *OutputStream) startChaos() {
+	s.ingress = make(chan []byte, cap(s.Frames))
+	s.quit = make(chan struct{})
+	go s.chaos()
+}
+
+// chaos applies loss, duplication, reordering and latency jitter to every
+// frame until the stream is removed. Reordering holds a frame back and
+// releases it behind its successor; jitter delays the whole stream
+// head-of-line like a congested path would.
+func (s *OutputStream) chaos() {
+	var held []byte
+	for {
+		select {
+		case frame := <-s.ingress:
+			if held != nil {
+				s.impair(frame)
+				s.impair(held)
+				held = nil
+				continue
+			}
+			if ChaosReorder > 0 && rand.Float64() < ChaosReorder {
+				held = frame
+				continue
+			}
+			s.impair(frame)
+		case <-s.quit:
+			if held != nil {
+				s.send(held)
+			}
+			return
+		}
+	}
+}
+
+// impair delivers one frame through the loss, jitter and duplication
+// impairments.
+func (s *OutputStream) impair(frame []byte) {
+	if ChaosLoss > 0 && rand.Float64() < ChaosLoss {
+		return
+	}
+	if ChaosJitter > 0 {
+		time.Sleep(time.Duration(rand.Int63n(int64(ChaosJitter))))
+	}
+	s.send(frame)
+	if ChaosDup > 0 && rand.Float64() < ChaosDup {
+		s.send(frame)
+	}
+}
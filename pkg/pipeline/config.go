@@ -0,0 +1,132 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+)
+
+// PipelineConfig describes one independent pipeline of the multi-pipeline
+// configuration file: a listening port feeding its own set of outputs.
+// Streams are still tracked by IDCODE process-wide, so the PMU feeds of
+// different pipelines must use distinct IDCODEs.
+type PipelineConfig struct {
+	Name    string         `json:"name"`
+	Port    int            `json:"port"`
+	Bind    string         `json:"bind,omitempty"`
+	Outputs []OutputConfig `json:"outputs"`
+}
+
+// OutputConfig is one output destination inside a PipelineConfig.
+type OutputConfig struct {
+	Protocol string `json:"protocol"`
+	Host     string `json:"host,omitempty"`
+	Port     int    `json:"port,omitempty"`
+	Rate     int    `json:"rate,omitempty"`
+	File     string `json:"file,omitempty"`
+}
+
+// LoadPipelines reads a JSON array of pipeline definitions, e.g.
+//
+//	[{"name": "north", "port": 4712,
+//	  "outputs": [{"protocol": "udp", "host": "10.0.0.5", "port": 4713, "rate": 10}]}]
+func LoadPipelines(path string) ([]PipelineConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var pcs []PipelineConfig
+	if err := json.Unmarshal(data, &pcs); err != nil {
+		return nil, fmt.Errorf("cannot parse pipeline config %s: %v", path, err)
+	}
+	for i, pc := range pcs {
+		if pc.Port <= 0 || pc.Port > 65535 {
+			return nil, fmt.Errorf("pipeline %d (%s): invalid port %d", i, pc.Name, pc.Port)
+		}
+		if len(pc.Outputs) == 0 {
+			return nil, fmt.Errorf("pipeline %d (%s): no outputs", i, pc.Name)
+		}
+	}
+	return pcs, nil
+}
+
+// StartOutput launches the sender matching the stream's protocol and
+// blocks until it stops.
+func StartOutput(ctx context.Context, stream *OutputStream, bindAddr string, bo Backoff) error {
+	switch stream.Protocol {
+	case "udp":
+		return StartUDPSender(ctx, stream, bindAddr)
+	case "tcp":
+		return StartTCPClientFailover(ctx, stream, bindAddr, bo)
+	case "tcp-server":
+		return StartTCPServer(ctx, stream)
+	case "file":
+		return StartFileWriter(ctx, stream)
+	case "sqlite":
+		return StartSQLiteWriter(ctx, stream)
+	case "proto":
+		return StartProtoWriter(ctx, stream)
+	case "arrow":
+		return StartArrowWriter(ctx, stream)
+	}
+	return fmt.Errorf("unknown output protocol %q", stream.Protocol)
+}
+
+// The output registries of the running pipelines by name, so the control
+// API can address the outputs of one pipeline in -pipelines mode.
+var (
+	pipelineOutsMu sync.Mutex
+	pipelineOuts   = make(map[string]*Outputs)
+)
+
+// PipelineOutputs returns the output registry of the named pipeline, or
+// nil when no such pipeline is running.
+func PipelineOutputs(name string) *Outputs {
+	pipelineOutsMu.Lock()
+	defer pipelineOutsMu.Unlock()
+	return pipelineOuts[name]
+}
+
+// allPipelineOuts returns a snapshot of the registries of all running
+// pipelines.
+func allPipelineOuts() map[string]*Outputs {
+	pipelineOutsMu.Lock()
+	defer pipelineOutsMu.Unlock()
+	out := make(map[string]*Outputs, len(pipelineOuts))
+	for name, outs := range pipelineOuts {
+		out[name] = outs
+	}
+	return out
+}
+
+// RunPipeline brings up one configured pipeline: its outputs get their own
+// registry and sender goroutines, then the listener runs until ctx is
+// cancelled.
+func RunPipeline(ctx context.Context, pc PipelineConfig) error {
+	outs := NewOutputs()
+	pipelineOutsMu.Lock()
+	pipelineOuts[pc.Name] = outs
+	pipelineOutsMu.Unlock()
+	for _, oc := range pc.Outputs {
+		host := oc.Host
+		if host == "" {
+			host = "127.0.0.1"
+		}
+		stream := outs.Add(Output{
+			Protocol: oc.Protocol,
+			Host:     host,
+			Port:     oc.Port,
+			DataRate: oc.Rate,
+			FilePath: oc.File,
+		})
+		go func() {
+			if err := StartOutput(ctx, stream, "", DefaultBackoff); err != nil && ctx.Err() == nil {
+				log.Printf("pipeline %s: output %s %s stopped: %v", pc.Name, stream.Protocol, stream.Addr(), err)
+			}
+		}()
+	}
+	return StartListening(ctx, pc.Port, pc.Bind, outs)
+}
@@ -0,0 +1,161 @@
+package pipeline
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// RunConsole connects to a PMU or PDC at addr and runs an interactive
+// command console: the operator sends command frames addressed to idCode
+// and every response is decoded and printed, which is handy while
+// commissioning a device.
+func RunConsole(addr string, idCode uint16) error {
+	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	fmt.Printf("connected to %s, commanding IDCODE %d\n", addr, idCode)
+	fmt.Println("commands: on, off, cfg1, cfg2, cfg3, hdr, id <n>, quit")
+	go consoleReader(conn)
+
+	in := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Print("> ")
+		if !in.Scan() {
+			return in.Err()
+		}
+		fields := strings.Fields(in.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		var command uint16
+		switch fields[0] {
+		case "on":
+			command = c37.CmdDataOn
+		case "off":
+			command = c37.CmdDataOff
+		case "hdr":
+			command = c37.CmdSendHdr
+		case "cfg1":
+			command = c37.CmdSendCfg1
+		case "cfg2":
+			command = c37.CmdSendCfg2
+		case "cfg3":
+			command = c37.CmdSendCfg3
+		case "id":
+			if len(fields) != 2 {
+				fmt.Println("usage: id <idcode>")
+				continue
+			}
+			if _, err := fmt.Sscanf(fields[1], "%d", &idCode); err != nil {
+				fmt.Printf("invalid IDCODE %q\n", fields[1])
+			} else {
+				fmt.Printf("commanding IDCODE %d\n", idCode)
+			}
+			continue
+		case "quit", "exit":
+			return nil
+		default:
+			fmt.Printf("unknown command %q (on, off, cfg1, cfg2, cfg3, hdr, id <n>, quit)\n", fields[0])
+			continue
+		}
+		if _, err := conn.Write(c37.BuildCommandFrame(idCode, command)); err != nil {
+			return fmt.Errorf("sending command: %w", err)
+		}
+	}
+}
+
+// consoleReader frames the response stream and prints one decoded line
+// per frame, resynchronizing on the next SYNC byte after garbage. Data
+// frames are summarized at most once per second per stream so a running
+// device does not drown the prompt.
+func consoleReader(conn net.Conn) {
+	cfgs := make(map[uint16]*c37.CfgFrame2)
+	lastData := make(map[uint16]time.Time)
+	var buf []byte
+	tmp := make([]byte, 4096)
+	for {
+		n, err := conn.Read(tmp)
+		if err != nil {
+			fmt.Printf("\nconnection closed: %v\n", err)
+			return
+		}
+		buf = append(buf, tmp[:n]...)
+		for len(buf) >= c37.CommonHeaderSize {
+			size := int(binary.BigEndian.Uint16(buf[2:4]))
+			if buf[0] != c37.SyncLead || size < c37.CommonHeaderSize+2 {
+				buf = buf[1:]
+				continue
+			}
+			if len(buf) < size {
+				break
+			}
+			printFrame(buf[:size], cfgs, lastData)
+			buf = buf[size:]
+		}
+	}
+}
+
+// printFrame decodes one response frame and prints a summary.
+func printFrame(data []byte, cfgs map[uint16]*c37.CfgFrame2, lastData map[uint16]time.Time) {
+	h, err := c37.DecodeHeader(data)
+	if err != nil {
+		return
+	}
+	switch h.Type {
+	case c37.TypeCfg1, c37.TypeCfg2:
+		cfg, err := c37.DecodeConfigFrame2(data)
+		if err != nil {
+			fmt.Printf("\n< CFG (IDCODE %d): undecodable: %v\n> ", h.IDCode, err)
+			return
+		}
+		cfgs[h.IDCode] = cfg
+		fmt.Printf("\n< CFG (IDCODE %d): %d PMU(s), %d fps, TIME_BASE %d\n", h.IDCode, cfg.NumPMU, cfg.DataRate, cfg.TimeBase)
+		for i := range cfg.PMUs {
+			pc := &cfg.PMUs[i]
+			fmt.Printf("  PMU %d %q: %d phasors %v, %d analogs, %d digitals, %g Hz nominal\n",
+				i, pc.StationName, pc.NumPhasors, pc.PhasorNames, pc.NumAnalogs, pc.NumDigitals, pc.NominalFreq())
+		}
+		fmt.Print("> ")
+	case c37.TypeCfg3:
+		fmt.Printf("\n< CFG-3 (IDCODE %d): %d bytes\n> ", h.IDCode, len(data))
+	case c37.TypeHeader:
+		fmt.Printf("\n< HDR (IDCODE %d): %q\n> ", h.IDCode, strings.TrimSpace(string(data[c37.CommonHeaderSize:len(data)-2])))
+	case c37.TypeData:
+		if time.Since(lastData[h.IDCode]) < time.Second {
+			return
+		}
+		lastData[h.IDCode] = time.Now()
+		cfg := cfgs[h.IDCode]
+		if cfg == nil {
+			fmt.Printf("\n< DATA (IDCODE %d): %d bytes, no configuration yet (send cfg2)\n> ", h.IDCode, len(data))
+			return
+		}
+		df, err := c37.DecodeDataFrame(data, cfg)
+		if err != nil {
+			fmt.Printf("\n< DATA (IDCODE %d): undecodable: %v\n> ", h.IDCode, err)
+			return
+		}
+		for i := range df.PMUs {
+			pmu := &df.PMUs[i]
+			line := fmt.Sprintf("STAT %04X, freq %.4f Hz, dfreq %.3f Hz/s", pmu.Stat.Raw, pmu.Freq, pmu.DFreq)
+			if len(pmu.Phasors) > 0 {
+				line += fmt.Sprintf(", ph0 %.1f∠%.2f°", pmu.Phasors[0].Magnitude, pmu.Phasors[0].Angle*180/math.Pi)
+			}
+			fmt.Printf("\n< DATA (IDCODE %d) PMU %d: %s\n> ", h.IDCode, i, line)
+		}
+	case c37.TypeCmd:
+		if cmd, err := c37.DecodeCommandFrame(data); err == nil {
+			fmt.Printf("\n< CMD echo (IDCODE %d): command %d\n> ", h.IDCode, cmd.Command)
+		}
+	}
+}
@@ -0,0 +1,386 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Control is the HTTP control plane: it can start and stop input listeners
+// and attach or detach outputs at runtime while the rest of the pipeline
+// keeps running.
+type Control struct {
+	ctx  context.Context
+	outs *Outputs
+
+	mu      sync.Mutex
+	senders map[*OutputStream]*attachedSender
+}
+
+// attachedSender tracks an output attached at runtime together with the
+// registry it was attached to, so detaching removes it from the right
+// one.
+type attachedSender struct {
+	cancel context.CancelFunc
+	outs   *Outputs
+}
+
+// registry resolves the output registry a request addresses: the
+// "pipeline" parameter selects a named pipeline's registry; without it
+// the flag-defined registry is used, which does not exist in -pipelines
+// mode.
+func (c *Control) registry(r *http.Request) (*Outputs, error) {
+	if name := r.FormValue("pipeline"); name != "" {
+		outs := PipelineOutputs(name)
+		if outs == nil {
+			return nil, fmt.Errorf("unknown pipeline %q", name)
+		}
+		return outs, nil
+	}
+	if c.outs == nil {
+		return nil, fmt.Errorf("pipeline parameter required in -pipelines mode")
+	}
+	return c.outs, nil
+}
+
+// StartControlAPI serves the control API on addr (e.g. "127.0.0.1:8080")
+// until ctx is cancelled. outs is the flag-defined output registry; in
+// -pipelines mode it is nil and every request that needs a registry must
+// name a pipeline.
+func StartControlAPI(ctx context.Context, addr string, outs *Outputs) error {
+	c := &Control{
+		ctx:     ctx,
+		outs:    outs,
+		senders: make(map[*OutputStream]*attachedSender),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/listeners/start", post(c.handleListenerStart))
+	mux.HandleFunc("/listeners/stop", post(c.handleListenerStop))
+	mux.HandleFunc("/listeners/restart", post(c.handleListenerRestart))
+	mux.HandleFunc("/outputs/attach", post(c.handleOutputAttach))
+	mux.HandleFunc("/outputs/detach", post(c.handleOutputDetach))
+	mux.HandleFunc("/streams", c.handleStreams)
+	mux.HandleFunc("/alarms", c.handleAlarms)
+	mux.HandleFunc("/failover", c.handleFailover)
+	mux.HandleFunc("/paths", c.handlePaths)
+	mux.HandleFunc("/sync", c.handleSync)
+	mux.HandleFunc("/freqstats", c.handleFreqStats)
+	mux.HandleFunc("/errors", c.handleErrors)
+	mux.HandleFunc("/outputs", c.handleOutputs)
+	mux.HandleFunc("/sessions", c.handleSessions)
+
+	srv := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		shutCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		srv.Shutdown(shutCtx)
+	}()
+	log.Printf("control API listening on http://%s", addr)
+	err := srv.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// post restricts a handler to the POST method.
+func post(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h(w, r)
+	}
+}
+
+func (c *Control) handleListenerStart(w http.ResponseWriter, r *http.Request) {
+	outs, err := c.registry(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	port, err := strconv.Atoi(r.FormValue("port"))
+	if err != nil || port <= 0 || port > 65535 {
+		http.Error(w, "invalid or missing port", http.StatusBadRequest)
+		return
+	}
+	bind := r.FormValue("bind")
+	go func() {
+		err := StartManagedListener(c.ctx, port, bind, outs)
+		if err != nil && c.ctx.Err() == nil {
+			log.Printf("listener on port %d stopped: %v", port, err)
+		}
+	}()
+	fmt.Fprintf(w, "listening on port %d\n", port)
+}
+
+func (c *Control) handleListenerStop(w http.ResponseWriter, r *http.Request) {
+	port, err := strconv.Atoi(r.FormValue("port"))
+	if err != nil {
+		http.Error(w, "invalid or missing port", http.StatusBadRequest)
+		return
+	}
+	if !StopListener(port) {
+		http.Error(w, fmt.Sprintf("no listener on port %d", port), http.StatusNotFound)
+		return
+	}
+	fmt.Fprintf(w, "stopped listener on port %d\n", port)
+}
+
+// handleListenerRestart rebinds a running input listener, optionally to a
+// different port, bind address or source allowlist. The outputs are not
+// touched, so established TCP output sessions survive the restart; with
+// -hold enabled their cadence is bridged from the hold buffer while the
+// socket is down.
+func (c *Control) handleListenerRestart(w http.ResponseWriter, r *http.Request) {
+	outs, err := c.registry(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	port, err := strconv.Atoi(r.FormValue("port"))
+	if err != nil || port <= 0 || port > 65535 {
+		http.Error(w, "invalid or missing port", http.StatusBadRequest)
+		return
+	}
+	newPort := port
+	if v := r.FormValue("new_port"); v != "" {
+		if newPort, err = strconv.Atoi(v); err != nil || newPort <= 0 || newPort > 65535 {
+			http.Error(w, "invalid new_port", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := r.FormValue("allow"); v != "" {
+		if v == "any" {
+			SourceAllowlist = nil
+		} else {
+			al, err := ParseAllowlist(v)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			SourceAllowlist = al
+		}
+	}
+	if !StopListener(port) {
+		http.Error(w, fmt.Sprintf("no listener on port %d", port), http.StatusNotFound)
+		return
+	}
+	if !listenerStopped(port, 2*time.Second) {
+		http.Error(w, fmt.Sprintf("listener on port %d did not release its socket", port), http.StatusInternalServerError)
+		return
+	}
+	bind := r.FormValue("bind")
+	go func() {
+		err := StartManagedListener(c.ctx, newPort, bind, outs)
+		if err != nil && c.ctx.Err() == nil {
+			log.Printf("listener on port %d stopped: %v", newPort, err)
+		}
+	}()
+	fmt.Fprintf(w, "listener on port %d restarted on port %d\n", port, newPort)
+}
+
+func (c *Control) handleOutputAttach(w http.ResponseWriter, r *http.Request) {
+	outs, err := c.registry(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	port, err := strconv.Atoi(r.FormValue("port"))
+	if err != nil {
+		http.Error(w, "invalid or missing port", http.StatusBadRequest)
+		return
+	}
+	rate, _ := strconv.Atoi(r.FormValue("rate"))
+	out := Output{
+		Protocol: r.FormValue("protocol"),
+		Host:     r.FormValue("host"),
+		Port:     port,
+		DataRate: rate,
+		FilePath: r.FormValue("file"),
+	}
+	if out.Host == "" {
+		out.Host = "127.0.0.1"
+	}
+	stream := outs.Add(out)
+	sctx, cancel := context.WithCancel(c.ctx)
+	c.mu.Lock()
+	c.senders[stream] = &attachedSender{cancel: cancel, outs: outs}
+	c.mu.Unlock()
+	var start func() error
+	switch out.Protocol {
+	case "udp":
+		start = func() error { return StartUDPSender(sctx, stream, "") }
+	case "tcp":
+		start = func() error { return StartTCPClient(sctx, stream, "", DefaultBackoff) }
+	case "tcp-server":
+		start = func() error { return StartTCPServer(sctx, stream) }
+	case "file":
+		start = func() error { return StartFileWriter(sctx, stream) }
+	default:
+		cancel()
+		outs.Remove(stream)
+		http.Error(w, fmt.Sprintf("unknown protocol %q", out.Protocol), http.StatusBadRequest)
+		return
+	}
+	go func() {
+		if err := start(); err != nil && sctx.Err() == nil {
+			log.Printf("output %s %s stopped: %v", out.Protocol, out.Addr(), err)
+		}
+		outs.Remove(stream)
+	}()
+	// Late joiners need the configuration before any data frame.
+	if cfg := LastOutputCfg(); cfg != nil {
+		stream.Frames <- cfg
+	}
+	fmt.Fprintf(w, "attached %s output %s\n", out.Protocol, out.Addr())
+}
+
+func (c *Control) handleOutputDetach(w http.ResponseWriter, r *http.Request) {
+	port, err := strconv.Atoi(r.FormValue("port"))
+	if err != nil {
+		http.Error(w, "invalid or missing port", http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for stream, s := range c.senders {
+		if stream.Port == port && stream.Protocol == r.FormValue("protocol") {
+			s.cancel()
+			delete(c.senders, stream)
+			s.outs.Remove(stream)
+			fmt.Fprintf(w, "detached %s output %s\n", stream.Protocol, stream.Addr())
+			return
+		}
+	}
+	http.Error(w, "no matching output", http.StatusNotFound)
+}
+
+func (c *Control) handleAlarms(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AlarmCounts())
+}
+
+func (c *Control) handleFailover(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(FailoverStatus())
+}
+
+func (c *Control) handlePaths(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PathHealth())
+}
+
+func (c *Control) handleSync(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SyncLost())
+}
+
+func (c *Control) handleFreqStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(FreqStats())
+}
+
+func (c *Control) handleOutputs(w http.ResponseWriter, r *http.Request) {
+	type outputInfo struct {
+		Pipeline    string    `json:"pipeline,omitempty"`
+		Protocol    string    `json:"protocol"`
+		Addr        string    `json:"addr"`
+		Dropped     uint64    `json:"dropped"`
+		LastError   string    `json:"last_error,omitempty"`
+		LastErrorAt time.Time `json:"last_error_at,omitempty"`
+	}
+	var infos []outputInfo
+	add := func(pipeline string, outs *Outputs) {
+		for _, os := range outs.Streams() {
+			info := outputInfo{
+				Pipeline: pipeline,
+				Protocol: os.Protocol,
+				Addr:     os.Addr(),
+				Dropped:  atomic.LoadUint64(&os.Dropped),
+			}
+			info.LastError, info.LastErrorAt = os.LastSendError()
+			infos = append(infos, info)
+		}
+	}
+	switch {
+	case r.FormValue("pipeline") != "":
+		outs, err := c.registry(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		add(r.FormValue("pipeline"), outs)
+	case c.outs != nil:
+		add("", c.outs)
+	default:
+		for name, outs := range allPipelineOuts() {
+			add(name, outs)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(infos)
+}
+
+func (c *Control) handleErrors(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RepeatedLogCounts())
+}
+
+func (c *Control) handleSessions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SessionStats())
+}
+
+func (c *Control) handleStreams(w http.ResponseWriter, r *http.Request) {
+	type streamInfo struct {
+		IDCode       uint16    `json:"idcode"`
+		Version      uint8     `json:"version"`
+		FramesIn     uint64    `json:"frames_in"`
+		FramesOut    uint64    `json:"frames_out"`
+		DecodeErrors uint64    `json:"decode_errors"`
+		TQDrops      uint64    `json:"tq_drops,omitempty"`
+		TQCode       uint8     `json:"tq_code"`
+		LeapPending  bool      `json:"leap_pending,omitempty"`
+		ClockSkewMs  float64   `json:"clock_skew_ms"`
+		LastFrame    time.Time `json:"last_frame"`
+		DataRate     int16     `json:"data_rate,omitempty"`
+		State        string    `json:"state"`
+		LastError    string    `json:"last_error,omitempty"`
+		LastErrorAt  time.Time `json:"last_error_at,omitempty"`
+	}
+	var infos []streamInfo
+	pipelineMu.Lock()
+	for _, st := range Streams() {
+		info := streamInfo{
+			IDCode:       st.IDCode,
+			Version:      st.Stats.Version,
+			FramesIn:     st.Stats.FramesIn,
+			FramesOut:    st.Stats.FramesOut,
+			DecodeErrors: st.Stats.DecodeErrors,
+			TQDrops:      st.Stats.TQDrops,
+			TQCode:       st.Stats.TimeQuality.Code,
+			LeapPending:  st.Stats.TimeQuality.LeapPending,
+			ClockSkewMs:  float64(st.Stats.ClockSkew) / float64(time.Millisecond),
+			LastFrame:    st.Stats.LastFrame,
+			State:        st.State(),
+			LastError:    st.Stats.LastError,
+			LastErrorAt:  st.Stats.LastErrorAt,
+		}
+		if st.Cfg != nil {
+			info.DataRate = st.Cfg.DataRate
+		}
+		infos = append(infos, info)
+	}
+	pipelineMu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(infos)
+}
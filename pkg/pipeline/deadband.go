@@ -0,0 +1,106 @@
+package pipeline
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// MaxSilence caps how long the deadband filter may hold frames back: once
+// this much time has passed since the last forwarded frame, the next one
+// goes out regardless of channel movement.
+var MaxSilence = time.Second
+
+// deadbandEntry is one monitored channel and its threshold: a phasor
+// magnitude in channel units, or with freq set the PMU frequency in Hz.
+type deadbandEntry struct {
+	ref   ChannelRef
+	freq  bool
+	delta float64
+}
+
+var deadbands = make(map[uint16][]deadbandEntry)
+
+// ParseDeadbands parses the -deadband flag: comma-separated
+// "IDCODE:pmu:phasor=delta" entries monitoring a phasor magnitude, or
+// "IDCODE:pmu:f=delta" monitoring the PMU frequency, e.g.
+// "41:0:0=50,41:0:f=0.01". Streams without an entry are unaffected.
+func ParseDeadbands(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		ch, d, ok := strings.Cut(entry, "=")
+		if !ok {
+			return fmt.Errorf("invalid deadband entry %q, want IDCODE:pmu:phasor=delta", entry)
+		}
+		var e deadbandEntry
+		var err error
+		if rest, found := strings.CutSuffix(ch, ":f"); found {
+			e.freq = true
+			ch = rest + ":0"
+		}
+		ref, err := parseChannelRef(ch)
+		if err != nil {
+			return fmt.Errorf("invalid channel in deadband entry %q: %v", entry, err)
+		}
+		e.ref = ref
+		if e.delta, err = strconv.ParseFloat(d, 64); err != nil || e.delta <= 0 {
+			return fmt.Errorf("invalid delta in deadband entry %q", entry)
+		}
+		deadbands[ref.IDCode] = append(deadbands[ref.IDCode], e)
+	}
+	return nil
+}
+
+// deadbandState remembers the channel values of the last forwarded frame
+// per stream.
+type deadbandState struct {
+	sent   time.Time
+	values []float64
+}
+
+var deadbandStates = make(map[uint16]*deadbandState)
+
+// checkDeadband decides whether a data frame passes the deadband filter.
+// Streams without monitored channels always pass; otherwise a frame is
+// forwarded when any monitored channel moved by more than its delta since
+// the last forwarded frame, or when MaxSilence has elapsed.
+func checkDeadband(st *StreamContext, df *c37.DataFrame) bool {
+	entries := deadbands[st.IDCode]
+	if len(entries) == 0 {
+		return true
+	}
+	current := make([]float64, len(entries))
+	for i, e := range entries {
+		if e.ref.PMU >= len(df.PMUs) {
+			continue
+		}
+		pmu := &df.PMUs[e.ref.PMU]
+		if e.freq {
+			current[i] = pmu.Freq
+		} else if e.ref.Phasor < len(pmu.Phasors) {
+			current[i] = pmu.Phasors[e.ref.Phasor].Magnitude
+		}
+	}
+	dbs := deadbandStates[st.IDCode]
+	if dbs == nil {
+		deadbandStates[st.IDCode] = &deadbandState{sent: time.Now(), values: current}
+		return true
+	}
+	forward := time.Since(dbs.sent) >= MaxSilence
+	for i, e := range entries {
+		if diff := current[i] - dbs.values[i]; diff > e.delta || diff < -e.delta {
+			forward = true
+			break
+		}
+	}
+	if forward {
+		dbs.sent = time.Now()
+		dbs.values = current
+	}
+	return forward
+}
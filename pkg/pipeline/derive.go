@@ -0,0 +1,138 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// dropExpr and alarmExpr are the compiled -drop_if and -alarm_if
+// predicates; nil when unset.
+var (
+	dropExpr  exprNode
+	alarmExpr exprNode
+	alarmSrc  string
+)
+
+// deriveChannel is one -derive entry: a formula appended as a synthetic
+// analog channel to every PMU block.
+type deriveChannel struct {
+	Name string
+	node exprNode
+}
+
+var deriveChannels []deriveChannel
+
+// ParseDropExpr compiles the -drop_if predicate; frames where it holds
+// for any PMU are dropped.
+func ParseDropExpr(src string) error {
+	if src == "" {
+		return nil
+	}
+	node, err := parseExpr(src)
+	dropExpr = node
+	return err
+}
+
+// ParseAlarmExpr compiles the -alarm_if predicate; an alarm is raised per
+// PMU while it holds.
+func ParseAlarmExpr(src string) error {
+	if src == "" {
+		return nil
+	}
+	node, err := parseExpr(src)
+	alarmExpr = node
+	alarmSrc = src
+	return err
+}
+
+// ParseDeriveChannels parses the -derive flag: comma-separated
+// "NAME=expr" entries, e.g. "FDEV=freq-fnom,VDIFF=mag0-mag1". Each entry
+// appends one analog channel to every PMU block of the output.
+func ParseDeriveChannels(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		name, src, ok := strings.Cut(entry, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("invalid derive entry %q, want NAME=expr", entry)
+		}
+		node, err := parseExpr(src)
+		if err != nil {
+			return err
+		}
+		deriveChannels = append(deriveChannels, deriveChannel{Name: name, node: node})
+	}
+	return nil
+}
+
+// checkExprRules evaluates the drop and alarm predicates over a decoded
+// frame. Evaluation errors (e.g. a channel index beyond this stream's
+// layout) count as false.
+func checkExprRules(st *StreamContext, df *c37.DataFrame) (drop bool) {
+	for i := range df.PMUs {
+		env := pmuEnv(st, df, i)
+		if alarmExpr != nil {
+			v, err := alarmExpr.eval(env)
+			if err == nil {
+				key := fmt.Sprintf("%d/%d/expr", st.IDCode, i)
+				transitionAlarm(key, alarmEvent{
+					IDCode:  st.IDCode,
+					Station: alarmSrc,
+					Kind:    "expr",
+					Value:   v,
+					SOC:     df.Header.SOC,
+					FracSec: df.Header.FracSecValue(),
+				}, v, 0)
+			}
+		}
+		if dropExpr != nil {
+			if v, err := dropExpr.eval(env); err == nil && v != 0 {
+				drop = true
+			}
+		}
+	}
+	return drop
+}
+
+// withDerivedChannels returns a copy of cfg advertising the derived
+// analog channels, or cfg itself when none are configured.
+func withDerivedChannels(cfg *c37.CfgFrame2) *c37.CfgFrame2 {
+	if len(deriveChannels) == 0 {
+		return cfg
+	}
+	out := *cfg
+	out.PMUs = append([]c37.PMUConfig(nil), cfg.PMUs...)
+	for i := range out.PMUs {
+		pc := &out.PMUs[i]
+		names := append([]string(nil), pc.AnalogNames...)
+		units := append([]c37.AnalogUnit(nil), pc.AnalogUnits...)
+		for _, dc := range deriveChannels {
+			names = append(names, dc.Name)
+			units = append(units, c37.AnalogUnit{Type: 1, Factor: 1})
+		}
+		pc.AnalogNames = names
+		pc.AnalogUnits = units
+		pc.NumAnalogs += uint16(len(deriveChannels))
+	}
+	return &out
+}
+
+// injectDerivedChannels evaluates every derive formula per PMU block and
+// re-encodes the data frame with the results appended as analog channels.
+// A formula that fails on this stream contributes zero.
+func injectDerivedChannels(st *StreamContext, df *c37.DataFrame) []byte {
+	for i := range df.PMUs {
+		env := pmuEnv(st, df, i)
+		for _, dc := range deriveChannels {
+			v, err := dc.node.eval(env)
+			if err != nil {
+				v = 0
+			}
+			df.PMUs[i].Analogs = append(df.PMUs[i].Analogs, v)
+		}
+	}
+	return c37.EncodeDataFrame(df, st.Cfg)
+}
@@ -0,0 +1,215 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"log"
+	"math"
+	"net"
+	"sort"
+	"time"
+)
+
+// DNP3Station is the link-layer address the embedded outstation answers
+// as.
+var DNP3Station uint16 = 1
+
+// The embedded DNP3 outstation serves the most recent values of every
+// stream as single-precision analog inputs (group 30 variation 5), so
+// SCADA masters without synchrophasor support can poll the reduced data.
+// Point indices are assigned deterministically: streams sorted by IDCODE,
+// per PMU block first the phasor magnitudes, then the angles in degrees,
+// then frequency and ROCOF.
+
+// dnp3MaxPoints caps a response at what one link frame can carry: the
+// link-layer LEN octet covers at most 250 octets of user data, and after
+// the 12 octets of transport, application and object headers each group
+// 30 variation 5 point takes 5.
+const dnp3MaxPoints = 47
+
+// dnp3Truncated makes the point-list overflow warning fire once.
+var dnp3Truncated bool
+
+// dnp3Snapshot assembles the current analog point list.
+func dnp3Snapshot() []float64 {
+	pipelineMu.Lock()
+	defer pipelineMu.Unlock()
+	sts := Streams()
+	sort.Slice(sts, func(i, j int) bool { return sts[i].IDCode < sts[j].IDCode })
+	var points []float64
+	for _, st := range sts {
+		df := st.LastData
+		if df == nil {
+			continue
+		}
+		for i := range df.PMUs {
+			pd := &df.PMUs[i]
+			for _, ph := range pd.Phasors {
+				points = append(points, ph.Magnitude)
+			}
+			for _, ph := range pd.Phasors {
+				points = append(points, ph.Angle*180/math.Pi)
+			}
+			points = append(points, pd.Freq, pd.DFreq)
+		}
+	}
+	if len(points) > dnp3MaxPoints {
+		if !dnp3Truncated {
+			dnp3Truncated = true
+			log.Printf("DNP3: %d points exceed one link frame, serving the first %d", len(points), dnp3MaxPoints)
+		}
+		points = points[:dnp3MaxPoints]
+	}
+	return points
+}
+
+// dnp3CRC computes the DNP3 link-layer CRC (polynomial 0x3D65, reflected,
+// complemented).
+func dnp3CRC(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xA6BC
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return ^crc
+}
+
+// StartDNP3Outstation serves DNP3 READ requests on addr until ctx is
+// cancelled. Every READ is answered with the full point list; class and
+// range qualifiers in the request are not interpreted.
+func StartDNP3Outstation(ctx context.Context, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	log.Printf("DNP3 outstation listening on %s (link address %d)", addr, DNP3Station)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go serveDNP3(conn)
+	}
+}
+
+// serveDNP3 answers the requests of one master connection.
+func serveDNP3(conn net.Conn) {
+	defer conn.Close()
+	for {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Minute))
+		src, user, err := readDNP3Frame(conn)
+		if err != nil {
+			if err != io.EOF {
+				log.Printf("DNP3 %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		// Transport header, application control, function code.
+		if len(user) < 3 || user[2] != 0x01 {
+			continue
+		}
+		resp := buildDNP3Response(user[1]&0x0F, dnp3Snapshot())
+		if _, err := conn.Write(linkDNP3Frame(src, resp)); err != nil {
+			return
+		}
+	}
+}
+
+// readDNP3Frame reads one link frame and returns the master's link
+// address and the user data with the block CRCs stripped.
+func readDNP3Frame(r io.Reader) (src uint16, user []byte, err error) {
+	hdr := make([]byte, 10)
+	if _, err = io.ReadFull(r, hdr); err != nil {
+		return 0, nil, err
+	}
+	if hdr[0] != 0x05 || hdr[1] != 0x64 {
+		return 0, nil, errDNP3Framing
+	}
+	if dnp3CRC(hdr[:8]) != binary.LittleEndian.Uint16(hdr[8:10]) {
+		return 0, nil, errDNP3CRC
+	}
+	src = binary.LittleEndian.Uint16(hdr[6:8])
+	// LEN counts CTRL, DST, SRC and the user data, without the CRCs.
+	remain := int(hdr[2]) - 5
+	for remain > 0 {
+		n := remain
+		if n > 16 {
+			n = 16
+		}
+		block := make([]byte, n+2)
+		if _, err = io.ReadFull(r, block); err != nil {
+			return 0, nil, err
+		}
+		if dnp3CRC(block[:n]) != binary.LittleEndian.Uint16(block[n:]) {
+			return 0, nil, errDNP3CRC
+		}
+		user = append(user, block[:n]...)
+		remain -= n
+	}
+	return src, user, nil
+}
+
+var (
+	errDNP3Framing = &net.OpError{Op: "read", Net: "dnp3", Err: errString("bad start octets")}
+	errDNP3CRC     = &net.OpError{Op: "read", Net: "dnp3", Err: errString("CRC mismatch")}
+)
+
+type errString string
+
+func (e errString) Error() string { return string(e) }
+
+// buildDNP3Response assembles the transport and application layers of a
+// response carrying all points as group 30 variation 5 objects.
+func buildDNP3Response(seq uint8, points []float64) []byte {
+	buf := []byte{
+		0xC0 | seq, // transport: FIR|FIN
+		0xC0 | seq, // application: FIR|FIN
+		0x81,       // RESPONSE
+		0x00, 0x00, // IIN
+	}
+	if len(points) > 0 {
+		buf = append(buf, 30, 5, 0x01) // group, variation, 2-byte start-stop
+		buf = binary.LittleEndian.AppendUint16(buf, 0)
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(len(points)-1))
+		for _, v := range points {
+			buf = append(buf, 0x01) // flags: ONLINE
+			buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(float32(v)))
+		}
+	}
+	return buf
+}
+
+// linkDNP3Frame wraps user data in a link-layer frame addressed to dst,
+// inserting a CRC after the header and every 16-byte block.
+func linkDNP3Frame(dst uint16, user []byte) []byte {
+	hdr := []byte{0x05, 0x64, byte(5 + len(user)), 0x44, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint16(hdr[4:6], dst)
+	binary.LittleEndian.PutUint16(hdr[6:8], DNP3Station)
+	frame := append(hdr, 0, 0)
+	binary.LittleEndian.PutUint16(frame[8:10], dnp3CRC(frame[:8]))
+	for len(user) > 0 {
+		n := len(user)
+		if n > 16 {
+			n = 16
+		}
+		frame = append(frame, user[:n]...)
+		frame = append(frame, 0, 0)
+		binary.LittleEndian.PutUint16(frame[len(frame)-2:], dnp3CRC(user[:n]))
+		user = user[n:]
+	}
+	return frame
+}
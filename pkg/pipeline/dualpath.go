@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// DedupInputs enables PRP-style dual-path merging: frames arriving on any
+// listener are deduplicated by (IDCODE, SOC, FRACSEC), so a PMU sending
+// the same stream over two network paths yields one merged stream.
+var DedupInputs bool
+
+// PathStats is the health record of one input path, keyed by its
+// listening port.
+type PathStats struct {
+	Frames     uint64    `json:"frames"`
+	Duplicates uint64    `json:"duplicates"`
+	LastFrame  time.Time `json:"last_frame"`
+}
+
+// dedupKey identifies one frame across paths. The frame type is included
+// so a configuration frame never suppresses the data frame sharing its
+// timestamp.
+type dedupKey struct {
+	idCode  uint16
+	soc     uint32
+	fracSec uint32
+	typ     c37.FrameType
+}
+
+// dedupWindow is how many recently seen frames are remembered; at 50 fps
+// on a handful of streams this covers well over the inter-path delay.
+const dedupWindow = 8192
+
+var (
+	dedupMu   sync.Mutex
+	pathStats = make(map[int]*PathStats)
+	dedupSeen = make(map[dedupKey]struct{})
+	dedupRing []dedupKey
+)
+
+// dedupFrame records the frame against its path and reports whether it is
+// a duplicate already forwarded via the other path. With DedupInputs off
+// it only keeps the path statistics.
+func dedupFrame(port int, data []byte) bool {
+	h, err := c37.DecodeHeader(data)
+	if err != nil {
+		return false
+	}
+	dedupMu.Lock()
+	defer dedupMu.Unlock()
+	ps := pathStats[port]
+	if ps == nil {
+		ps = &PathStats{}
+		pathStats[port] = ps
+	}
+	ps.Frames++
+	ps.LastFrame = time.Now()
+	if !DedupInputs {
+		return false
+	}
+	key := dedupKey{h.IDCode, h.SOC, h.FracSecValue(), h.Type}
+	if _, ok := dedupSeen[key]; ok {
+		ps.Duplicates++
+		return true
+	}
+	dedupSeen[key] = struct{}{}
+	dedupRing = append(dedupRing, key)
+	if len(dedupRing) > dedupWindow {
+		delete(dedupSeen, dedupRing[0])
+		dedupRing = dedupRing[1:]
+	}
+	return false
+}
+
+// PathHealth returns a snapshot of the per-path statistics, keyed by
+// listening port.
+func PathHealth() map[int]PathStats {
+	dedupMu.Lock()
+	defer dedupMu.Unlock()
+	out := make(map[int]PathStats, len(pathStats))
+	for port, ps := range pathStats {
+		out[port] = *ps
+	}
+	return out
+}
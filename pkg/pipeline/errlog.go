@@ -0,0 +1,75 @@
+package pipeline
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// A broken stream produces the same decode error at frame rate, which
+// drowns the log. logRepeats collapses identical lines: the first
+// occurrence is logged verbatim, repeats are only counted, and a summary
+// line goes out at most once per repeatLogInterval.
+
+// repeatLogInterval is the minimum spacing between summary lines for one
+// repeated message.
+const repeatLogInterval = 10 * time.Second
+
+// repeatLogMax caps the number of distinct messages tracked; beyond it
+// new messages are logged uncollapsed rather than growing the map
+// without bound.
+const repeatLogMax = 1024
+
+type repeatEntry struct {
+	pending    uint64 // occurrences since the last line logged
+	total      uint64
+	lastLogged time.Time
+}
+
+var (
+	repeatMu sync.Mutex
+	repeats  = make(map[string]*repeatEntry)
+)
+
+// logRepeats logs a message with identical-line deduplication.
+func logRepeats(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	repeatMu.Lock()
+	e := repeats[msg]
+	if e == nil {
+		if len(repeats) >= repeatLogMax {
+			repeatMu.Unlock()
+			log.Print(msg)
+			return
+		}
+		repeats[msg] = &repeatEntry{total: 1, lastLogged: time.Now()}
+		repeatMu.Unlock()
+		log.Print(msg)
+		return
+	}
+	e.pending++
+	e.total++
+	since := time.Since(e.lastLogged)
+	if since < repeatLogInterval {
+		repeatMu.Unlock()
+		return
+	}
+	n := e.pending
+	e.pending = 0
+	e.lastLogged = time.Now()
+	repeatMu.Unlock()
+	log.Printf("%s (repeated %d times in the last %s)", msg, n, since.Round(time.Second))
+}
+
+// RepeatedLogCounts returns the total occurrences of every collapsed
+// message, including the ones that were logged.
+func RepeatedLogCounts() map[string]uint64 {
+	repeatMu.Lock()
+	defer repeatMu.Unlock()
+	out := make(map[string]uint64, len(repeats))
+	for msg, e := range repeats {
+		out[msg] = e.total
+	}
+	return out
+}
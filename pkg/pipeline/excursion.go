@@ -0,0 +1,165 @@
+package pipeline
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// excursionBand is the per-IDCODE frequency band and ROCOF magnitude the
+// excursion detector watches. A zero bound disables that check.
+type excursionBand struct {
+	Low, High float64 // Hz
+	ROCOF     float64 // Hz/s magnitude
+}
+
+var excursionBands = make(map[uint16]excursionBand)
+
+// ExcursionDir is where disturbance records are written.
+var ExcursionDir = "."
+
+// ExcursionWindow is the number of frames recorded before the first and
+// after the last violating sample of a disturbance.
+var ExcursionWindow = 100
+
+// ParseExcursionBands parses the -excursion flag: comma-separated
+// "IDCODE=low:high:rocof" entries, e.g. "41=49.8:50.2:1.0". IDCODE 0 sets
+// the default band for streams without their own entry.
+func ParseExcursionBands(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		id, bounds, ok := strings.Cut(entry, "=")
+		if !ok {
+			return fmt.Errorf("invalid excursion entry %q, want IDCODE=low:high:rocof", entry)
+		}
+		idCode, err := strconv.ParseUint(id, 10, 16)
+		if err != nil {
+			return fmt.Errorf("invalid IDCODE in excursion entry %q: %v", entry, err)
+		}
+		parts := strings.Split(bounds, ":")
+		if len(parts) != 3 {
+			return fmt.Errorf("invalid excursion band %q, want low:high:rocof", bounds)
+		}
+		var b excursionBand
+		if b.Low, err = strconv.ParseFloat(parts[0], 64); err != nil {
+			return fmt.Errorf("invalid lower bound in excursion entry %q: %v", entry, err)
+		}
+		if b.High, err = strconv.ParseFloat(parts[1], 64); err != nil {
+			return fmt.Errorf("invalid upper bound in excursion entry %q: %v", entry, err)
+		}
+		if b.ROCOF, err = strconv.ParseFloat(parts[2], 64); err != nil {
+			return fmt.Errorf("invalid ROCOF bound in excursion entry %q: %v", entry, err)
+		}
+		excursionBands[uint16(idCode)] = b
+	}
+	return nil
+}
+
+// excursionSample is one buffered row of a disturbance record.
+type excursionSample struct {
+	soc     uint32
+	fracSec uint32
+	freq    float64
+	rocof   float64
+}
+
+// excursionState is the detector state of one PMU: the rolling pre-event
+// buffer and, during a disturbance, the open record.
+type excursionState struct {
+	ring []excursionSample
+	post int
+	f    *os.File
+}
+
+var excursions = make(map[string]*excursionState)
+
+// checkExcursions runs every PMU of a decoded frame through its excursion
+// band and maintains the per-PMU disturbance records.
+func checkExcursions(st *StreamContext, df *c37.DataFrame) {
+	band, ok := excursionBands[st.IDCode]
+	if !ok {
+		band, ok = excursionBands[0]
+	}
+	if !ok {
+		return
+	}
+	for i := range df.PMUs {
+		pmu := &df.PMUs[i]
+		violated := false
+		if band.Low != 0 && pmu.Freq < band.Low {
+			violated = true
+		}
+		if band.High != 0 && pmu.Freq > band.High {
+			violated = true
+		}
+		if band.ROCOF > 0 && (pmu.DFreq > band.ROCOF || pmu.DFreq < -band.ROCOF) {
+			violated = true
+		}
+		recordExcursion(fmt.Sprintf("%d_%d", st.IDCode, i), excursionSample{
+			soc:     df.Header.SOC,
+			fracSec: df.Header.FracSecValue(),
+			freq:    pmu.Freq,
+			rocof:   pmu.DFreq,
+		}, violated)
+	}
+}
+
+// recordExcursion feeds one sample through a PMU's detector: quiet
+// samples roll through the pre-event buffer, a violation opens a CSV
+// record seeded with that buffer, and the record closes ExcursionWindow
+// samples after the last violation.
+func recordExcursion(key string, s excursionSample, violated bool) {
+	stx := excursions[key]
+	if stx == nil {
+		stx = &excursionState{}
+		excursions[key] = stx
+	}
+	if stx.f == nil {
+		stx.ring = append(stx.ring, s)
+		if len(stx.ring) > ExcursionWindow {
+			stx.ring = stx.ring[1:]
+		}
+		if !violated {
+			return
+		}
+		name := filepath.Join(ExcursionDir, fmt.Sprintf("excursion_%s_%s.csv",
+			key, time.Now().UTC().Format("20060102T150405")))
+		f, err := os.Create(name)
+		if err != nil {
+			log.Printf("cannot open disturbance record: %v", err)
+			return
+		}
+		fmt.Fprintln(f, "soc,fracsec,freq,rocof")
+		for _, r := range stx.ring {
+			writeExcursionRow(f, r)
+		}
+		stx.f = f
+		stx.ring = nil
+		stx.post = ExcursionWindow
+		log.Printf("frequency excursion (IDCODE/PMU %s, %.4f Hz, %.4f Hz/s): %s", key, s.freq, s.rocof, name)
+		return
+	}
+	writeExcursionRow(stx.f, s)
+	if violated {
+		stx.post = ExcursionWindow
+		return
+	}
+	stx.post--
+	if stx.post <= 0 {
+		log.Printf("disturbance record complete: %s", stx.f.Name())
+		stx.f.Close()
+		stx.f = nil
+	}
+}
+
+func writeExcursionRow(f *os.File, s excursionSample) {
+	fmt.Fprintf(f, "%d,%d,%.6f,%.6f\n", s.soc, s.fracSec, s.freq, s.rocof)
+}
@@ -0,0 +1,339 @@
+package pipeline
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// The expression language used for drop predicates, expression alarms and
+// derived analog channels. Expressions work per PMU block over these
+// identifiers:
+//
+//	freq, dfreq, fnom     frequency, ROCOF, nominal frequency
+//	freq.mean, freq.min,
+//	freq.max, freq.std    sliding-window frequency statistics (-freq_window)
+//	magN, angN            magnitude/angle (radians) of phasor N
+//	analogN               analog channel N
+//	stat.error            non-zero data-error field
+//	stat.sync             PMU time sync flag
+//	stat.trigger          PMU trigger flag
+//	stat.tq               PMU time-quality code
+//	stat.unlocked         unlocked-time code
+//
+// Operators: || && ! comparisons + - * / and parentheses. Booleans are 1
+// and 0, so predicates and formulas share one evaluator.
+
+// exprEnv resolves identifiers during evaluation.
+type exprEnv func(name string) (float64, error)
+
+// exprNode is one node of a parsed expression.
+type exprNode interface {
+	eval(env exprEnv) (float64, error)
+}
+
+type exprNum float64
+
+func (n exprNum) eval(exprEnv) (float64, error) { return float64(n), nil }
+
+type exprIdent string
+
+func (id exprIdent) eval(env exprEnv) (float64, error) { return env(string(id)) }
+
+type exprUnary struct {
+	op string
+	x  exprNode
+}
+
+func (u exprUnary) eval(env exprEnv) (float64, error) {
+	v, err := u.x.eval(env)
+	if err != nil {
+		return 0, err
+	}
+	if u.op == "!" {
+		return b2f(v == 0), nil
+	}
+	return -v, nil
+}
+
+type exprBinary struct {
+	op   string
+	l, r exprNode
+}
+
+func (b exprBinary) eval(env exprEnv) (float64, error) {
+	l, err := b.l.eval(env)
+	if err != nil {
+		return 0, err
+	}
+	// Short-circuit the logical operators like the source languages the
+	// syntax borrows from.
+	switch b.op {
+	case "||":
+		if l != 0 {
+			return 1, nil
+		}
+		r, err := b.r.eval(env)
+		return b2f(r != 0), err
+	case "&&":
+		if l == 0 {
+			return 0, nil
+		}
+		r, err := b.r.eval(env)
+		return b2f(r != 0), err
+	}
+	r, err := b.r.eval(env)
+	if err != nil {
+		return 0, err
+	}
+	switch b.op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		if r == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		return l / r, nil
+	case "<":
+		return b2f(l < r), nil
+	case "<=":
+		return b2f(l <= r), nil
+	case ">":
+		return b2f(l > r), nil
+	case ">=":
+		return b2f(l >= r), nil
+	case "==":
+		return b2f(l == r), nil
+	case "!=":
+		return b2f(l != r), nil
+	}
+	return 0, fmt.Errorf("unknown operator %q", b.op)
+}
+
+func b2f(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// exprParser is a recursive-descent parser over a token list.
+type exprParser struct {
+	toks []string
+	pos  int
+}
+
+// parseExpr compiles an expression to its AST.
+func parseExpr(src string) (exprNode, error) {
+	toks, err := exprTokens(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{toks: toks}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("in expression %q: %v", src, err)
+	}
+	if p.pos != len(p.toks) {
+		return nil, fmt.Errorf("in expression %q: unexpected %q", src, p.toks[p.pos])
+	}
+	return node, nil
+}
+
+func exprTokens(src string) ([]string, error) {
+	var toks []string
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsDigit(c) || c == '.' && i+1 < len(src) && unicode.IsDigit(rune(src[i+1])):
+			j := i
+			for j < len(src) && (unicode.IsDigit(rune(src[j])) || src[j] == '.') {
+				j++
+			}
+			toks = append(toks, src[i:j])
+			i = j
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(src) && (unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j])) || src[j] == '_' || src[j] == '.') {
+				j++
+			}
+			toks = append(toks, src[i:j])
+			i = j
+		case strings.HasPrefix(src[i:], "||"), strings.HasPrefix(src[i:], "&&"),
+			strings.HasPrefix(src[i:], "<="), strings.HasPrefix(src[i:], ">="),
+			strings.HasPrefix(src[i:], "=="), strings.HasPrefix(src[i:], "!="):
+			toks = append(toks, src[i:i+2])
+			i += 2
+		case strings.ContainsRune("<>!+-*/()", c):
+			toks = append(toks, string(c))
+			i++
+		default:
+			return nil, fmt.Errorf("invalid character %q in expression", c)
+		}
+	}
+	return toks, nil
+}
+
+func (p *exprParser) next() string {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return ""
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	node, err := p.parseAnd()
+	for err == nil && p.next() == "||" {
+		p.pos++
+		var r exprNode
+		if r, err = p.parseAnd(); err == nil {
+			node = exprBinary{"||", node, r}
+		}
+	}
+	return node, err
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	node, err := p.parseCmp()
+	for err == nil && p.next() == "&&" {
+		p.pos++
+		var r exprNode
+		if r, err = p.parseCmp(); err == nil {
+			node = exprBinary{"&&", node, r}
+		}
+	}
+	return node, err
+}
+
+func (p *exprParser) parseCmp() (exprNode, error) {
+	node, err := p.parseAdd()
+	for err == nil {
+		op := p.next()
+		if op != "<" && op != "<=" && op != ">" && op != ">=" && op != "==" && op != "!=" {
+			break
+		}
+		p.pos++
+		var r exprNode
+		if r, err = p.parseAdd(); err == nil {
+			node = exprBinary{op, node, r}
+		}
+	}
+	return node, err
+}
+
+func (p *exprParser) parseAdd() (exprNode, error) {
+	node, err := p.parseMul()
+	for err == nil && (p.next() == "+" || p.next() == "-") {
+		op := p.next()
+		p.pos++
+		var r exprNode
+		if r, err = p.parseMul(); err == nil {
+			node = exprBinary{op, node, r}
+		}
+	}
+	return node, err
+}
+
+func (p *exprParser) parseMul() (exprNode, error) {
+	node, err := p.parseUnary()
+	for err == nil && (p.next() == "*" || p.next() == "/") {
+		op := p.next()
+		p.pos++
+		var r exprNode
+		if r, err = p.parseUnary(); err == nil {
+			node = exprBinary{op, node, r}
+		}
+	}
+	return node, err
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	switch p.next() {
+	case "!", "-":
+		op := p.next()
+		p.pos++
+		x, err := p.parseUnary()
+		return exprUnary{op, x}, err
+	case "(":
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		p.pos++
+		return node, nil
+	case "":
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	tok := p.toks[p.pos]
+	p.pos++
+	if v, err := strconv.ParseFloat(tok, 64); err == nil {
+		return exprNum(v), nil
+	}
+	return exprIdent(tok), nil
+}
+
+// pmuEnv builds the evaluation environment over one PMU block.
+func pmuEnv(st *StreamContext, df *c37.DataFrame, pmu int) exprEnv {
+	return func(name string) (float64, error) {
+		pd := &df.PMUs[pmu]
+		switch name {
+		case "freq":
+			return pd.Freq, nil
+		case "dfreq":
+			return pd.DFreq, nil
+		case "fnom":
+			if st.Cfg != nil && pmu < len(st.Cfg.PMUs) {
+				return st.Cfg.PMUs[pmu].NominalFreq(), nil
+			}
+			return 0, nil
+		case "stat.error":
+			return b2f(pd.Stat.DataError != 0), nil
+		case "stat.sync":
+			return b2f(pd.Stat.PMUSync), nil
+		case "stat.trigger":
+			return b2f(pd.Stat.PMUTrigger), nil
+		case "stat.tq":
+			return float64(pd.Stat.TimeQuality), nil
+		case "stat.unlocked":
+			return float64(pd.Stat.UnlockedTime), nil
+		case "freq.mean", "freq.min", "freq.max", "freq.std":
+			if v, ok := freqStatFor(st.IDCode, pmu, strings.TrimPrefix(name, "freq.")); ok {
+				return v, nil
+			}
+			return 0, fmt.Errorf("%s: frequency window empty (set -freq_window)", name)
+		}
+		for _, pre := range []string{"mag", "ang", "analog"} {
+			idx, found := strings.CutPrefix(name, pre)
+			if !found {
+				continue
+			}
+			i, err := strconv.Atoi(idx)
+			if err != nil || i < 0 {
+				break
+			}
+			switch {
+			case pre == "analog" && i < len(pd.Analogs):
+				return pd.Analogs[i], nil
+			case pre == "mag" && i < len(pd.Phasors):
+				return pd.Phasors[i].Magnitude, nil
+			case pre == "ang" && i < len(pd.Phasors):
+				return pd.Phasors[i].Angle, nil
+			}
+			return 0, fmt.Errorf("channel %s out of range", name)
+		}
+		return 0, fmt.Errorf("unknown identifier %q", name)
+	}
+}
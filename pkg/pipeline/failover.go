@@ -0,0 +1,107 @@
+package pipeline
+
+import (
+	"context"
+	"log"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// BackupAddr is the secondary destination ("host:port") of the TCP
+// output. On sustained failure of the primary the stream fails over to
+// it, and fails back once the primary is reachable again. Empty disables
+// failover.
+var BackupAddr string
+
+// FailoverAfter is the number of consecutive failed connection attempts
+// to the primary before failing over.
+var FailoverAfter = 5
+
+// FailbackProbe is how often the primary is probed while the stream runs
+// on the backup.
+var FailbackProbe = 30 * time.Second
+
+// Failover metrics, read by the control API.
+var (
+	FailoverCount uint64
+	FailbackCount uint64
+	// onBackup is 1 while the stream is served by the backup destination.
+	onBackup uint32
+)
+
+// StartTCPClientFailover runs the TCP client output with automatic
+// primary/secondary failover. Without a BackupAddr it behaves exactly
+// like StartTCPClient.
+func StartTCPClientFailover(ctx context.Context, out *OutputStream, bindAddr string, bo Backoff) error {
+	if BackupAddr == "" {
+		return StartTCPClient(ctx, out, bindAddr, bo)
+	}
+	primary := out.Addr()
+	pbo := bo
+	if pbo.MaxAttempts == 0 || pbo.MaxAttempts > FailoverAfter {
+		pbo.MaxAttempts = FailoverAfter
+	}
+	for {
+		err := runTCPClient(ctx, out, primary, bindAddr, pbo)
+		if ctx.Err() != nil {
+			return err
+		}
+		atomic.AddUint64(&FailoverCount, 1)
+		atomic.StoreUint32(&onBackup, 1)
+		log.Printf("failing over from %s to backup %s", primary, BackupAddr)
+
+		bctx, cancel := context.WithCancel(ctx)
+		go probePrimary(bctx, primary, bindAddr, cancel)
+		runTCPClient(bctx, out, BackupAddr, bindAddr, bo)
+		recovered := bctx.Err() != nil && ctx.Err() == nil
+		cancel()
+		atomic.StoreUint32(&onBackup, 0)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if recovered {
+			atomic.AddUint64(&FailbackCount, 1)
+			log.Printf("primary %s reachable again, failing back", primary)
+		} else {
+			log.Printf("backup %s is down too, retrying primary %s", BackupAddr, primary)
+		}
+	}
+}
+
+// probePrimary dials the primary every FailbackProbe and cancels the
+// backup's context once a connection succeeds, triggering failback.
+func probePrimary(ctx context.Context, addr, bindAddr string, cancel context.CancelFunc) {
+	dialer := net.Dialer{Timeout: 5 * time.Second}
+	if bindAddr != "" {
+		if ip := net.ParseIP(bindAddr); ip != nil {
+			dialer.LocalAddr = &net.TCPAddr{IP: ip}
+		}
+	}
+	ticker := time.NewTicker(FailbackProbe)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			conn, err := dialer.DialContext(ctx, "tcp", addr)
+			if err != nil {
+				continue
+			}
+			conn.Close()
+			cancel()
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// FailoverStatus reports the failover metrics for the control API.
+func FailoverStatus() map[string]any {
+	return map[string]any{
+		"backup":    BackupAddr,
+		"on_backup": atomic.LoadUint32(&onBackup) == 1,
+		"failovers": atomic.LoadUint64(&FailoverCount),
+		"failbacks": atomic.LoadUint64(&FailbackCount),
+	}
+}
@@ -0,0 +1,106 @@
+package pipeline
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/hex"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// ProcessFile replays a capture file (one hex-encoded frame per line)
+// through the same pipeline as live traffic, pacing data frames by the
+// data rate announced in the capture's configuration frame. Cancelling ctx
+// stops the replay early.
+func ProcessFile(ctx context.Context, path string, outs *Outputs) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var in io.Reader = f
+	switch filepath.Ext(path) {
+	case ".gz":
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		in = gr
+	case ".zst":
+		zr, err := zstd.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		in = zr
+	}
+	log.Printf("replaying capture file %s", path)
+
+	interval := time.Duration(0)
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 256*1024), 256*1024)
+	line := 0
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		line++
+		text := scanner.Text()
+		if text == "" {
+			continue
+		}
+		data, err := hex.DecodeString(text)
+		if err != nil {
+			log.Printf("%s:%d: not a hex frame, skipping: %v", path, line, err)
+			continue
+		}
+		HandleFrame(data, nil, outs)
+		if h, err := c37.DecodeHeader(data); err == nil {
+			pipelineMu.Lock()
+			if st := lookupStream(h.IDCode); st != nil && st.Cfg != nil && st.Cfg.DataRate != 0 {
+				interval = rateInterval(int(st.Cfg.DataRate))
+			}
+			pipelineMu.Unlock()
+			if h.Type == c37.TypeData && interval > 0 {
+				select {
+				case <-time.After(interval):
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// StartFileWriter appends every frame from frames to the capture file as
+// one hex-encoded line until ctx is cancelled. The file rotates by size
+// and age per the Rotate* settings.
+func StartFileWriter(ctx context.Context, out *OutputStream) error {
+	f, err := newRotatingFile(out.FilePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	log.Printf("writing frames to %s", out.FilePath)
+	for {
+		var frame []byte
+		select {
+		case frame = <-out.Frames:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if err := f.WriteLine(hex.EncodeToString(frame)); err != nil {
+			return err
+		}
+	}
+}
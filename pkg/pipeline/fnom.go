@@ -0,0 +1,58 @@
+package pipeline
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// fnomOverrides maps IDCODEs to a forced nominal frequency FNOM word.
+// Some test sets report FNOM incorrectly, which breaks 16-bit integer
+// frequency decoding; the override fixes the decode context without
+// trusting the stream. IDCODE 0 is the default for all streams.
+var fnomOverrides = make(map[uint16]uint16)
+
+// ParseFNomOverrides parses the -fnom flag: comma-separated "IDCODE=Hz"
+// entries where Hz is 50 or 60, e.g. "41=50,42=60".
+func ParseFNomOverrides(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		id, hz, ok := strings.Cut(entry, "=")
+		if !ok {
+			return fmt.Errorf("invalid FNOM override %q, want IDCODE=50 or IDCODE=60", entry)
+		}
+		idCode, err := strconv.ParseUint(id, 10, 16)
+		if err != nil {
+			return fmt.Errorf("invalid IDCODE in FNOM override %q: %v", entry, err)
+		}
+		switch hz {
+		case "50":
+			fnomOverrides[uint16(idCode)] = 1
+		case "60":
+			fnomOverrides[uint16(idCode)] = 0
+		default:
+			return fmt.Errorf("invalid nominal frequency %q in FNOM override, want 50 or 60", hz)
+		}
+	}
+	return nil
+}
+
+// applyFNomOverride forces the FNOM word of every PMU block in a freshly
+// decoded configuration, so both data frame decoding and the re-encoded
+// outgoing configuration use the corrected nominal frequency.
+func applyFNomOverride(idCode uint16, cfg *c37.CfgFrame2) {
+	fnom, ok := fnomOverrides[idCode]
+	if !ok {
+		fnom, ok = fnomOverrides[0]
+	}
+	if !ok {
+		return
+	}
+	for i := range cfg.PMUs {
+		cfg.PMUs[i].FNom = cfg.PMUs[i].FNom&^1 | fnom
+	}
+}
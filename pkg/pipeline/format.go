@@ -0,0 +1,81 @@
+package pipeline
+
+import (
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// IntOutput converts floating-point phasor, analog and FREQ/DFREQ
+// channels to 16-bit integer format on output, halving the data frame
+// size toward constrained links.
+var IntOutput bool
+
+// Default PHUNIT factors applied to float channels that carry none, taken
+// from the standard's example configuration: roughly 300 kV respectively
+// 1.5 kA full scale over the int16 range.
+const (
+	intVoltFactor = 915527
+	intCurrFactor = 45776
+)
+
+// OutputCoords converts phasors to one coordinate form on output:
+// "polar", "rect" or empty to keep the source form. The POLAR bit of the
+// regenerated FORMAT word follows the conversion.
+var OutputCoords string
+
+// convertFormat reports whether any output format conversion is active.
+func convertFormat() bool {
+	return IntOutput || OutputCoords != ""
+}
+
+// outFmtCfgs caches the converted output configuration per stream for
+// re-encoding its data frames.
+var outFmtCfgs = make(map[uint16]*c37.CfgFrame2)
+
+// outputFormatConfig returns a copy of cfg with the configured format
+// conversions applied. With IntOutput, phasor and analog channels switch
+// to 16-bit integer format; float streams usually carry no PHUNIT factor,
+// so channels without one get a default full-scale factor, while existing
+// factors are kept. With OutputCoords, the POLAR bit is forced to the
+// requested coordinate form.
+func outputFormatConfig(cfg *c37.CfgFrame2) *c37.CfgFrame2 {
+	out := *cfg
+	out.PMUs = append([]c37.PMUConfig(nil), cfg.PMUs...)
+	for i := range out.PMUs {
+		pc := &out.PMUs[i]
+		switch OutputCoords {
+		case "polar":
+			pc.Format.POLAR = true
+		case "rect":
+			pc.Format.POLAR = false
+		}
+		if !IntOutput || (!pc.Format.PHASORS && !pc.Format.ANALOGS && !pc.Format.FREQ_DFREQ) {
+			continue
+		}
+		pc.Format.PHASORS = false
+		pc.Format.ANALOGS = false
+		pc.Format.FREQ_DFREQ = false
+		pc.PhasorUnits = append([]c37.PhasorUnit(nil), pc.PhasorUnits...)
+		for j := range pc.PhasorUnits {
+			u := &pc.PhasorUnits[j]
+			if u.Factor == 0 {
+				if u.IsCurrent {
+					u.Factor = intCurrFactor
+				} else {
+					u.Factor = intVoltFactor
+				}
+			}
+		}
+	}
+	return &out
+}
+
+// convertDataFormat re-encodes a decoded data frame with the stream's
+// converted output configuration. Frames from streams whose configuration
+// has not been converted yet pass through unchanged.
+func convertDataFormat(h *c37.Header, df *c37.DataFrame, out []byte) []byte {
+	fc := outFmtCfgs[h.IDCode]
+	if fc == nil {
+		return out
+	}
+	return c37.EncodeDataFrame(df, fc)
+}
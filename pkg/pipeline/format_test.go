@@ -0,0 +1,113 @@
+package pipeline
+
+import (
+	"math"
+	"testing"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// floatTestCfg builds a single-PMU all-float configuration as a live PMU
+// would announce it: float channels carry no PHUNIT factor.
+func floatTestCfg(id uint16) *c37.CfgFrame2 {
+	return &c37.CfgFrame2{
+		Header:   c37.Header{Version: c37.Version2011, IDCode: id},
+		TimeBase: 1_000_000,
+		NumPMU:   1,
+		DataRate: 50,
+		PMUs: []c37.PMUConfig{{
+			StationName: "FLOAT PMU",
+			IDCode:      id,
+			Format:      c37.Format{FREQ_DFREQ: true, ANALOGS: true, PHASORS: true, POLAR: true},
+			NumPhasors:  2,
+			PhasorNames: []string{"VA", "IA"},
+			PhasorUnits: []c37.PhasorUnit{{}, {IsCurrent: true}},
+			FNom:        1, // 50 Hz
+		}},
+	}
+}
+
+func TestOutputFormatConfig(t *testing.T) {
+	defer func() { IntOutput, OutputCoords = false, "" }()
+	IntOutput = true
+	cfg := floatTestCfg(7)
+	out := outputFormatConfig(cfg)
+	pc := &out.PMUs[0]
+	if pc.Format.PHASORS || pc.Format.ANALOGS || pc.Format.FREQ_DFREQ {
+		t.Errorf("converted FORMAT = %+v, want all-integer", pc.Format)
+	}
+	if pc.PhasorUnits[0].Factor != intVoltFactor || pc.PhasorUnits[1].Factor != intCurrFactor {
+		t.Errorf("default PHUNIT factors = %d/%d, want %d/%d",
+			pc.PhasorUnits[0].Factor, pc.PhasorUnits[1].Factor, intVoltFactor, intCurrFactor)
+	}
+	if cfg.PMUs[0].Format != (c37.Format{FREQ_DFREQ: true, ANALOGS: true, PHASORS: true, POLAR: true}) {
+		t.Error("source configuration was mutated")
+	}
+
+	IntOutput = false
+	OutputCoords = "rect"
+	if out := outputFormatConfig(cfg); out.PMUs[0].Format.POLAR {
+		t.Error("OutputCoords rect did not clear the POLAR bit")
+	}
+	OutputCoords = "polar"
+	if out := outputFormatConfig(cfg); !out.PMUs[0].Format.POLAR {
+		t.Error("OutputCoords polar did not set the POLAR bit")
+	}
+}
+
+// TestConvertedFramesDecodeWithOutputCfg mirrors the decoded sinks: a
+// frame int16-converted by convertDataFormat must decode against the
+// configuration outputDecodeCfg resolves, not the float input one.
+func TestConvertedFramesDecodeWithOutputCfg(t *testing.T) {
+	defer resetStreams()
+	defer func() {
+		IntOutput = false
+		delete(outFmtCfgs, 7)
+	}()
+	IntOutput = true
+	cfg := floatTestCfg(7)
+	st := streamFor(7)
+	st.Cfg = cfg
+	outFmtCfgs[7] = outputFormatConfig(cfg)
+
+	df := &c37.DataFrame{
+		Header: c37.Header{Version: c37.Version2011, IDCode: 7, SOC: 1_700_000_000},
+		PMUs: []c37.PMUData{{
+			Phasors: []c37.Phasor{{Magnitude: 230000, Angle: 0.5}, {Magnitude: 400, Angle: -1.25}},
+			Freq:    49.95,
+			DFreq:   0.02,
+		}},
+	}
+	raw := c37.EncodeDataFrame(df, cfg)
+	out := convertDataFormat(&df.Header, df, raw)
+	if len(out) >= len(raw) {
+		t.Fatalf("converted frame is %d bytes, want smaller than the %d-byte float frame", len(out), len(raw))
+	}
+
+	dcfg := outputDecodeCfg(7)
+	if dcfg != outFmtCfgs[7] {
+		t.Fatal("outputDecodeCfg did not resolve the converted configuration")
+	}
+	got, err := c37.DecodeDataFrame(out, dcfg)
+	if err != nil {
+		t.Fatalf("decoding the converted frame: %v", err)
+	}
+	// One count of the default PHUNIT factor is the integer resolution.
+	voltTol := float64(intVoltFactor) * 1e-5
+	if math.Abs(got.PMUs[0].Phasors[0].Magnitude-230000) > voltTol {
+		t.Errorf("voltage magnitude = %v, want 230000 within %v", got.PMUs[0].Phasors[0].Magnitude, voltTol)
+	}
+	if math.Abs(got.PMUs[0].Freq-49.95) > 1e-3 {
+		t.Errorf("FREQ = %v, want 49.95", got.PMUs[0].Freq)
+	}
+
+	// Without a converted configuration cached, frames pass through
+	// unchanged and the stream's own configuration applies.
+	delete(outFmtCfgs, 7)
+	if fr := convertDataFormat(&df.Header, df, raw); &fr[0] != &raw[0] {
+		t.Error("convertDataFormat re-encoded a stream without a converted configuration")
+	}
+	if dcfg := outputDecodeCfg(7); dcfg != cfg {
+		t.Error("outputDecodeCfg did not fall back to the input configuration")
+	}
+}
@@ -0,0 +1,121 @@
+package pipeline
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// FreqStatsWindow is the length of the sliding window the per-PMU
+// frequency statistics are computed over. Zero disables the collection.
+var FreqStatsWindow time.Duration
+
+// freqSample is one windowed frequency observation.
+type freqSample struct {
+	at   time.Time
+	freq float64
+}
+
+// freqWindow holds the samples of one PMU inside the window.
+type freqWindow struct {
+	samples []freqSample
+}
+
+var (
+	freqMu      sync.Mutex
+	freqWindows = make(map[string]*freqWindow)
+)
+
+// recordFreqStats feeds the frequencies of a decoded frame into the
+// per-PMU sliding windows.
+func recordFreqStats(st *StreamContext, df *c37.DataFrame) {
+	if FreqStatsWindow <= 0 {
+		return
+	}
+	now := time.Now()
+	freqMu.Lock()
+	defer freqMu.Unlock()
+	for i := range df.PMUs {
+		key := fmt.Sprintf("%d/%d", st.IDCode, i)
+		w := freqWindows[key]
+		if w == nil {
+			w = &freqWindow{}
+			freqWindows[key] = w
+		}
+		w.samples = append(w.samples, freqSample{now, df.PMUs[i].Freq})
+		for len(w.samples) > 0 && now.Sub(w.samples[0].at) > FreqStatsWindow {
+			w.samples = w.samples[1:]
+		}
+	}
+}
+
+// FreqStatSnapshot is the windowed frequency statistics of one PMU.
+type FreqStatSnapshot struct {
+	Samples int     `json:"samples"`
+	Mean    float64 `json:"mean"`
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max"`
+	StdDev  float64 `json:"stddev"`
+}
+
+// compute derives the statistics of one window.
+func (w *freqWindow) compute() FreqStatSnapshot {
+	s := FreqStatSnapshot{Samples: len(w.samples)}
+	if s.Samples == 0 {
+		return s
+	}
+	s.Min, s.Max = w.samples[0].freq, w.samples[0].freq
+	for _, smp := range w.samples {
+		s.Mean += smp.freq
+		s.Min = math.Min(s.Min, smp.freq)
+		s.Max = math.Max(s.Max, smp.freq)
+	}
+	s.Mean /= float64(s.Samples)
+	for _, smp := range w.samples {
+		d := smp.freq - s.Mean
+		s.StdDev += d * d
+	}
+	s.StdDev = math.Sqrt(s.StdDev / float64(s.Samples))
+	return s
+}
+
+// freqStatFor resolves one freq.* identifier for the expression
+// environment; ok is false while the window is empty.
+func freqStatFor(idCode uint16, pmu int, stat string) (float64, bool) {
+	freqMu.Lock()
+	w := freqWindows[fmt.Sprintf("%d/%d", idCode, pmu)]
+	var snap FreqStatSnapshot
+	if w != nil {
+		snap = w.compute()
+	}
+	freqMu.Unlock()
+	if snap.Samples == 0 {
+		return 0, false
+	}
+	switch stat {
+	case "mean":
+		return snap.Mean, true
+	case "min":
+		return snap.Min, true
+	case "max":
+		return snap.Max, true
+	case "std":
+		return snap.StdDev, true
+	}
+	return 0, false
+}
+
+// FreqStats returns the windowed statistics of all PMUs, keyed
+// "IDCODE/pmu", for the control API.
+func FreqStats() map[string]FreqStatSnapshot {
+	freqMu.Lock()
+	defer freqMu.Unlock()
+	out := make(map[string]FreqStatSnapshot, len(freqWindows))
+	for key, w := range freqWindows {
+		out[key] = w.compute()
+	}
+	return out
+}
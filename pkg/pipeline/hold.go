@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// statDataInvalid is the STAT data-error code repeated hold frames carry.
+const statDataInvalid = 0x8000 // bits 15-14 = 10: test/invalid data
+
+// StartHold keeps the output cadence alive through input gaps: it watches
+// every stream at the output rate and, for streams silent for more than
+// two reporting intervals, repeats the last data frame with STAT marked
+// invalid until the input recovers or ctx is cancelled. Downstream
+// applications with strict cadence expectations don't trip on the outage.
+func StartHold(ctx context.Context, outs *Outputs) {
+	rate := outs.Rate()
+	if rate == 0 {
+		log.Printf("last-value hold disabled: no output rate configured")
+		return
+	}
+	interval := rateInterval(rate)
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	holding := make(map[uint16]bool)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+		pipelineMu.Lock()
+		for _, st := range Streams() {
+			if st.Cfg == nil || st.LastData == nil {
+				continue
+			}
+			stalled := time.Since(st.Stats.LastFrame) > 2*interval
+			if stalled != holding[st.IDCode] {
+				holding[st.IDCode] = stalled
+				if stalled {
+					log.Printf("IDCODE %d: input stalled, holding last values", st.IDCode)
+				} else {
+					log.Printf("IDCODE %d: input recovered, hold released", st.IDCode)
+				}
+			}
+			if !stalled {
+				continue
+			}
+			publishHoldFrame(st, outs)
+		}
+		pipelineMu.Unlock()
+	}
+}
+
+// publishHoldFrame re-emits the stream's last values with a fresh
+// timestamp and invalid STAT, running the same output stages a live
+// frame would.
+func publishHoldFrame(st *StreamContext, outs *Outputs) {
+	df := *st.LastData
+	df.PMUs = append([]c37.PMUData(nil), df.PMUs...)
+	now := time.Now()
+	tb := st.Cfg.TimeBase
+	if tb == 0 {
+		tb = 1_000_000
+	}
+	df.Header.SOC = uint32(now.Unix())
+	frac := uint32(uint64(now.Nanosecond()) * uint64(tb) / 1e9)
+	df.Header.FracSec = df.Header.FracSec&0xFF000000 | frac&0x00FFFFFF
+	for i := range df.PMUs {
+		df.PMUs[i].Stat.Raw = df.PMUs[i].Stat.Raw&^0xC000 | statDataInvalid
+	}
+	st.Stats.FramesOut++
+	// LastData already carries the power and derived channels: the live
+	// path appends them to the decoded frame in place. The hold frame is
+	// therefore only re-encoded, never re-injected, or its analog count
+	// would outgrow the advertised configuration.
+	out := c37.EncodeDataFrame(&df, st.Cfg)
+	if convertFormat() {
+		out = convertDataFormat(&df.Header, &df, out)
+	}
+	out = rewriteIDCode(out)
+	if !routeFrame(st.IDCode, out) {
+		outs.Publish(out)
+	}
+}
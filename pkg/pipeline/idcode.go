@@ -0,0 +1,31 @@
+package pipeline
+
+import (
+	"encoding/binary"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// OutputIDCode replaces the header IDCODE of every outgoing frame, so the
+// reduced stream registers at the downstream PDC as its own device
+// instead of impersonating the source PMU. 0 keeps the source IDCODEs.
+var OutputIDCode uint16
+
+// OutputStation replaces the STN field of the outgoing configuration
+// frame's PMU blocks, so downstream systems show a meaningful name like
+// "REDUCTOR_WEST" instead of the source PMU's. The encoder pads or
+// truncates it to the 16 bytes the field holds. Empty keeps the source
+// names.
+var OutputStation string
+
+// rewriteIDCode returns the frame with the header IDCODE replaced by
+// OutputIDCode and the CRC recomputed, or the frame unchanged when no
+// override is configured.
+func rewriteIDCode(frame []byte) []byte {
+	if OutputIDCode == 0 || len(frame) < c37.CommonHeaderSize+2 {
+		return frame
+	}
+	out := append([]byte(nil), frame[:len(frame)-2]...)
+	binary.BigEndian.PutUint16(out[4:6], OutputIDCode)
+	return c37.AppendCRC(out)
+}
@@ -0,0 +1,79 @@
+package pipeline
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// resetStreams empties the stream registry between tests that populate it.
+func resetStreams() {
+	streamsMu.Lock()
+	streams = make(map[uint16]*StreamContext)
+	streamsMu.Unlock()
+}
+
+func TestRewriteIDCode(t *testing.T) {
+	defer func() { OutputIDCode = 0 }()
+	h := c37.Header{Type: c37.TypeData, Version: c37.Version2011, IDCode: 7}
+	buf := make([]byte, c37.CommonHeaderSize)
+	h.FrameSize = uint16(len(buf) + 2)
+	h.Encode(buf)
+	frame := c37.AppendCRC(buf)
+
+	OutputIDCode = 0
+	if got := rewriteIDCode(frame); &got[0] != &frame[0] {
+		t.Error("rewriteIDCode copied the frame with no override configured")
+	}
+
+	OutputIDCode = 99
+	out := rewriteIDCode(frame)
+	if err := c37.VerifyCRC(out); err != nil {
+		t.Fatalf("rewritten frame: %v", err)
+	}
+	if id := binary.BigEndian.Uint16(out[4:6]); id != 99 {
+		t.Errorf("rewritten IDCODE = %d, want 99", id)
+	}
+	if id := binary.BigEndian.Uint16(frame[4:6]); id != 7 {
+		t.Errorf("source frame mutated: IDCODE = %d, want 7", id)
+	}
+}
+
+func TestOutputDecodeCfgRewritten(t *testing.T) {
+	defer resetStreams()
+	defer func() { OutputIDCode = 0 }()
+	cfg := &c37.CfgFrame2{Header: c37.Header{IDCode: 7}, NumPMU: 1}
+	streamFor(7).Cfg = cfg
+	streamFor(8) // a second stream that never got a configuration
+
+	OutputIDCode = 99
+	if got := outputDecodeCfg(99); got != cfg {
+		t.Errorf("outputDecodeCfg(99) = %p, want the source stream's config", got)
+	}
+	if lookupStream(99) != nil {
+		t.Error("resolving the rewritten IDCODE created a ghost stream context")
+	}
+
+	OutputIDCode = 0
+	if got := outputDecodeCfg(7); got != cfg {
+		t.Error("outputDecodeCfg(7) did not return the stream's config")
+	}
+	if got := outputDecodeCfg(99); got != nil {
+		t.Errorf("outputDecodeCfg(99) = %p for an unknown stream, want nil", got)
+	}
+}
+
+func TestOutputDecodeCfgLowestSourceWins(t *testing.T) {
+	defer resetStreams()
+	defer func() { OutputIDCode = 0 }()
+	cfgLow := &c37.CfgFrame2{Header: c37.Header{IDCode: 3}, NumPMU: 1}
+	cfgHigh := &c37.CfgFrame2{Header: c37.Header{IDCode: 12}, NumPMU: 1}
+	streamFor(12).Cfg = cfgHigh
+	streamFor(3).Cfg = cfgLow
+
+	OutputIDCode = 99
+	if got := outputDecodeCfg(99); got != cfgLow {
+		t.Errorf("outputDecodeCfg(99) did not pick the lowest source IDCODE")
+	}
+}
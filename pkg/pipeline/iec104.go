@@ -0,0 +1,239 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// IEC104CommonAddr is the ASDU common address of the embedded 104 server.
+var IEC104CommonAddr uint16 = 1
+
+// iec104Point maps one information object address onto a channel: a
+// phasor magnitude, or with freq set the PMU frequency.
+type iec104Point struct {
+	ioa  uint32
+	ref  ChannelRef
+	freq bool
+}
+
+var iec104Points []iec104Point
+
+// ParseIEC104Map parses the -iec104_map flag: comma-separated
+// "IOA=IDCODE:pmu:phasor" entries transmitting a phasor magnitude, or
+// "IOA=IDCODE:pmu:f" the PMU frequency, e.g. "1001=41:0:0,1002=41:0:f".
+func ParseIEC104Map(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		addr, ch, ok := strings.Cut(entry, "=")
+		if !ok {
+			return fmt.Errorf("invalid 104 map entry %q, want IOA=IDCODE:pmu:phasor", entry)
+		}
+		ioa, err := strconv.ParseUint(addr, 10, 24)
+		if err != nil || ioa == 0 {
+			return fmt.Errorf("invalid information object address in %q", entry)
+		}
+		p := iec104Point{ioa: uint32(ioa)}
+		if rest, found := strings.CutSuffix(ch, ":f"); found {
+			p.freq = true
+			ch = rest + ":0"
+		}
+		if p.ref, err = parseChannelRef(ch); err != nil {
+			return fmt.Errorf("invalid channel in 104 map entry %q: %v", entry, err)
+		}
+		iec104Points = append(iec104Points, p)
+	}
+	sort.Slice(iec104Points, func(i, j int) bool { return iec104Points[i].ioa < iec104Points[j].ioa })
+	return nil
+}
+
+// iec104Conn is one connected 104 master. Measurements flow only after
+// the master has activated the link with STARTDT.
+type iec104Conn struct {
+	mu      sync.Mutex
+	conn    net.Conn
+	active  bool
+	sendSeq uint16
+	recvSeq uint16
+}
+
+var (
+	iec104Mu    sync.Mutex
+	iec104Conns = make(map[*iec104Conn]struct{})
+)
+
+// StartIEC104Server accepts 104 masters on addr until ctx is cancelled.
+func StartIEC104Server(ctx context.Context, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	log.Printf("IEC 104 server listening on %s (common address %d, %d points)",
+		addr, IEC104CommonAddr, len(iec104Points))
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		c := &iec104Conn{conn: conn}
+		iec104Mu.Lock()
+		iec104Conns[c] = struct{}{}
+		iec104Mu.Unlock()
+		go c.serve()
+	}
+}
+
+// serve handles the control traffic of one master: STARTDT/STOPDT and
+// TESTFR U-frames and the receive sequence of I/S-frames.
+func (c *iec104Conn) serve() {
+	defer func() {
+		iec104Mu.Lock()
+		delete(iec104Conns, c)
+		iec104Mu.Unlock()
+		c.conn.Close()
+	}()
+	hdr := make([]byte, 2)
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(5 * time.Minute))
+		if _, err := io.ReadFull(c.conn, hdr); err != nil {
+			return
+		}
+		if hdr[0] != 0x68 || hdr[1] < 4 {
+			log.Printf("IEC 104 %s: bad APCI", c.conn.RemoteAddr())
+			return
+		}
+		apdu := make([]byte, hdr[1])
+		if _, err := io.ReadFull(c.conn, apdu); err != nil {
+			return
+		}
+		c.mu.Lock()
+		switch {
+		case apdu[0]&0x03 == 0x03: // U-format
+			switch apdu[0] {
+			case 0x07: // STARTDT act
+				c.active = true
+				c.conn.Write([]byte{0x68, 4, 0x0B, 0, 0, 0})
+			case 0x13: // STOPDT act
+				c.active = false
+				c.conn.Write([]byte{0x68, 4, 0x23, 0, 0, 0})
+			case 0x43: // TESTFR act
+				c.conn.Write([]byte{0x68, 4, 0x83, 0, 0, 0})
+			}
+		case apdu[0]&0x01 == 0: // I-format; only the sequence matters here
+			c.recvSeq++
+		}
+		c.mu.Unlock()
+	}
+}
+
+// sendASDU wraps an ASDU in an I-format APCI and writes it out.
+func (c *iec104Conn) sendASDU(asdu []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.active {
+		return nil
+	}
+	apdu := make([]byte, 6, 6+len(asdu))
+	apdu[0] = 0x68
+	apdu[1] = byte(4 + len(asdu))
+	binary.LittleEndian.PutUint16(apdu[2:4], c.sendSeq<<1)
+	binary.LittleEndian.PutUint16(apdu[4:6], c.recvSeq<<1)
+	c.sendSeq++
+	_, err := c.conn.Write(append(apdu, asdu...))
+	return err
+}
+
+// publishIEC104 spontaneously transmits the mapped measurements of one
+// reduced data frame as M_ME_TF (type 36) objects to every active master.
+func publishIEC104(st *StreamContext, df *c37.DataFrame) {
+	if len(iec104Points) == 0 {
+		return
+	}
+	iec104Mu.Lock()
+	conns := make([]*iec104Conn, 0, len(iec104Conns))
+	for c := range iec104Conns {
+		conns = append(conns, c)
+	}
+	iec104Mu.Unlock()
+	if len(conns) == 0 {
+		return
+	}
+	var timeBase uint32
+	if st.Cfg != nil {
+		timeBase = st.Cfg.TimeBase
+	}
+	ts := cp56Time(df.Header.Timestamp(timeBase))
+	var objs []byte
+	var count int
+	for _, p := range iec104Points {
+		if p.ref.IDCode != st.IDCode || p.ref.PMU >= len(df.PMUs) {
+			continue
+		}
+		pmu := &df.PMUs[p.ref.PMU]
+		var v float64
+		if p.freq {
+			v = pmu.Freq
+		} else if p.ref.Phasor < len(pmu.Phasors) {
+			v = pmu.Phasors[p.ref.Phasor].Magnitude
+		} else {
+			continue
+		}
+		objs = append(objs, byte(p.ioa), byte(p.ioa>>8), byte(p.ioa>>16))
+		objs = binary.LittleEndian.AppendUint32(objs, math.Float32bits(float32(v)))
+		objs = append(objs, 0) // QDS: good
+		objs = append(objs, ts...)
+		count++
+	}
+	if count == 0 {
+		return
+	}
+	asdu := []byte{
+		36,          // M_ME_TF: short float with CP56Time2a
+		byte(count), // SQ=0, individual addresses
+		3, 0,        // COT: spontaneous
+		byte(IEC104CommonAddr), byte(IEC104CommonAddr >> 8),
+	}
+	asdu = append(asdu, objs...)
+	for _, c := range conns {
+		if err := c.sendASDU(asdu); err != nil {
+			log.Printf("IEC 104 %s: %v", c.conn.RemoteAddr(), err)
+		}
+	}
+}
+
+// cp56Time encodes a timestamp as CP56Time2a.
+func cp56Time(t time.Time) []byte {
+	ms := uint16(t.Second()*1000 + t.Nanosecond()/1e6)
+	dow := byte(t.Weekday())
+	if dow == 0 {
+		dow = 7
+	}
+	return []byte{
+		byte(ms), byte(ms >> 8),
+		byte(t.Minute()),
+		byte(t.Hour()),
+		byte(t.Day()) | dow<<5,
+		byte(t.Month()),
+		byte(t.Year() - 2000),
+	}
+}
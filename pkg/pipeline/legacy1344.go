@@ -0,0 +1,56 @@
+package pipeline
+
+import (
+	"log"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// Legacy1344IDCode maps IEEE 1344 input onto this C37.118 stream IDCODE.
+// 1344 frames carry no ID of their own, so one legacy PMU per listener is
+// supported. 0 disables legacy decoding.
+var Legacy1344IDCode uint16
+
+var legacy1344NoCfg bool
+
+// convert1344 translates one IEEE 1344 frame into its C37.118-2011
+// equivalent so it can flow through the normal pipeline. It returns nil
+// for frames that cannot or need not be forwarded (ASCII headers, data
+// before the configuration arrived, decode failures).
+func convert1344(data []byte) []byte {
+	pipelineMu.Lock()
+	defer pipelineMu.Unlock()
+	typ, err := c37.Classify1344(data)
+	if err != nil {
+		log.Printf("cannot classify 1344 frame: %v", err)
+		return nil
+	}
+	switch typ {
+	case c37.Type1344Cfg:
+		cfg, err := c37.Decode1344Config(data, Legacy1344IDCode)
+		if err != nil {
+			log.Printf("cannot decode 1344 config frame: %v", err)
+			return nil
+		}
+		legacy1344NoCfg = false
+		return c37.EncodeConfigFrame2(cfg)
+	case c37.Type1344Data:
+		st := lookupStream(Legacy1344IDCode)
+		if st == nil || st.Cfg == nil {
+			if !legacy1344NoCfg {
+				legacy1344NoCfg = true
+				log.Printf("dropping 1344 data frames until a configuration frame arrives")
+			}
+			return nil
+		}
+		df, err := c37.Decode1344Data(data, st.Cfg)
+		if err != nil {
+			st.Stats.DecodeErrors++
+			log.Printf("cannot decode 1344 data frame: %v", err)
+			return nil
+		}
+		return c37.EncodeDataFrame(df, st.Cfg)
+	}
+	// ASCII header frames have no C37.118 consumer downstream.
+	return nil
+}
@@ -0,0 +1,491 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// lastOutCfg is the most recent configuration frame forwarded to the
+// output, kept for idle heartbeats and late-joining consumers.
+var (
+	lastOutCfgMu sync.Mutex
+	lastOutCfg   []byte
+)
+
+// LastOutputCfg returns the configuration frame the output stream currently
+// advertises, or nil when none has been forwarded yet.
+func LastOutputCfg() []byte {
+	lastOutCfgMu.Lock()
+	defer lastOutCfgMu.Unlock()
+	return lastOutCfg
+}
+
+func setLastOutputCfg(frame []byte) {
+	lastOutCfgMu.Lock()
+	lastOutCfg = frame
+	lastOutCfgMu.Unlock()
+}
+
+// frameBuffer keeps received data frames keyed by their timestamp
+// (SOC<<24|FRACSEC) so frames from several PMUs can be aggregated into one
+// outgoing frame. It is only populated while aggregation is enabled and is
+// bounded: entries past FrameBufferTTL are evicted, and when more than
+// FrameBufferMax timestamps are held the oldest goes first.
+var frameBuffer = make(map[uint64]*bufferedFrames)
+
+type bufferedFrames struct {
+	frames []*c37.DataFrame
+	added  time.Time
+}
+
+// FrameBufferTTL is how long a timestamp's frames wait for late siblings
+// before being evicted unaggregated.
+var FrameBufferTTL = 2 * time.Second
+
+// FrameBufferMax caps the number of distinct timestamps buffered.
+var FrameBufferMax = 128
+
+// bufferFrame files df under its timestamp key and evicts expired or
+// excess entries.
+func bufferFrame(key uint64, df *c37.DataFrame) []*c37.DataFrame {
+	now := time.Now()
+	for k, b := range frameBuffer {
+		if now.Sub(b.added) > FrameBufferTTL {
+			delete(frameBuffer, k)
+		}
+	}
+	for len(frameBuffer) >= FrameBufferMax {
+		oldest, first := uint64(0), true
+		for k, b := range frameBuffer {
+			if first || b.added.Before(frameBuffer[oldest].added) {
+				oldest, first = k, false
+			}
+		}
+		delete(frameBuffer, oldest)
+	}
+	b := frameBuffer[key]
+	if b == nil {
+		b = &bufferedFrames{added: now}
+		frameBuffer[key] = b
+	}
+	b.frames = append(b.frames, df)
+	return b.frames
+}
+
+// Aggregate enables merging of same-timestamp frames from several PMUs
+// into one multi-PMU output frame.
+var Aggregate bool
+
+// Cfg3MTU caps the size of CFG-3 frames sent to the output. Larger frames
+// are split into CONT_IDX fragments. The default is the FRAMESIZE field
+// maximum; UDP outputs behind a smaller path MTU can lower it.
+var Cfg3MTU = 65535
+
+// The registry of running input listeners, keyed by port and shared
+// between the startup path and the control API, so any listener can be
+// stopped or rebound at runtime. The outputs live in their own registry
+// and are untouched by listener churn: established TCP output sessions
+// stay open across a rebind, bridged from the hold buffer when -hold is
+// enabled.
+var (
+	listenersMu   sync.Mutex
+	listenerStops = make(map[int]context.CancelFunc)
+)
+
+// StartManagedListener registers the listener under its port and runs it
+// until ctx is cancelled or StopListener is called for the port.
+func StartManagedListener(ctx context.Context, port int, bindAddr string, outs *Outputs) error {
+	lctx, cancel := context.WithCancel(ctx)
+	listenersMu.Lock()
+	if _, ok := listenerStops[port]; ok {
+		listenersMu.Unlock()
+		cancel()
+		return fmt.Errorf("already listening on port %d", port)
+	}
+	listenerStops[port] = cancel
+	listenersMu.Unlock()
+	err := StartListening(lctx, port, bindAddr, outs)
+	listenersMu.Lock()
+	delete(listenerStops, port)
+	listenersMu.Unlock()
+	return err
+}
+
+// StopListener cancels the managed listener on port; false when no
+// listener is registered there.
+func StopListener(port int) bool {
+	listenersMu.Lock()
+	cancel, ok := listenerStops[port]
+	listenersMu.Unlock()
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+// listenerStopped waits for the managed listener on port to release its
+// socket, so a rebind to the same port does not race the close.
+func listenerStopped(port int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		listenersMu.Lock()
+		_, running := listenerStops[port]
+		listenersMu.Unlock()
+		if !running {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// StartListening binds a UDP socket on port and feeds every received frame
+// through the reduction pipeline into frames until ctx is cancelled.
+// bindAddr optionally restricts the socket to one local IP, which
+// multi-homed gateways need to keep PMU traffic on the station bus
+// interface.
+func StartListening(ctx context.Context, port int, bindAddr string, outs *Outputs) error {
+	laddr := &net.UDPAddr{Port: port}
+	if bindAddr != "" {
+		laddr.IP = net.ParseIP(bindAddr)
+		if laddr.IP == nil {
+			return fmt.Errorf("invalid bind address %q", bindAddr)
+		}
+	}
+	conn := activatedUDPConn(port)
+	if conn != nil {
+		log.Printf("using systemd-activated socket for port %d", port)
+	} else {
+		var err error
+		conn, err = net.ListenUDP("udp", laddr)
+		if err != nil {
+			return err
+		}
+	}
+	if err := joinMulticastGroups(conn); err != nil {
+		conn.Close()
+		return err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	log.Printf("listening for C37.118 frames on UDP %s", conn.LocalAddr())
+
+	buf := make([]byte, 65535)
+	for {
+		n, src, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if !sourceAllowed(src.IP) {
+			continue
+		}
+		if !sourceWithinRate(src.IP) {
+			continue
+		}
+		data := make([]byte, n)
+		copy(data, buf[:n])
+		if Legacy1344IDCode != 0 && data[0] != c37.SyncLead {
+			if data = convert1344(data); data == nil {
+				continue
+			}
+		}
+		if dedupFrame(port, data) {
+			continue
+		}
+		HandleFrame(data, src.IP, outs)
+	}
+}
+
+// pipelineMu serializes frame handling and every out-of-band reader of
+// the shared decode state: the stream contexts (Stats, Cfg, Cfg3,
+// LastData), the aggregation frameBuffer and the cached output
+// configurations. Frames arrive from several goroutines at once — the
+// redundant UDP listeners, the TCP input connections — and the poll-based
+// servers, the hold ticker and the control API read the same state; a
+// concurrent map write is a fatal runtime throw that recover cannot
+// catch.
+var pipelineMu sync.Mutex
+
+// HandleFrame dispatches one received frame: configuration frames update the
+// decode context and are forwarded (rewritten to the output rate), data
+// frames pass through the reducer. A panic while processing the frame is
+// recovered and recorded so one poisonous frame cannot kill the process.
+func HandleFrame(data []byte, src net.IP, outs *Outputs) {
+	pipelineMu.Lock()
+	defer pipelineMu.Unlock()
+	defer recoverFrame(data, src)
+	handleFrame(data, src, outs)
+}
+
+func handleFrame(data []byte, src net.IP, outs *Outputs) {
+	h, err := c37.DecodeHeader(data)
+	if err != nil {
+		countMalformed(src)
+		logRepeats("dropping frame from %s: %v", src, err)
+		return
+	}
+	if !checkSourcePin(h.IDCode, src) {
+		return
+	}
+	st := streamFor(h.IDCode)
+	st.Stats.FramesIn++
+	st.Stats.LastFrame = time.Now()
+	st.Stats.TimeQuality = h.TimeQualityInfo()
+	st.Stats.Version = h.Version
+	captureFrame(data)
+	q := quirksFor(h.IDCode)
+	if q.TrimInput && int(h.FrameSize) >= c37.CommonHeaderSize && int(h.FrameSize) < len(data) {
+		data = data[:h.FrameSize]
+	}
+	lenient := Lenient || q.Lenient
+	if !checkFrame(data, st) {
+		return
+	}
+	switch h.Type {
+	case c37.TypeCfg1, c37.TypeCfg2:
+		var cfg *c37.CfgFrame2
+		var err error
+		if lenient {
+			var warnings []string
+			cfg, warnings, err = c37.DecodeConfigFrame2Lenient(data)
+			logWarnings(st, src, warnings)
+		} else {
+			cfg, err = c37.DecodeConfigFrame2(data)
+		}
+		if err != nil {
+			st.Stats.DecodeErrors++
+			st.Stats.recordError(err)
+			countMalformed(src)
+			logRepeats("cannot decode config frame from %s: %v", src, err)
+			return
+		}
+		if !checkConfig(cfg.Validate(), st) {
+			return
+		}
+		applyFNomOverride(h.IDCode, cfg)
+		fillChannelNames(cfg)
+		labelAnalogChannels(cfg)
+		if st.Cfg3 != nil {
+			checkCfgConsistency(st, cfg, st.Cfg3)
+		}
+		if preferCfg3(st) {
+			logRepeats("IDCODE %d: CFG-2 received but CFG-3 drives decoding", h.IDCode)
+		} else {
+			adoptConfig(st, cfg, outs.Rate())
+		}
+		log.Printf("received CFG-%d from %s: IDCODE %d, %d PMU(s), %d fps",
+			frameTypeNumber(h.Type), src, h.IDCode, cfg.NumPMU, cfg.DataRate)
+		cfgOut := withDerivedChannels(withPowerChannels(cfg))
+		if convertFormat() {
+			cfgOut = outputFormatConfig(cfgOut)
+			outFmtCfgs[h.IDCode] = cfgOut
+		}
+		out := ConvertConfigurationFrame(cfgOut, outs.Rate())
+		if SelfCheck {
+			selfCheckConfig(outputConfig(cfgOut, outs.Rate()), out)
+		}
+		setLastOutputCfg(out)
+		st.Stats.FramesOut++
+		if !routeFrame(h.IDCode, out) {
+			outs.Publish(out)
+		}
+		if EmitCfg3 {
+			for _, frag := range buildCfg3Frames(cfgOut, outs.Rate()) {
+				st.Stats.FramesOut++
+				if !routeFrame(h.IDCode, frag) {
+					outs.Publish(frag)
+				}
+			}
+		}
+	case c37.TypeCfg3:
+		whole, done, err := st.Cfg3Frags.Add(data)
+		if err != nil {
+			st.Stats.DecodeErrors++
+			st.Stats.recordError(err)
+			countMalformed(src)
+			logRepeats("cannot reassemble CFG-3 frame from %s: %v", src, err)
+			return
+		}
+		if !done {
+			return
+		}
+		cfg3, err := c37.DecodeConfigFrame3(whole)
+		if err != nil {
+			st.Stats.DecodeErrors++
+			st.Stats.recordError(err)
+			countMalformed(src)
+			logRepeats("cannot decode CFG-3 frame from %s: %v", src, err)
+			return
+		}
+		if !checkConfig(cfg3.Validate(), st) {
+			return
+		}
+		if st.Cfg != nil {
+			checkCfgConsistency(st, st.Cfg, cfg3)
+		}
+		st.Cfg3 = cfg3
+		log.Printf("received CFG-3 from %s: IDCODE %d, %d PMU(s)", src, h.IDCode, cfg3.NumPMU)
+		if EmitCfg2 || CfgPreference == "cfg3" {
+			cfg2 := c37.Cfg2FromCfg3(cfg3)
+			fillChannelNames(cfg2)
+			transliterateNames(cfg2)
+			labelAnalogChannels(cfg2)
+			if CfgPreference == "cfg3" || st.Cfg == nil || st.Cfg.DataRate != cfg2.DataRate {
+				adoptConfig(st, cfg2, outs.Rate())
+			}
+			if EmitCfg2 {
+				cfgOut := withDerivedChannels(withPowerChannels(cfg2))
+				if convertFormat() {
+					cfgOut = outputFormatConfig(cfgOut)
+					outFmtCfgs[h.IDCode] = cfgOut
+				}
+				out := ConvertConfigurationFrame(cfgOut, outs.Rate())
+				if SelfCheck {
+					selfCheckConfig(outputConfig(cfgOut, outs.Rate()), out)
+				}
+				setLastOutputCfg(out)
+				st.Stats.FramesOut++
+				if !routeFrame(h.IDCode, out) {
+					outs.Publish(out)
+				}
+			}
+		}
+		frags, err := c37.FragmentCfg3(rewriteIDCode(whole), Cfg3MTU)
+		if err != nil {
+			log.Printf("cannot fragment CFG-3 frame for IDCODE %d: %v", h.IDCode, err)
+			return
+		}
+		for _, frag := range frags {
+			st.Stats.FramesOut++
+			if !routeFrame(h.IDCode, frag) {
+				outs.Publish(frag)
+			}
+		}
+	case c37.TypeData:
+		checkClockSkew(st, h)
+		if st.Cfg == nil {
+			logRepeats("dropping data frame from %s: no configuration for IDCODE %d yet", src, h.IDCode)
+			return
+		}
+		var df *c37.DataFrame
+		var err error
+		if lenient {
+			var warnings []string
+			df, warnings, err = c37.DecodeDataFrameLenient(data, st.Cfg)
+			logWarnings(st, src, warnings)
+		} else {
+			df, err = c37.DecodeDataFrame(data, st.Cfg)
+		}
+		if err != nil {
+			st.Stats.DecodeErrors++
+			st.Stats.recordError(err)
+			countMalformed(src)
+			logRepeats("cannot decode data frame from %s: %v", src, err)
+			return
+		}
+		st.LastData = df
+		for i := range df.PMUs {
+			if df.PMUs[i].Stat.PMUTrigger {
+				TriggerEvent(fmt.Sprintf("STAT trigger from IDCODE %d", h.IDCode))
+				break
+			}
+		}
+		drop, flagged := checkTimeQuality(st, h, df)
+		if drop {
+			return
+		}
+		sdrop, sflagged := checkSyncLoss(st, df)
+		if sdrop {
+			return
+		}
+		flagged = flagged || sflagged
+		if applyCalibration(st, df) {
+			flagged = true
+		}
+		if applyStatOverride(df) {
+			flagged = true
+		}
+		if applyAnalogTypes(st, df) {
+			flagged = true
+		}
+		if applyTQPolicy(&df.Header) {
+			flagged = true
+		}
+		recordFreqStats(st, df)
+		if checkExprRules(st, df) {
+			return
+		}
+		checkAlarms(st, df)
+		checkAngles(st, df)
+		checkExcursions(st, df)
+		if Aggregate {
+			key := uint64(h.SOC)<<24 | uint64(h.FracSecValue())
+			frames := bufferFrame(key, df)
+			if st.Reducer == nil || !st.Reducer.Keep() {
+				return
+			}
+			if agg := BuildAggregatedDataFrame(frames); agg != nil {
+				st.Stats.FramesOut++
+				outs.Publish(restampFrame(st, agg, outs.Rate()))
+			}
+			return
+		}
+		keep, mutated := runTransforms(st, df)
+		if !keep {
+			return
+		}
+		flagged = flagged || mutated
+		st.Stats.FramesOut++
+		publishIEC104(st, df)
+		publishRedis(st, df)
+		out := data
+		if flagged || len(powerMappingsFor(h.IDCode)) > 0 {
+			out = injectPowerChannels(st, df)
+		}
+		if len(deriveChannels) > 0 {
+			out = injectDerivedChannels(st, df)
+		}
+		if convertFormat() {
+			out = convertDataFormat(h, df, out)
+		}
+		out = rewriteIDCode(out)
+		out = restampFrame(st, out, outs.Rate())
+		if SelfCheck {
+			selfCheckData(st, df, out)
+		}
+		if !routeFrame(h.IDCode, out) {
+			outs.Publish(out)
+		}
+	case c37.TypeHeader, c37.TypeCmd:
+		// Header and command frames are not part of the reduced stream.
+	default:
+		logRepeats("dropping frame of unknown type %d from %s", h.Type, src)
+	}
+}
+
+// logWarnings records lenient-decode warnings against the stream.
+func logWarnings(st *StreamContext, src net.IP, warnings []string) {
+	for _, w := range warnings {
+		st.Stats.Warnings++
+		logRepeats("frame from %s (IDCODE %d): %s", src, st.IDCode, w)
+	}
+}
+
+func frameTypeNumber(t c37.FrameType) int {
+	if t == c37.TypeCfg1 {
+		return 1
+	}
+	return 2
+}
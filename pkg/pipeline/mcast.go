@@ -0,0 +1,67 @@
+package pipeline
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+)
+
+// mcastGroup is one multicast membership the UDP listeners join: a group
+// address, an optional interface, and optional IGMPv3 source filters.
+type mcastGroup struct {
+	group   net.IP
+	iface   string
+	sources []net.IP
+}
+
+var mcastGroups []mcastGroup
+
+// ParseMcastGroups parses the -mcast flag: comma-separated
+// "group[@interface][=source|source...]" entries, e.g.
+// "239.1.2.3,239.1.2.4@eth0,232.1.1.1@eth0=10.0.0.5". Entries with
+// sources join source-specific (IGMPv3 filtered) memberships.
+func ParseMcastGroups(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		var g mcastGroup
+		addr := entry
+		if a, srcs, found := strings.Cut(addr, "="); found {
+			addr = a
+			for _, s := range strings.Split(srcs, "|") {
+				src := net.ParseIP(s).To4()
+				if src == nil {
+					return fmt.Errorf("invalid multicast source %q in %q", s, entry)
+				}
+				g.sources = append(g.sources, src)
+			}
+		}
+		if a, ifname, found := strings.Cut(addr, "@"); found {
+			addr, g.iface = a, ifname
+		}
+		g.group = net.ParseIP(addr).To4()
+		if g.group == nil || !g.group.IsMulticast() {
+			return fmt.Errorf("invalid multicast group %q in %q", addr, entry)
+		}
+		mcastGroups = append(mcastGroups, g)
+	}
+	return nil
+}
+
+// joinMulticastGroups joins all configured memberships on the listener
+// socket.
+func joinMulticastGroups(conn *net.UDPConn) error {
+	for _, g := range mcastGroups {
+		if err := joinMulticast(conn, g); err != nil {
+			return fmt.Errorf("cannot join multicast group %s: %w", g.group, err)
+		}
+		if len(g.sources) > 0 {
+			log.Printf("joined multicast group %s (%d source filters)", g.group, len(g.sources))
+		} else {
+			log.Printf("joined multicast group %s", g.group)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package pipeline
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// joinMulticast joins one membership via setsockopt. Plain joins use
+// IP_ADD_MEMBERSHIP with the interface index; source-filtered joins use
+// one IP_ADD_SOURCE_MEMBERSHIP per source, which the kernel turns into
+// IGMPv3 include-mode reports.
+func joinMulticast(conn *net.UDPConn, g mcastGroup) error {
+	var ifindex int
+	var ifaceIP net.IP
+	if g.iface != "" {
+		ifi, err := net.InterfaceByName(g.iface)
+		if err != nil {
+			return err
+		}
+		ifindex = ifi.Index
+		if addrs, err := ifi.Addrs(); err == nil {
+			for _, a := range addrs {
+				if ipn, ok := a.(*net.IPNet); ok && ipn.IP.To4() != nil {
+					ifaceIP = ipn.IP.To4()
+					break
+				}
+			}
+		}
+	}
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var serr error
+	cerr := rc.Control(func(fd uintptr) {
+		if len(g.sources) == 0 {
+			mreq := &syscall.IPMreqn{Ifindex: int32(ifindex)}
+			copy(mreq.Multiaddr[:], g.group)
+			serr = syscall.SetsockoptIPMreqn(int(fd), syscall.IPPROTO_IP, syscall.IP_ADD_MEMBERSHIP, mreq)
+			return
+		}
+		for _, src := range g.sources {
+			// struct ip_mreq_source: multiaddr, interface, sourceaddr.
+			var b [12]byte
+			copy(b[0:4], g.group)
+			copy(b[4:8], ifaceIP)
+			copy(b[8:12], src)
+			serr = syscall.SetsockoptString(int(fd), syscall.IPPROTO_IP,
+				syscall.IP_ADD_SOURCE_MEMBERSHIP, string(b[:]))
+			if serr != nil {
+				return
+			}
+		}
+	})
+	if cerr != nil {
+		return cerr
+	}
+	if serr != nil {
+		return fmt.Errorf("setsockopt: %w", serr)
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package pipeline
+
+import (
+	"fmt"
+	"net"
+)
+
+// joinMulticast is Linux-only; gateways on other platforms have to rely
+// on OS-level group membership.
+func joinMulticast(conn *net.UDPConn, g mcastGroup) error {
+	return fmt.Errorf("multicast membership is only supported on Linux")
+}
@@ -0,0 +1,163 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// modbusEntry maps one register address onto a channel. Without a scale
+// the value occupies two registers as a big-endian float32; with one it
+// is multiplied, rounded and served as a signed 16-bit integer in a
+// single register.
+type modbusEntry struct {
+	addr  uint16
+	ref   ChannelRef
+	freq  bool
+	scale float64 // 0: float32 across two registers
+}
+
+var modbusEntries []modbusEntry
+
+// ParseModbusMap parses the -modbus_map flag: comma-separated
+// "reg=IDCODE:pmu:phasor" entries serving a phasor magnitude as float32
+// in registers reg and reg+1, "reg=IDCODE:pmu:f" the PMU frequency, and
+// an optional "*scale" suffix switching to a scaled int16 in a single
+// register, e.g. "100=41:0:0,102=41:0:f,103=41:0:f*1000".
+func ParseModbusMap(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		addr, ch, ok := strings.Cut(entry, "=")
+		if !ok {
+			return fmt.Errorf("invalid modbus map entry %q, want reg=IDCODE:pmu:phasor", entry)
+		}
+		reg, err := strconv.ParseUint(addr, 10, 16)
+		if err != nil {
+			return fmt.Errorf("invalid register address in %q", entry)
+		}
+		e := modbusEntry{addr: uint16(reg)}
+		if base, scale, found := strings.Cut(ch, "*"); found {
+			ch = base
+			if e.scale, err = strconv.ParseFloat(scale, 64); err != nil || e.scale == 0 {
+				return fmt.Errorf("invalid scale in modbus map entry %q", entry)
+			}
+		}
+		if rest, found := strings.CutSuffix(ch, ":f"); found {
+			e.freq = true
+			ch = rest + ":0"
+		}
+		if e.ref, err = parseChannelRef(ch); err != nil {
+			return fmt.Errorf("invalid channel in modbus map entry %q: %v", entry, err)
+		}
+		modbusEntries = append(modbusEntries, e)
+	}
+	return nil
+}
+
+// modbusRegisters renders the configured map against the most recent data
+// frames. Registers without a mapped (or yet unseen) channel read as 0.
+func modbusRegisters() map[uint16]uint16 {
+	pipelineMu.Lock()
+	defer pipelineMu.Unlock()
+	regs := make(map[uint16]uint16, 2*len(modbusEntries))
+	for _, e := range modbusEntries {
+		st := lookupStream(e.ref.IDCode)
+		if st == nil || st.LastData == nil || e.ref.PMU >= len(st.LastData.PMUs) {
+			continue
+		}
+		pmu := &st.LastData.PMUs[e.ref.PMU]
+		var v float64
+		if e.freq {
+			v = pmu.Freq
+		} else if e.ref.Phasor < len(pmu.Phasors) {
+			v = pmu.Phasors[e.ref.Phasor].Magnitude
+		} else {
+			continue
+		}
+		if e.scale != 0 {
+			regs[e.addr] = uint16(int16(math.Round(v * e.scale)))
+		} else {
+			bits := math.Float32bits(float32(v))
+			regs[e.addr] = uint16(bits >> 16)
+			regs[e.addr+1] = uint16(bits)
+		}
+	}
+	return regs
+}
+
+// StartModbusServer serves the register map over Modbus TCP on addr until
+// ctx is cancelled. Read holding registers (3) and read input registers
+// (4) are supported; both address the same map.
+func StartModbusServer(ctx context.Context, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	log.Printf("Modbus TCP server listening on %s (%d mapped entries)", addr, len(modbusEntries))
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go serveModbus(conn)
+	}
+}
+
+// serveModbus answers the requests of one Modbus TCP client.
+func serveModbus(conn net.Conn) {
+	defer conn.Close()
+	mbap := make([]byte, 7)
+	for {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Minute))
+		if _, err := io.ReadFull(conn, mbap); err != nil {
+			return
+		}
+		plen := int(binary.BigEndian.Uint16(mbap[4:6]))
+		if plen < 2 || plen > 260 {
+			return
+		}
+		pdu := make([]byte, plen-1)
+		if _, err := io.ReadFull(conn, pdu); err != nil {
+			return
+		}
+		var resp []byte
+		switch {
+		case (pdu[0] == 3 || pdu[0] == 4) && len(pdu) == 5:
+			start := binary.BigEndian.Uint16(pdu[1:3])
+			count := binary.BigEndian.Uint16(pdu[3:5])
+			if count == 0 || count > 125 {
+				resp = []byte{pdu[0] | 0x80, 0x03} // illegal data value
+				break
+			}
+			regs := modbusRegisters()
+			resp = []byte{pdu[0], byte(2 * count)}
+			for i := uint16(0); i < count; i++ {
+				resp = binary.BigEndian.AppendUint16(resp, regs[start+i])
+			}
+		default:
+			resp = []byte{pdu[0] | 0x80, 0x01} // illegal function
+		}
+		out := make([]byte, 7, 7+len(resp))
+		copy(out, mbap[:7])
+		binary.BigEndian.PutUint16(out[4:6], uint16(1+len(resp)))
+		if _, err := conn.Write(append(out, resp...)); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package pipeline
+
+import (
+	"fmt"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// FillNames enables placeholder naming for empty channel names. CFG-3
+// allows zero-length names, which leave downstream sinks with
+// unidentifiable columns; the policy substitutes "PMU<IDCODE>_PH03"-style
+// names so the decoded output and every regenerated CFG frame agree.
+var FillNames bool
+
+// fillChannelNames replaces empty (or missing) channel names of a decoded
+// configuration with deterministic placeholders: PH for phasors, AN for
+// analogs and DG for digital bits, numbered per PMU block.
+func fillChannelNames(cfg *c37.CfgFrame2) {
+	if !FillNames {
+		return
+	}
+	for i := range cfg.PMUs {
+		pc := &cfg.PMUs[i]
+		pc.PhasorNames = fillNames(pc.PhasorNames, int(pc.NumPhasors), pc.IDCode, "PH")
+		pc.AnalogNames = fillNames(pc.AnalogNames, int(pc.NumAnalogs), pc.IDCode, "AN")
+		pc.DigitalNames = fillNames(pc.DigitalNames, 16*int(pc.NumDigitals), pc.IDCode, "DG")
+		if pc.StationName == "" {
+			pc.StationName = fmt.Sprintf("PMU%d", pc.IDCode)
+		}
+	}
+}
+
+// fillNames pads a name list to the channel count and substitutes
+// placeholders for empty entries.
+func fillNames(names []string, count int, idCode uint16, kind string) []string {
+	for len(names) < count {
+		names = append(names, "")
+	}
+	for i := range names {
+		if names[i] == "" {
+			names[i] = fmt.Sprintf("PMU%d_%s%02d", idCode, kind, i)
+		}
+	}
+	return names
+}
@@ -0,0 +1,577 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The embedded OPC UA server mirrors the PMU blocks of every stream as
+// objects under the Objects folder, each holding freq, dfreq and per-
+// phasor magnitude/angle variables that read the most recent decoded
+// values. The implementation is the minimal binary profile control-center
+// clients need to browse and poll: SecurityPolicy None, anonymous
+// sessions, and the GetEndpoints, CreateSession, ActivateSession, Browse,
+// Read and CloseSession services. Subscriptions are not implemented;
+// clients poll with Read.
+
+const uaSecurityPolicyNone = "http://opcfoundation.org/UA/SecurityPolicy#None"
+
+// OPC UA service request/response type ids (DefaultBinary encodings).
+const (
+	uaOpenSecureChannelReq  = 446
+	uaOpenSecureChannelResp = 449
+	uaGetEndpointsReq       = 428
+	uaGetEndpointsResp      = 431
+	uaCreateSessionReq      = 461
+	uaCreateSessionResp     = 464
+	uaActivateSessionReq    = 467
+	uaActivateSessionResp   = 470
+	uaCloseSessionReq       = 473
+	uaCloseSessionResp      = 476
+	uaBrowseReq             = 525
+	uaBrowseResp            = 528
+	uaReadReq               = 631
+	uaReadResp              = 634
+	uaServiceFaultResp      = 397
+)
+
+// StartOPCUAServer serves the phasor address space on addr until ctx is
+// cancelled.
+func StartOPCUAServer(ctx context.Context, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	log.Printf("OPC UA server listening on opc.tcp://%s", addr)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go serveOPCUA(conn)
+	}
+}
+
+// serveOPCUA runs the transport state machine of one client connection.
+func serveOPCUA(conn net.Conn) {
+	defer conn.Close()
+	endpoint := "opc.tcp://" + conn.LocalAddr().String()
+	var seq uint32
+	for {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Minute))
+		hdr := make([]byte, 8)
+		if _, err := io.ReadFull(conn, hdr); err != nil {
+			return
+		}
+		size := binary.LittleEndian.Uint32(hdr[4:8])
+		if size < 8 || size > 1<<24 {
+			return
+		}
+		body := make([]byte, size-8)
+		if _, err := io.ReadFull(conn, body); err != nil {
+			return
+		}
+		var resp []byte
+		switch string(hdr[:3]) {
+		case "HEL":
+			r := uaReader{b: body}
+			r.u32() // protocol version
+			rb, sb := r.u32(), r.u32()
+			var w uaBuf
+			w.u32(0) // protocol version
+			w.u32(rb)
+			w.u32(sb)
+			w.u32(1 << 24)
+			w.u32(0)
+			resp = uaMessage("ACK", w.b)
+		case "OPN":
+			r := uaReader{b: body}
+			r.u32()   // secure channel id
+			r.str()   // security policy
+			r.bytes() // sender certificate
+			r.bytes() // receiver thumbprint
+			r.u32()   // sequence number
+			reqID := r.u32()
+			r.nodeID() // request type
+			handle := r.requestHeader()
+			var w uaBuf
+			w.u32(1) // secure channel id
+			w.str(uaSecurityPolicyNone)
+			w.i32(-1) // no certificate
+			w.i32(-1) // no thumbprint
+			seq++
+			w.u32(seq)
+			w.u32(reqID)
+			w.nodeIDNum(uaOpenSecureChannelResp)
+			w.responseHeader(handle)
+			w.u32(0) // server protocol version
+			w.u32(1) // channel id
+			w.u32(1) // token id
+			w.u64(uaFiletime(time.Now()))
+			w.u32(3600000) // revised lifetime
+			w.i32(-1)      // server nonce
+			resp = uaMessage("OPN", w.b)
+		case "CLO":
+			return
+		case "MSG":
+			r := uaReader{b: body}
+			r.u32() // channel id
+			r.u32() // token id
+			r.u32() // sequence number
+			reqID := r.u32()
+			typeID := r.nodeID()
+			handle := r.requestHeader()
+			var w uaBuf
+			w.u32(1) // channel id
+			w.u32(1) // token id
+			seq++
+			w.u32(seq)
+			w.u32(reqID)
+			if !uaService(&w, typeID, handle, &r, endpoint) {
+				return
+			}
+			resp = uaMessage("MSG", w.b)
+		default:
+			return
+		}
+		if resp != nil {
+			if _, err := conn.Write(resp); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// uaService encodes the response of one service call into w; a false
+// return closes the connection (CloseSession).
+func uaService(w *uaBuf, typeID uint32, handle uint32, r *uaReader, endpoint string) bool {
+	switch typeID {
+	case uaGetEndpointsReq:
+		w.nodeIDNum(uaGetEndpointsResp)
+		w.responseHeader(handle)
+		w.i32(1) // one endpoint
+		uaEndpoint(w, endpoint)
+	case uaCreateSessionReq:
+		w.nodeIDNum(uaCreateSessionResp)
+		w.responseHeader(handle)
+		w.nodeIDStr(1, "session") // session id
+		w.nodeIDNum(1)            // authentication token (ns 0 numeric)
+		w.f64(3600000)            // revised timeout ms
+		w.i32(-1)                 // server nonce
+		w.i32(-1)                 // server certificate
+		w.i32(1)                  // one endpoint
+		uaEndpoint(w, endpoint)
+		w.i32(0)  // software certificates
+		w.i32(-1) // signature algorithm
+		w.i32(-1) // signature
+		w.u32(1 << 24)
+	case uaActivateSessionReq:
+		w.nodeIDNum(uaActivateSessionResp)
+		w.responseHeader(handle)
+		w.i32(-1) // server nonce
+		w.i32(-1) // results
+		w.i32(-1) // diagnostics
+	case uaCloseSessionReq:
+		w.nodeIDNum(uaCloseSessionResp)
+		w.responseHeader(handle)
+		return true
+	case uaBrowseReq:
+		uaBrowse(w, handle, r)
+	case uaReadReq:
+		uaRead(w, handle, r)
+	default:
+		w.nodeIDNum(uaServiceFaultResp)
+		w.responseHeaderStatus(handle, 0x80b60000) // Bad_ServiceUnsupported
+	}
+	return true
+}
+
+// uaEndpoint writes one EndpointDescription for the None policy with an
+// anonymous token.
+func uaEndpoint(w *uaBuf, endpoint string) {
+	w.str(endpoint)
+	w.str("urn:frame_reductor") // application uri
+	w.str("urn:frame_reductor") // product uri
+	w.locText("frame_reductor") // application name
+	w.u32(0)                    // application type: server
+	w.i32(-1)                   // gateway server uri
+	w.i32(-1)                   // discovery profile uri
+	w.i32(-1)                   // discovery urls
+	w.i32(-1)                   // server certificate
+	w.u32(1)                    // security mode: none
+	w.str(uaSecurityPolicyNone)
+	w.i32(1) // one user token policy
+	w.str("anonymous")
+	w.u32(0)  // token type: anonymous
+	w.i32(-1) // issued token type
+	w.i32(-1) // issuer endpoint url
+	w.i32(-1) // security policy uri
+	w.str("http://opcfoundation.org/UA-Profile/Transport/uatcp-uasc-uabinary")
+	w.u8(0) // security level
+}
+
+// uaBrowse answers a Browse request over the two-level PMU address space.
+func uaBrowse(w *uaBuf, handle uint32, r *uaReader) {
+	r.nodeID()   // view id
+	r.u64()      // view timestamp
+	r.u32()      // view version
+	r.u32()      // requested max references
+	n := r.i32() // nodes to browse
+	w.nodeIDNum(uaBrowseResp)
+	w.responseHeader(handle)
+	w.i32(n)
+	for i := int32(0); i < n; i++ {
+		num, str := r.browseDescription()
+		w.u32(0)  // status: good
+		w.i32(-1) // continuation point
+		switch {
+		case num == 85: // Objects folder: one object per PMU block
+			refs := uaObjects()
+			w.i32(int32(len(refs)))
+			for _, ref := range refs {
+				uaReference(w, ref.id, ref.name, 1) // node class: object
+			}
+		case str != "" && !strings.Contains(str, "."):
+			refs := uaVariables(str)
+			w.i32(int32(len(refs)))
+			for _, ref := range refs {
+				uaReference(w, ref.id, ref.name, 2) // node class: variable
+			}
+		default:
+			w.i32(0)
+		}
+	}
+	w.i32(-1) // diagnostics
+}
+
+// uaRef is one browseable node: a string NodeId and its display name.
+type uaRef struct {
+	id   string
+	name string
+}
+
+// uaObjects lists the PMU block objects, NodeIds "IDCODE:pmu".
+func uaObjects() []uaRef {
+	pipelineMu.Lock()
+	defer pipelineMu.Unlock()
+	sts := Streams()
+	sort.Slice(sts, func(i, j int) bool { return sts[i].IDCode < sts[j].IDCode })
+	var refs []uaRef
+	for _, st := range sts {
+		if st.Cfg == nil {
+			continue
+		}
+		for i := range st.Cfg.PMUs {
+			refs = append(refs, uaRef{
+				id:   fmt.Sprintf("%d:%d", st.IDCode, i),
+				name: st.Cfg.PMUs[i].StationName,
+			})
+		}
+	}
+	return refs
+}
+
+// uaVariables lists the channel variables of one PMU block object.
+func uaVariables(obj string) []uaRef {
+	pipelineMu.Lock()
+	defer pipelineMu.Unlock()
+	idStr, pmuStr, ok := strings.Cut(obj, ":")
+	if !ok {
+		return nil
+	}
+	idCode, err1 := strconv.Atoi(idStr)
+	pmu, err2 := strconv.Atoi(pmuStr)
+	if err1 != nil || err2 != nil {
+		return nil
+	}
+	st := lookupStream(uint16(idCode))
+	if st == nil || st.Cfg == nil || pmu >= len(st.Cfg.PMUs) {
+		return nil
+	}
+	refs := []uaRef{
+		{obj + ".freq", "Frequency"},
+		{obj + ".dfreq", "ROCOF"},
+	}
+	pc := &st.Cfg.PMUs[pmu]
+	for i := 0; i < int(pc.NumPhasors); i++ {
+		name := fmt.Sprintf("Phasor %d", i)
+		if i < len(pc.PhasorNames) {
+			name = pc.PhasorNames[i]
+		}
+		refs = append(refs,
+			uaRef{fmt.Sprintf("%s.mag%d", obj, i), name + " magnitude"},
+			uaRef{fmt.Sprintf("%s.ang%d", obj, i), name + " angle"})
+	}
+	return refs
+}
+
+// uaReference writes one ReferenceDescription for a ns-1 string node.
+func uaReference(w *uaBuf, id, name string, nodeClass uint32) {
+	w.nodeIDNum(47) // HasComponent
+	w.u8(1)         // forward
+	w.nodeIDStr(1, id)
+	w.u8(0) // no expanded fields
+	w.u16(1)
+	w.str(name) // browse name
+	w.locText(name)
+	w.u32(nodeClass)
+	w.nodeIDNum(0) // type definition
+	w.u8(0)
+}
+
+// uaRead answers a Read request; values resolve against the most recent
+// decoded data frames.
+func uaRead(w *uaBuf, handle uint32, r *uaReader) {
+	r.f64() // max age
+	r.u32() // timestamps to return
+	n := r.i32()
+	w.nodeIDNum(uaReadResp)
+	w.responseHeader(handle)
+	w.i32(n)
+	now := uaFiletime(time.Now())
+	for i := int32(0); i < n; i++ {
+		_, str := r.nodeIDParts()
+		r.u32() // attribute id
+		r.str() // index range
+		r.u16() // data encoding namespace
+		r.str() // data encoding name
+		if v, ok := uaValue(str); ok {
+			w.u8(0x05) // value + source timestamp
+			w.u8(11)   // variant: double
+			w.f64(v)
+			w.u64(now)
+		} else {
+			w.u8(0x02)        // status only
+			w.u32(0x80340000) // Bad_NodeIdUnknown
+		}
+	}
+	w.i32(-1) // diagnostics
+}
+
+// uaValue resolves a variable NodeId string ("IDCODE:pmu.channel") to its
+// latest value.
+func uaValue(id string) (float64, bool) {
+	pipelineMu.Lock()
+	defer pipelineMu.Unlock()
+	obj, ch, ok := strings.Cut(id, ".")
+	if !ok {
+		return 0, false
+	}
+	idStr, pmuStr, ok := strings.Cut(obj, ":")
+	if !ok {
+		return 0, false
+	}
+	idCode, err1 := strconv.Atoi(idStr)
+	pmu, err2 := strconv.Atoi(pmuStr)
+	if err1 != nil || err2 != nil {
+		return 0, false
+	}
+	st := lookupStream(uint16(idCode))
+	if st == nil || st.LastData == nil || pmu >= len(st.LastData.PMUs) {
+		return 0, false
+	}
+	pd := &st.LastData.PMUs[pmu]
+	switch {
+	case ch == "freq":
+		return pd.Freq, true
+	case ch == "dfreq":
+		return pd.DFreq, true
+	case strings.HasPrefix(ch, "mag"):
+		if i, err := strconv.Atoi(ch[3:]); err == nil && i >= 0 && i < len(pd.Phasors) {
+			return pd.Phasors[i].Magnitude, true
+		}
+	case strings.HasPrefix(ch, "ang"):
+		if i, err := strconv.Atoi(ch[3:]); err == nil && i >= 0 && i < len(pd.Phasors) {
+			return pd.Phasors[i].Angle * 180 / math.Pi, true
+		}
+	}
+	return 0, false
+}
+
+// uaFiletime converts a timestamp to the OPC UA 100 ns epoch.
+func uaFiletime(t time.Time) uint64 {
+	return uint64(t.UnixNano()/100 + 116444736000000000)
+}
+
+// uaMessage frames a final chunk with the given 3-letter message type.
+func uaMessage(typ string, body []byte) []byte {
+	out := make([]byte, 8, 8+len(body))
+	copy(out, typ)
+	out[3] = 'F'
+	binary.LittleEndian.PutUint32(out[4:8], uint32(8+len(body)))
+	return append(out, body...)
+}
+
+// uaBuf builds little-endian OPC UA binary structures.
+type uaBuf struct {
+	b []byte
+}
+
+func (w *uaBuf) u8(v uint8)   { w.b = append(w.b, v) }
+func (w *uaBuf) u16(v uint16) { w.b = binary.LittleEndian.AppendUint16(w.b, v) }
+func (w *uaBuf) u32(v uint32) { w.b = binary.LittleEndian.AppendUint32(w.b, v) }
+func (w *uaBuf) u64(v uint64) { w.b = binary.LittleEndian.AppendUint64(w.b, v) }
+func (w *uaBuf) i32(v int32)  { w.u32(uint32(v)) }
+func (w *uaBuf) f64(v float64) {
+	w.u64(math.Float64bits(v))
+}
+
+func (w *uaBuf) str(s string) {
+	w.i32(int32(len(s)))
+	w.b = append(w.b, s...)
+}
+
+// nodeIDNum writes a ns-0 numeric NodeId in the shortest encoding.
+func (w *uaBuf) nodeIDNum(id uint32) {
+	if id < 256 {
+		w.u8(0)
+		w.u8(uint8(id))
+		return
+	}
+	w.u8(1)
+	w.u8(0)
+	w.u16(uint16(id))
+}
+
+// nodeIDStr writes a string NodeId in namespace ns.
+func (w *uaBuf) nodeIDStr(ns uint16, s string) {
+	w.u8(3)
+	w.u16(ns)
+	w.str(s)
+}
+
+// locText writes a LocalizedText with only the text field.
+func (w *uaBuf) locText(s string) {
+	w.u8(0x02)
+	w.str(s)
+}
+
+// responseHeader writes a good ResponseHeader for the request handle.
+func (w *uaBuf) responseHeader(handle uint32) {
+	w.responseHeaderStatus(handle, 0)
+}
+
+func (w *uaBuf) responseHeaderStatus(handle, status uint32) {
+	w.u64(uaFiletime(time.Now()))
+	w.u32(handle)
+	w.u32(status)
+	w.u8(0)   // no diagnostics
+	w.i32(-1) // string table
+	w.u8(0)   // additional header: null NodeId
+	w.u8(0)
+	w.u8(0) // no body
+}
+
+// uaReader decodes little-endian OPC UA binary structures; reads past the
+// end return zero values.
+type uaReader struct {
+	b   []byte
+	off int
+}
+
+func (r *uaReader) take(n int) []byte {
+	if r.off+n > len(r.b) {
+		r.off = len(r.b)
+		return make([]byte, n)
+	}
+	v := r.b[r.off : r.off+n]
+	r.off += n
+	return v
+}
+
+func (r *uaReader) u8() uint8   { return r.take(1)[0] }
+func (r *uaReader) u16() uint16 { return binary.LittleEndian.Uint16(r.take(2)) }
+func (r *uaReader) u32() uint32 { return binary.LittleEndian.Uint32(r.take(4)) }
+func (r *uaReader) u64() uint64 { return binary.LittleEndian.Uint64(r.take(8)) }
+func (r *uaReader) i32() int32  { return int32(r.u32()) }
+func (r *uaReader) f64() float64 {
+	return math.Float64frombits(r.u64())
+}
+
+func (r *uaReader) str() string {
+	n := r.i32()
+	if n <= 0 {
+		return ""
+	}
+	return string(r.take(int(n)))
+}
+
+func (r *uaReader) bytes() []byte {
+	n := r.i32()
+	if n <= 0 {
+		return nil
+	}
+	return r.take(int(n))
+}
+
+// nodeID consumes a NodeId and returns its numeric id (0 for string ids).
+func (r *uaReader) nodeID() uint32 {
+	num, _ := r.nodeIDParts()
+	return num
+}
+
+// nodeIDParts consumes a NodeId and returns the numeric id or the string
+// identifier, depending on the encoding.
+func (r *uaReader) nodeIDParts() (uint32, string) {
+	switch enc := r.u8() & 0x3F; enc {
+	case 0: // two byte
+		return uint32(r.u8()), ""
+	case 1: // four byte
+		r.u8()
+		return uint32(r.u16()), ""
+	case 2: // numeric
+		r.u16()
+		return r.u32(), ""
+	case 3: // string
+		r.u16()
+		return 0, r.str()
+	case 4: // guid
+		r.take(16 + 2)
+		return 0, ""
+	default: // bytestring
+		r.u16()
+		r.bytes()
+		return 0, ""
+	}
+}
+
+// requestHeader consumes a RequestHeader and returns the request handle.
+func (r *uaReader) requestHeader() uint32 {
+	r.nodeID() // authentication token
+	r.u64()    // timestamp
+	handle := r.u32()
+	r.u32()    // return diagnostics
+	r.str()    // audit entry id
+	r.u32()    // timeout hint
+	r.nodeID() // additional header type
+	r.u8()     // additional header encoding
+	return handle
+}
+
+// browseDescription consumes a BrowseDescription and returns the target
+// NodeId parts.
+func (r *uaReader) browseDescription() (uint32, string) {
+	num, str := r.nodeIDParts()
+	r.u32()    // browse direction
+	r.nodeID() // reference type
+	r.u8()     // include subtypes
+	r.u32()    // node class mask
+	r.u32()    // result mask
+	return num, str
+}
@@ -0,0 +1,149 @@
+package pipeline
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Output describes one destination the reduced stream is sent to.
+type Output struct {
+	Protocol string // "udp", "tcp", "tcp-server" or "file"
+	Host     string
+	Port     int
+	// DataRate is the reduced output rate in frames per second.
+	DataRate int
+	// FilePath is the capture file path for the "file" protocol.
+	FilePath string
+}
+
+// Addr returns the host:port form of the destination.
+func (o Output) Addr() string {
+	return fmt.Sprintf("%s:%d", o.Host, o.Port)
+}
+
+// OutputStream couples a destination with the channel its sender reads.
+type OutputStream struct {
+	Output
+	Frames chan []byte
+	// Dropped counts frames discarded because the sender could not keep up.
+	Dropped uint64
+	// ingress receives published frames ahead of the retiming stage; it is
+	// nil when the stream is not retimed.
+	ingress chan []byte
+	quit    chan struct{}
+	// The most recent send or connect failure, recorded by the sender
+	// goroutine and read by the control API.
+	errMu       sync.Mutex
+	lastSendErr string
+	lastSendAt  time.Time
+}
+
+// recordSendError notes the most recent send or connect failure of the
+// stream for introspection.
+func (s *OutputStream) recordSendError(err error) {
+	s.errMu.Lock()
+	s.lastSendErr = err.Error()
+	s.lastSendAt = time.Now()
+	s.errMu.Unlock()
+}
+
+// LastSendError returns the most recent send failure and when it
+// happened; an empty string means the output has never failed.
+func (s *OutputStream) LastSendError() (string, time.Time) {
+	s.errMu.Lock()
+	defer s.errMu.Unlock()
+	return s.lastSendErr, s.lastSendAt
+}
+
+// Outputs is the registry of active output streams. The listener publishes
+// every outgoing frame to all registered streams; each stream has its own
+// sender goroutine and can therefore be configured independently.
+type Outputs struct {
+	mu      sync.Mutex
+	streams []*OutputStream
+}
+
+// NewOutputs creates an empty output registry.
+func NewOutputs() *Outputs {
+	return &Outputs{}
+}
+
+// Add registers a destination and returns its stream for the sender to
+// consume.
+func (o *Outputs) Add(out Output) *OutputStream {
+	os := &OutputStream{Output: out, Frames: make(chan []byte, 1024)}
+	if Retime && out.DataRate != 0 {
+		os.startRetimer()
+		// The retimer and the chaos stage both own the ingress channel, so
+		// only one can interpose; make the silent precedence visible.
+		if chaosEnabled() {
+			log.Printf("chaos impairments disabled on %s %s: retiming owns the output stage", out.Protocol, out.Addr())
+		}
+	} else if chaosEnabled() {
+		os.startChaos()
+	}
+	o.mu.Lock()
+	o.streams = append(o.streams, os)
+	o.mu.Unlock()
+	return os
+}
+
+// Remove detaches a stream from the registry; frames are no longer
+// published to it.
+func (o *Outputs) Remove(s *OutputStream) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for i, cur := range o.streams {
+		if cur == s {
+			o.streams = append(o.streams[:i], o.streams[i+1:]...)
+			if s.quit != nil {
+				close(s.quit)
+				s.quit = nil
+			}
+			return
+		}
+	}
+}
+
+// Streams returns a snapshot of the registered output streams.
+func (o *Outputs) Streams() []*OutputStream {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]*OutputStream(nil), o.streams...)
+}
+
+// Publish fans a frame out to every registered stream. A stream whose
+// sender has fallen behind loses the frame rather than stalling the whole
+// pipeline.
+func (o *Outputs) Publish(frame []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, os := range o.streams {
+		ch := os.Frames
+		if os.ingress != nil {
+			ch = os.ingress
+		}
+		select {
+		case ch <- frame:
+		default:
+			atomic.AddUint64(&os.Dropped, 1)
+		}
+	}
+}
+
+// Rate returns the lowest configured output data rate, which drives the
+// reduction.
+func (o *Outputs) Rate() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	rate := 0
+	for _, os := range o.streams {
+		if rate == 0 || (os.DataRate != 0 && rateLess(os.DataRate, rate)) {
+			rate = os.DataRate
+		}
+	}
+	return rate
+}
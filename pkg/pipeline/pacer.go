@@ -0,0 +1,61 @@
+package pipeline
+
+import (
+	"sync"
+	"time"
+)
+
+// Pacer is a token bucket that smooths output bursts — for example the
+// flush of buffered frames after a reconnect — to a maximum packet rate.
+type Pacer struct {
+	mu     sync.Mutex
+	rate   float64 // tokens added per second
+	burst  float64 // bucket capacity
+	tokens float64
+	last   time.Time
+}
+
+// NewPacer creates a pacer allowing rate packets per second with bursts of
+// up to burst packets.
+func NewPacer(rate, burst int) *Pacer {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Pacer{
+		rate:   float64(rate),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a token is available and consumes it.
+func (p *Pacer) Wait() {
+	p.mu.Lock()
+	now := time.Now()
+	p.tokens += now.Sub(p.last).Seconds() * p.rate
+	if p.tokens > p.burst {
+		p.tokens = p.burst
+	}
+	p.last = now
+	p.tokens--
+	var sleep time.Duration
+	if p.tokens < 0 {
+		sleep = time.Duration(-p.tokens / p.rate * float64(time.Second))
+	}
+	p.mu.Unlock()
+	if sleep > 0 {
+		time.Sleep(sleep)
+	}
+}
+
+// OutputPacer, when set, limits the packet rate of every output. A nil
+// pacer sends as fast as frames arrive.
+var OutputPacer *Pacer
+
+// paceOutput consumes one token from the output pacer if one is configured.
+func paceOutput() {
+	if OutputPacer != nil {
+		OutputPacer.Wait()
+	}
+}
@@ -0,0 +1,83 @@
+package pipeline
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// sourcePins binds an IDCODE to the source address its frames must come
+// from, so a cross-wired or duplicated stream cannot silently take over
+// another stream's IDCODE.
+var sourcePins = make(map[uint16]net.IP)
+
+var (
+	pinMu sync.Mutex
+	// pinAlarmed remembers which IDCODE/source pairs already alarmed, so a
+	// sustained mismatch logs once instead of once per frame.
+	pinAlarmed = make(map[string]bool)
+	pinDrops   = make(map[uint16]uint64)
+)
+
+// ParseSourcePins parses the -pin_source flag: comma-separated
+// "IDCODE=IP" entries, e.g. "41=10.0.0.5,42=10.0.0.6".
+func ParseSourcePins(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		id, addr, ok := strings.Cut(entry, "=")
+		if !ok {
+			return fmt.Errorf("invalid source pin %q, want IDCODE=IP", entry)
+		}
+		idCode, err := strconv.ParseUint(id, 10, 16)
+		if err != nil {
+			return fmt.Errorf("invalid IDCODE in source pin %q: %v", entry, err)
+		}
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return fmt.Errorf("invalid address in source pin %q", entry)
+		}
+		sourcePins[uint16(idCode)] = ip
+	}
+	return nil
+}
+
+// PinnedSourceDrops returns a snapshot of the per-IDCODE counters of
+// frames rejected for arriving from the wrong source.
+func PinnedSourceDrops() map[uint16]uint64 {
+	pinMu.Lock()
+	defer pinMu.Unlock()
+	out := make(map[uint16]uint64, len(pinDrops))
+	for k, n := range pinDrops {
+		out[k] = n
+	}
+	return out
+}
+
+// checkSourcePin reports whether a frame carrying idCode may enter the
+// pipeline from src. The first mismatch per IDCODE/source pair raises an
+// alarm. Frames without a source (file replay) always pass.
+func checkSourcePin(idCode uint16, src net.IP) bool {
+	if src == nil {
+		return true
+	}
+	pin, ok := sourcePins[idCode]
+	if !ok || pin.Equal(src) {
+		return true
+	}
+	key := fmt.Sprintf("%d/%s", idCode, src)
+	pinMu.Lock()
+	pinDrops[idCode]++
+	alarmed := pinAlarmed[key]
+	pinAlarmed[key] = true
+	pinMu.Unlock()
+	if !alarmed {
+		log.Printf("ALARM source: IDCODE %d received from %s, pinned to %s", idCode, src, pin)
+		TriggerEvent(fmt.Sprintf("IDCODE %d from unexpected source %s", idCode, src))
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package pipeline
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"plugin"
+	"strings"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// customTransforms holds transforms registered beyond the built-in ones,
+// available under their name in -transforms chains.
+var customTransforms = make(map[string]Transform)
+
+// RegisterTransform makes t available in transform chains under name.
+// Linked-in extensions and loaded plugins use this to inject proprietary
+// frame processing without forking the pipeline.
+func RegisterTransform(name string, t Transform) {
+	customTransforms[name] = t
+}
+
+// LoadTransformPlugin opens a Go plugin and registers the transforms it
+// exports. The plugin must export
+//
+//	var Transforms map[string]pipeline.Transform
+//
+// whose entries are registered under their map keys.
+func LoadTransformPlugin(path string) error {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return fmt.Errorf("cannot load transform plugin %s: %v", path, err)
+	}
+	sym, err := p.Lookup("Transforms")
+	if err != nil {
+		return fmt.Errorf("plugin %s: %v", path, err)
+	}
+	ts, ok := sym.(*map[string]Transform)
+	if !ok {
+		return fmt.Errorf("plugin %s: Transforms has type %T, want map[string]pipeline.Transform", path, sym)
+	}
+	for name, t := range *ts {
+		RegisterTransform(name, t)
+		log.Printf("loaded transform %q from %s", name, path)
+	}
+	return nil
+}
+
+// execTransform runs an external transformer process and exchanges frames
+// with it over stdio: each data frame goes to the child as a big-endian
+// uint32 length followed by the encoded frame, and the child answers in
+// the same framing. A zero-length answer drops the frame. Errors disable
+// the child and pass frames through unchanged.
+type execTransform struct {
+	path   string
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	broken bool
+}
+
+func (e *execTransform) Name() string { return "exec:" + e.path }
+
+func (e *execTransform) start() error {
+	cmd := exec.Command(e.path)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	e.cmd = cmd
+	e.stdin = stdin
+	e.stdout = bufio.NewReader(stdout)
+	log.Printf("started transformer process %s (pid %d)", e.path, cmd.Process.Pid)
+	return nil
+}
+
+func (e *execTransform) Apply(st *StreamContext, df *c37.DataFrame) (keep, mutated bool) {
+	if e.broken || st.Cfg == nil {
+		return true, false
+	}
+	if e.cmd == nil {
+		if err := e.start(); err != nil {
+			log.Printf("cannot start transformer %s: %v, passing frames through", e.path, err)
+			e.broken = true
+			return true, false
+		}
+	}
+	frame := c37.EncodeDataFrame(df, st.Cfg)
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(frame)))
+	if _, err := e.stdin.Write(size[:]); err != nil {
+		return true, e.fail(err)
+	}
+	if _, err := e.stdin.Write(frame); err != nil {
+		return true, e.fail(err)
+	}
+	if _, err := io.ReadFull(e.stdout, size[:]); err != nil {
+		return true, e.fail(err)
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n == 0 {
+		return false, false
+	}
+	resp := make([]byte, n)
+	if _, err := io.ReadFull(e.stdout, resp); err != nil {
+		return true, e.fail(err)
+	}
+	out, err := c37.DecodeDataFrame(resp, st.Cfg)
+	if err != nil {
+		log.Printf("transformer %s returned an undecodable frame: %v", e.path, err)
+		return true, false
+	}
+	*df = *out
+	return true, true
+}
+
+// fail marks the child broken after an I/O error; frames pass through
+// from then on.
+func (e *execTransform) fail(err error) bool {
+	log.Printf("transformer %s failed: %v, passing frames through", e.path, err)
+	e.broken = true
+	if e.cmd != nil && e.cmd.Process != nil {
+		e.cmd.Process.Kill()
+	}
+	return false
+}
+
+// pluginTransform resolves a chain entry against the registered custom
+// transforms and the exec: convention.
+func pluginTransform(name string) (Transform, bool) {
+	if t, ok := customTransforms[name]; ok {
+		return t, true
+	}
+	if path, ok := strings.CutPrefix(name, "exec:"); ok {
+		return &execTransform{path: path}, true
+	}
+	return nil, false
+}
@@ -0,0 +1,117 @@
+package pipeline
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// PowerMapping pairs a voltage and a current phasor of one PMU block so
+// active, reactive and apparent power can be derived from them.
+type PowerMapping struct {
+	IDCode  uint16
+	PMU     int
+	Voltage int // phasor index of the voltage channel
+	Current int // phasor index of the current channel
+	Name    string
+}
+
+var powerMappings []PowerMapping
+
+// ParsePowerMappings parses the -power flag: comma-separated
+// "IDCODE:pmu:voltage:current=NAME" entries, e.g. "41:0:0:3=LINE1". Each
+// mapping adds three analog channels NAME_P, NAME_Q and NAME_S to the
+// outgoing stream.
+func ParsePowerMappings(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		chans, name, ok := strings.Cut(entry, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("invalid power mapping %q, want IDCODE:pmu:voltage:current=NAME", entry)
+		}
+		parts := strings.Split(chans, ":")
+		if len(parts) != 4 {
+			return fmt.Errorf("invalid power mapping %q, want IDCODE:pmu:voltage:current=NAME", entry)
+		}
+		idCode, err := strconv.ParseUint(parts[0], 10, 16)
+		if err != nil {
+			return fmt.Errorf("invalid IDCODE in power mapping %q: %v", entry, err)
+		}
+		m := PowerMapping{IDCode: uint16(idCode), Name: name}
+		if m.PMU, err = strconv.Atoi(parts[1]); err != nil || m.PMU < 0 {
+			return fmt.Errorf("invalid PMU index in power mapping %q", entry)
+		}
+		if m.Voltage, err = strconv.Atoi(parts[2]); err != nil || m.Voltage < 0 {
+			return fmt.Errorf("invalid voltage phasor index in power mapping %q", entry)
+		}
+		if m.Current, err = strconv.Atoi(parts[3]); err != nil || m.Current < 0 {
+			return fmt.Errorf("invalid current phasor index in power mapping %q", entry)
+		}
+		powerMappings = append(powerMappings, m)
+	}
+	return nil
+}
+
+// powerMappingsFor returns the mappings configured for one stream.
+func powerMappingsFor(idCode uint16) []PowerMapping {
+	var out []PowerMapping
+	for _, m := range powerMappings {
+		if m.IDCode == idCode {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// withPowerChannels returns a copy of cfg with the derived power channels
+// of the stream appended as analog channels, or cfg itself when none are
+// configured. The copy describes the frames injectPowerChannels produces.
+func withPowerChannels(cfg *c37.CfgFrame2) *c37.CfgFrame2 {
+	maps := powerMappingsFor(cfg.Header.IDCode)
+	if len(maps) == 0 {
+		return cfg
+	}
+	out := *cfg
+	out.PMUs = append([]c37.PMUConfig(nil), cfg.PMUs...)
+	for _, m := range maps {
+		if m.PMU >= len(out.PMUs) {
+			continue
+		}
+		pc := &out.PMUs[m.PMU]
+		pc.AnalogNames = append(append([]string(nil), pc.AnalogNames...),
+			m.Name+"_P", m.Name+"_Q", m.Name+"_S")
+		units := append([]c37.AnalogUnit(nil), pc.AnalogUnits...)
+		for i := 0; i < 3; i++ {
+			units = append(units, c37.AnalogUnit{Type: 1, Factor: 1})
+		}
+		pc.AnalogUnits = units
+		pc.NumAnalogs += 3
+	}
+	return &out
+}
+
+// injectPowerChannels computes P, Q and S for every mapping of the stream
+// and re-encodes the data frame with the results appended as analog
+// channels. Power is per phasor pair: positive-sequence inputs yield
+// one third of the three-phase total.
+func injectPowerChannels(st *StreamContext, df *c37.DataFrame) []byte {
+	for _, m := range powerMappingsFor(st.IDCode) {
+		if m.PMU >= len(df.PMUs) {
+			continue
+		}
+		pd := &df.PMUs[m.PMU]
+		if m.Voltage >= len(pd.Phasors) || m.Current >= len(pd.Phasors) {
+			continue
+		}
+		v, i := pd.Phasors[m.Voltage], pd.Phasors[m.Current]
+		s := v.Magnitude * i.Magnitude
+		phi := v.Angle - i.Angle
+		pd.Analogs = append(pd.Analogs, s*math.Cos(phi), s*math.Sin(phi), s)
+	}
+	return c37.EncodeDataFrame(df, st.Cfg)
+}
@@ -0,0 +1,153 @@
+package pipeline
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// ClientProfile is the reduction one TCP server client gets instead of
+// the shared output: its own data rate and optionally a subset of the
+// phasor channels.
+type ClientProfile struct {
+	Rate    int
+	Phasors []int // phasor indexes kept per PMU block; nil keeps all
+}
+
+// clientProfiles maps client IPs to their reduction profile.
+var clientProfiles = make(map[string]ClientProfile)
+
+// ParseClientProfiles parses the -client_profile flag: comma-separated
+// "IP=rate" entries with an optional phasor subset appended as
+// dot-separated indexes, e.g. "10.0.0.5=25,10.0.0.6=10/0.1.2".
+func ParseClientProfiles(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		ip, rest, ok := strings.Cut(entry, "=")
+		if !ok || net.ParseIP(ip) == nil {
+			return fmt.Errorf("invalid client profile %q, want IP=rate[/phasors]", entry)
+		}
+		rate, subset, _ := strings.Cut(rest, "/")
+		var p ClientProfile
+		var err error
+		if p.Rate, err = strconv.Atoi(rate); err != nil || p.Rate < 0 {
+			return fmt.Errorf("invalid rate in client profile %q", entry)
+		}
+		if subset != "" {
+			for _, s := range strings.Split(subset, ".") {
+				idx, err := strconv.Atoi(s)
+				if err != nil || idx < 0 {
+					return fmt.Errorf("invalid phasor index %q in client profile %q", s, entry)
+				}
+				p.Phasors = append(p.Phasors, idx)
+			}
+		}
+		clientProfiles[ip] = p
+	}
+	return nil
+}
+
+// profileFor returns the profile configured for a client address, or nil.
+func profileFor(addr net.Addr) *ClientProfile {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil
+	}
+	if p, ok := clientProfiles[host]; ok {
+		return &p
+	}
+	return nil
+}
+
+// subsetConfig cuts every PMU block of the configuration down to the
+// phasor indexes in idx; out-of-range indexes are skipped per block.
+func subsetConfig(cfg *c37.CfgFrame2, idx []int) *c37.CfgFrame2 {
+	out := *cfg
+	out.PMUs = make([]c37.PMUConfig, len(cfg.PMUs))
+	for i := range cfg.PMUs {
+		pc := cfg.PMUs[i]
+		var names []string
+		var units []c37.PhasorUnit
+		for _, j := range idx {
+			if j >= int(pc.NumPhasors) {
+				continue
+			}
+			if j < len(pc.PhasorNames) {
+				names = append(names, pc.PhasorNames[j])
+			}
+			if j < len(pc.PhasorUnits) {
+				units = append(units, pc.PhasorUnits[j])
+			}
+		}
+		pc.NumPhasors = uint16(len(units))
+		pc.PhasorNames = names
+		pc.PhasorUnits = units
+		out.PMUs[i] = pc
+	}
+	return &out
+}
+
+// subsetDataFrame keeps only the phasor indexes in idx, mirroring
+// subsetConfig so the re-encoded frame matches the client's CFG.
+func subsetDataFrame(df *c37.DataFrame, idx []int) *c37.DataFrame {
+	out := *df
+	out.PMUs = make([]c37.PMUData, len(df.PMUs))
+	for i := range df.PMUs {
+		pd := df.PMUs[i]
+		var phasors []c37.Phasor
+		for _, j := range idx {
+			if j < len(pd.Phasors) {
+				phasors = append(phasors, pd.Phasors[j])
+			}
+		}
+		pd.Phasors = phasors
+		out.PMUs[i] = pd
+	}
+	return &out
+}
+
+// rewriteData re-encodes an outgoing data frame with the profile's
+// channel subset. Frames that cannot be decoded (unknown stream, rewritten
+// IDCODE) pass through unmodified rather than being lost.
+func (p *ClientProfile) rewriteData(frame []byte, h *c37.Header) []byte {
+	if len(p.Phasors) == 0 {
+		return frame
+	}
+	pipelineMu.Lock()
+	defer pipelineMu.Unlock()
+	st := lookupStream(h.IDCode)
+	if st == nil || st.Cfg == nil || int(h.FrameSize) > len(frame) {
+		return frame
+	}
+	df, err := c37.DecodeDataFrame(frame[:h.FrameSize], st.Cfg)
+	if err != nil {
+		return frame
+	}
+	return c37.EncodeDataFrame(subsetDataFrame(df, p.Phasors), subsetConfig(st.Cfg, p.Phasors))
+}
+
+// rewriteConfig re-encodes an outgoing configuration frame with the
+// profile's rate and channel subset.
+func (p *ClientProfile) rewriteConfig(frame []byte, h *c37.Header) []byte {
+	if int(h.FrameSize) > len(frame) {
+		return frame
+	}
+	// FrameSize strips the trailing padding ConvertConfigurationFrame adds.
+	cfg, err := c37.DecodeConfigFrame2(frame[:h.FrameSize])
+	if err != nil {
+		return frame
+	}
+	if p.Rate > 0 {
+		cfg.DataRate = int16(p.Rate)
+	}
+	if len(p.Phasors) > 0 {
+		cfg = subsetConfig(cfg, p.Phasors)
+	}
+	// Same trailing padding as the shared output configuration.
+	return append(c37.EncodeConfigFrame2(cfg), cfgPadding(h.IDCode)...)
+}
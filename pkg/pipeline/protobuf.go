@@ -0,0 +1,194 @@
+package pipeline
+
+import (
+	"context"
+	"log"
+	"math"
+	"os"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// The proto output emits decoded frames as length-prefixed protobuf
+// records per proto/c37.proto. The messages are marshaled by hand with
+// protowire against the field numbers in that file, which keeps protoc
+// out of the build; any change there must be mirrored here.
+
+func appendMessage(buf []byte, field protowire.Number, msg []byte) []byte {
+	buf = protowire.AppendTag(buf, field, protowire.BytesType)
+	return protowire.AppendBytes(buf, msg)
+}
+
+func appendUint32(buf []byte, field protowire.Number, v uint32) []byte {
+	if v == 0 {
+		return buf
+	}
+	buf = protowire.AppendTag(buf, field, protowire.VarintType)
+	return protowire.AppendVarint(buf, uint64(v))
+}
+
+func appendDouble(buf []byte, field protowire.Number, v float64) []byte {
+	if v == 0 {
+		return buf
+	}
+	buf = protowire.AppendTag(buf, field, protowire.Fixed64Type)
+	return protowire.AppendFixed64(buf, math.Float64bits(v))
+}
+
+func appendString(buf []byte, field protowire.Number, s string) []byte {
+	if s == "" {
+		return buf
+	}
+	buf = protowire.AppendTag(buf, field, protowire.BytesType)
+	return protowire.AppendString(buf, s)
+}
+
+func marshalHeader(h *c37.Header) []byte {
+	var buf []byte
+	buf = appendUint32(buf, 1, uint32(h.Type))
+	buf = appendUint32(buf, 2, uint32(h.Version))
+	buf = appendUint32(buf, 3, uint32(h.IDCode))
+	buf = appendUint32(buf, 4, h.SOC)
+	buf = appendUint32(buf, 5, h.FracSecValue())
+	buf = appendUint32(buf, 6, uint32(h.TimeQuality()))
+	if Restamp {
+		if orig, ok := origTimeFor(h.IDCode, h.SOC, h.FracSecValue()); ok {
+			buf = appendUint32(buf, 7, orig.soc)
+			buf = appendUint32(buf, 8, orig.fracSec)
+		}
+	}
+	return buf
+}
+
+func marshalDataFrame(df *c37.DataFrame, u *AngleUnwrapper) []byte {
+	var buf []byte
+	buf = appendMessage(buf, 1, marshalHeader(&df.Header))
+	var cont, rel [][]float64
+	if u != nil {
+		cont, rel = u.UnwrapFrame(df.Header.IDCode, df)
+	}
+	for i := range df.PMUs {
+		pmu := &df.PMUs[i]
+		var pb []byte
+		pb = appendUint32(pb, 1, uint32(pmu.Stat.Raw))
+		for j, ph := range pmu.Phasors {
+			var phb []byte
+			phb = appendDouble(phb, 1, ph.Magnitude)
+			phb = appendDouble(phb, 2, ph.Angle)
+			if cont != nil {
+				phb = appendDouble(phb, 3, cont[i][j])
+				phb = appendDouble(phb, 4, rel[i][j])
+			}
+			pb = appendMessage(pb, 2, phb)
+		}
+		pb = appendDouble(pb, 3, pmu.Freq)
+		pb = appendDouble(pb, 4, pmu.DFreq)
+		if len(pmu.Analogs) > 0 {
+			var packed []byte
+			for _, a := range pmu.Analogs {
+				packed = protowire.AppendFixed64(packed, math.Float64bits(a))
+			}
+			pb = appendMessage(pb, 5, packed)
+		}
+		if len(pmu.Digitals) > 0 {
+			var packed []byte
+			for _, d := range pmu.Digitals {
+				packed = protowire.AppendVarint(packed, uint64(d))
+			}
+			pb = appendMessage(pb, 6, packed)
+		}
+		buf = appendMessage(buf, 2, pb)
+	}
+	return buf
+}
+
+func marshalConfigFrame(cfg *c37.CfgFrame2) []byte {
+	var buf []byte
+	buf = appendMessage(buf, 1, marshalHeader(&cfg.Header))
+	buf = appendUint32(buf, 2, cfg.TimeBase)
+	for i := range cfg.PMUs {
+		pc := &cfg.PMUs[i]
+		var pb []byte
+		pb = appendString(pb, 1, pc.StationName)
+		pb = appendUint32(pb, 2, uint32(pc.IDCode))
+		for _, n := range pc.PhasorNames {
+			pb = appendString(pb, 3, n)
+		}
+		for _, n := range pc.AnalogNames {
+			pb = appendString(pb, 4, n)
+		}
+		for _, n := range pc.DigitalNames {
+			pb = appendString(pb, 5, n)
+		}
+		pb = appendUint32(pb, 6, uint32(pc.FNom))
+		buf = appendMessage(buf, 3, pb)
+	}
+	if cfg.DataRate != 0 {
+		buf = protowire.AppendTag(buf, 4, protowire.VarintType)
+		buf = protowire.AppendVarint(buf, protowire.EncodeZigZag(int64(cfg.DataRate)))
+	}
+	return buf
+}
+
+// marshalRecord wraps a frame into the Record oneof and prefixes its
+// varint length.
+func marshalRecord(field protowire.Number, msg []byte) []byte {
+	rec := appendMessage(nil, field, msg)
+	out := protowire.AppendVarint(nil, uint64(len(rec)))
+	return append(out, rec...)
+}
+
+// StartProtoWriter writes every decodable frame from the stream to
+// out.FilePath as length-prefixed protobuf records until ctx is
+// cancelled. Frames that cannot be decoded (no configuration yet, or
+// unsupported type) are skipped.
+func StartProtoWriter(ctx context.Context, out *OutputStream) error {
+	f, err := os.OpenFile(out.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var unwrapper *AngleUnwrapper
+	if UnwrapAngles {
+		unwrapper = NewAngleUnwrapper()
+	}
+	log.Printf("writing protobuf records to %s", out.FilePath)
+	for {
+		var frame []byte
+		select {
+		case frame = <-out.Frames:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		h, err := c37.DecodeHeader(frame)
+		if err != nil {
+			continue
+		}
+		var rec []byte
+		switch h.Type {
+		case c37.TypeData:
+			cfg := outputDecodeCfg(h.IDCode)
+			if cfg == nil {
+				continue
+			}
+			df, err := c37.DecodeDataFrame(frame, cfg)
+			if err != nil {
+				continue
+			}
+			rec = marshalRecord(1, marshalDataFrame(df, unwrapper))
+		case c37.TypeCfg1, c37.TypeCfg2:
+			cfg, err := c37.DecodeConfigFrame2(frame)
+			if err != nil {
+				continue
+			}
+			rec = marshalRecord(2, marshalConfigFrame(cfg))
+		default:
+			continue
+		}
+		if _, err := f.Write(rec); err != nil {
+			return err
+		}
+	}
+}
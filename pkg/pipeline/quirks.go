@@ -0,0 +1,81 @@
+package pipeline
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// quirkProfile collects the known deviations of one vendor family so they
+// are selected per stream instead of hardcoded.
+type quirkProfile struct {
+	Name string
+	// CfgPadding zero bytes are appended after the CRC of configuration
+	// frames sent to this stream's consumers. The SEL PDC used during
+	// commissioning refused the frame without five of them.
+	CfgPadding int
+	// TrimInput cuts received frames back to FRAMESIZE before the CRC
+	// check, tolerating transport-level padding after the frame.
+	TrimInput bool
+	// Lenient forces lenient decoding for the stream, accepting the
+	// vendor's non-standard field contents with warnings.
+	Lenient bool
+}
+
+// quirkProfiles are the supported vendor profiles, keyed by the lowercase
+// name used in the -quirks flag.
+var quirkProfiles = map[string]quirkProfile{
+	"sel":     {Name: "SEL", CfgPadding: 5, TrimInput: true},
+	"ge":      {Name: "GE", TrimInput: true, Lenient: true},
+	"arbiter": {Name: "Arbiter", Lenient: true},
+}
+
+var quirks = make(map[uint16]quirkProfile)
+
+// ParseQuirks parses the -quirks flag: comma-separated "IDCODE=profile"
+// entries, e.g. "41=sel,42=ge". IDCODE 0 selects the default profile for
+// streams without their own entry.
+func ParseQuirks(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		id, name, ok := strings.Cut(entry, "=")
+		if !ok {
+			return fmt.Errorf("invalid quirks entry %q, want IDCODE=profile", entry)
+		}
+		idCode, err := strconv.ParseUint(id, 10, 16)
+		if err != nil {
+			return fmt.Errorf("invalid IDCODE in quirks entry %q", entry)
+		}
+		p, ok := quirkProfiles[strings.ToLower(name)]
+		if !ok {
+			names := make([]string, 0, len(quirkProfiles))
+			for n := range quirkProfiles {
+				names = append(names, n)
+			}
+			return fmt.Errorf("unknown quirk profile %q (have %s)", name, strings.Join(names, ", "))
+		}
+		quirks[uint16(idCode)] = p
+	}
+	return nil
+}
+
+// quirksFor returns the profile of a stream, falling back to the IDCODE 0
+// default. Streams without any entry get the empty (standard-conforming)
+// profile.
+func quirksFor(idCode uint16) quirkProfile {
+	if p, ok := quirks[idCode]; ok {
+		return p
+	}
+	return quirks[0]
+}
+
+// cfgPadding returns the configuration-frame padding of a stream's
+// profile, ready to append after the CRC.
+func cfgPadding(idCode uint16) []byte {
+	if n := quirksFor(idCode).CfgPadding; n > 0 {
+		return make([]byte, n)
+	}
+	return nil
+}
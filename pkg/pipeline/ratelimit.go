@@ -0,0 +1,67 @@
+package pipeline
+
+import (
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// MaxSourcePPS caps the inbound packet rate per source address; a flooding
+// or misconfigured device is throttled there instead of starving the
+// other streams. 0 disables the limit.
+var MaxSourcePPS int
+
+// srcRate is the one-second receive window of one source address.
+type srcRate struct {
+	window time.Time
+	count  int
+}
+
+var (
+	srcRateMu    sync.Mutex
+	srcRates     = make(map[string]*srcRate)
+	srcRateDrops = make(map[string]uint64)
+)
+
+// RateLimitedBySource returns a snapshot of the per-source flood-drop
+// counters.
+func RateLimitedBySource() map[string]uint64 {
+	srcRateMu.Lock()
+	defer srcRateMu.Unlock()
+	out := make(map[string]uint64, len(srcRateDrops))
+	for k, n := range srcRateDrops {
+		out[k] = n
+	}
+	return out
+}
+
+// sourceWithinRate counts one inbound frame against its source's window
+// and reports whether the frame may enter the pipeline.
+func sourceWithinRate(ip net.IP) bool {
+	if MaxSourcePPS <= 0 || ip == nil {
+		return true
+	}
+	key := ip.String()
+	now := time.Now()
+	srcRateMu.Lock()
+	defer srcRateMu.Unlock()
+	r := srcRates[key]
+	if r == nil {
+		r = &srcRate{}
+		srcRates[key] = r
+	}
+	if now.Sub(r.window) >= time.Second {
+		r.window = now
+		r.count = 0
+	}
+	r.count++
+	if r.count <= MaxSourcePPS {
+		return true
+	}
+	if r.count == MaxSourcePPS+1 {
+		log.Printf("rate limiting %s: over %d packets/s", key, MaxSourcePPS)
+	}
+	srcRateDrops[key]++
+	return false
+}
@@ -0,0 +1,51 @@
+package pipeline
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+// A malformed frame that slips past validation can still take the whole
+// process down through a slice-bounds panic deep in the decoder.
+// recoverFrame confines the damage to the one frame: the panic is logged
+// with its stack, the offending frame is hex-dumped to PanicFile for a
+// bug report, and the other streams keep flowing.
+
+// PanicFile is the file offending frames are appended to after a
+// processing panic, one commented hex line per frame. Empty logs only.
+var PanicFile = "frame_panics.log"
+
+// PanicCount counts recovered per-frame panics.
+var PanicCount uint64
+
+// recoverFrame is deferred around per-frame processing.
+func recoverFrame(data []byte, src net.IP) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	atomic.AddUint64(&PanicCount, 1)
+	log.Printf("panic processing frame from %s: %v\n%s", src, r, debug.Stack())
+	dumpPanicFrame(data, src, r)
+}
+
+// dumpPanicFrame appends the offending frame to PanicFile.
+func dumpPanicFrame(data []byte, src net.IP, r any) {
+	if PanicFile == "" {
+		return
+	}
+	f, err := os.OpenFile(PanicFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Printf("cannot open panic file: %v", err)
+		return
+	}
+	defer f.Close()
+	fmt.Fprintf(f, "# %s src=%s panic=%v\n%s\n",
+		time.Now().Format(time.RFC3339), src, r, hex.EncodeToString(data))
+}
@@ -0,0 +1,168 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// The redis sink XADDs decoded measurements into Redis Streams, one
+// stream per PMU with a capped length, for lightweight real-time
+// dashboards that already sit on Redis. The RESP protocol is simple
+// enough to speak directly.
+
+// RedisMaxLen caps each PMU's stream via XADD MAXLEN.
+var RedisMaxLen = 10000
+
+// redisEntry is one pending XADD.
+type redisEntry struct {
+	key    string
+	fields []string
+}
+
+// redisCh carries entries to the sink goroutine; nil while the sink is
+// not running.
+var redisCh chan redisEntry
+
+// publishRedis queues the measurements of a reduced frame for the redis
+// sink. A slow or disconnected Redis loses entries rather than stalling
+// the pipeline.
+func publishRedis(st *StreamContext, df *c37.DataFrame) {
+	if redisCh == nil {
+		return
+	}
+	for i := range df.PMUs {
+		pmu := &df.PMUs[i]
+		name := strconv.Itoa(i)
+		if st.Cfg != nil && i < len(st.Cfg.PMUs) && st.Cfg.PMUs[i].StationName != "" {
+			name = strings.ReplaceAll(st.Cfg.PMUs[i].StationName, " ", "_")
+		}
+		e := redisEntry{
+			key: fmt.Sprintf("pmu:%d:%s", st.IDCode, name),
+			fields: []string{
+				"soc", strconv.FormatUint(uint64(df.Header.SOC), 10),
+				"fracsec", strconv.FormatUint(uint64(df.Header.FracSecValue()), 10),
+				"stat", strconv.FormatUint(uint64(pmu.Stat.Raw), 10),
+				"freq", strconv.FormatFloat(pmu.Freq, 'f', -1, 64),
+				"dfreq", strconv.FormatFloat(pmu.DFreq, 'f', -1, 64),
+			},
+		}
+		for j, ph := range pmu.Phasors {
+			e.fields = append(e.fields,
+				fmt.Sprintf("mag%d", j), strconv.FormatFloat(ph.Magnitude, 'f', -1, 64),
+				fmt.Sprintf("ang%d", j), strconv.FormatFloat(ph.Angle, 'f', -1, 64))
+		}
+		for j, a := range pmu.Analogs {
+			e.fields = append(e.fields,
+				fmt.Sprintf("an%d", j), strconv.FormatFloat(a, 'f', -1, 64))
+		}
+		select {
+		case redisCh <- e:
+		default:
+		}
+	}
+}
+
+// StartRedisSink connects to the Redis server at addr and writes queued
+// entries until ctx is cancelled, reconnecting with a short delay after
+// errors.
+func StartRedisSink(ctx context.Context, addr string) error {
+	redisCh = make(chan redisEntry, 1024)
+	log.Printf("writing measurements to Redis Streams at %s", addr)
+	for {
+		if err := redisRun(ctx, addr); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("redis sink: %v", err)
+		}
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// redisRun serves one connection until it fails.
+func redisRun(ctx context.Context, addr string) error {
+	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	r := bufio.NewReader(conn)
+	for {
+		var e redisEntry
+		select {
+		case e = <-redisCh:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		args := append([]string{
+			"XADD", e.key, "MAXLEN", "~", strconv.Itoa(RedisMaxLen), "*",
+		}, e.fields...)
+		if _, err := conn.Write(respCommand(args)); err != nil {
+			return err
+		}
+		if err := discardRESPReply(r); err != nil {
+			return err
+		}
+	}
+}
+
+// respCommand encodes one command as a RESP array of bulk strings.
+func respCommand(args []string) []byte {
+	var buf []byte
+	buf = append(buf, fmt.Sprintf("*%d\r\n", len(args))...)
+	for _, a := range args {
+		buf = append(buf, fmt.Sprintf("$%d\r\n%s\r\n", len(a), a)...)
+	}
+	return buf
+}
+
+// discardRESPReply consumes one RESP reply, surfacing server errors.
+func discardRESPReply(r *bufio.Reader) error {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return fmt.Errorf("empty RESP reply")
+	}
+	switch line[0] {
+	case '+', ':':
+		return nil
+	case '-':
+		return fmt.Errorf("redis: %s", line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return err
+		}
+		_, err = r.Discard(n + 2)
+		return err
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			if err := discardRESPReply(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unexpected RESP reply %q", line)
+}
@@ -0,0 +1,54 @@
+// Package pipeline implements the frame reductor pipeline: listening for
+// C37.118 frames, reducing their rate and forwarding them to the configured
+// output. It is usable as a library; cmd/frame_reductor is a thin wrapper
+// around it.
+package pipeline
+
+// Reducer decimates a stream from the input data rate to the configured
+// output rate. It uses an error accumulator so rates that do not divide
+// evenly (e.g. 50 fps to 3 fps) still average out to the requested rate.
+// in and out hold the two rates cross-multiplied to a common base so
+// sub-hertz rates reduce exactly.
+type Reducer struct {
+	in  int
+	out int
+	acc int
+}
+
+// NewReducer creates a reducer from inRate down to outRate. Rates follow
+// the DATA_RATE convention of the standard: positive values are frames
+// per second, negative values are seconds per frame. An outRate of 0 or
+// one at least as high as inRate passes every frame.
+func NewReducer(inRate, outRate int) *Reducer {
+	inNum, inDen := rateRatio(inRate)
+	outNum, outDen := rateRatio(outRate)
+	return &Reducer{in: inNum * outDen, out: outNum * inDen}
+}
+
+// rateRatio returns a DATA_RATE value as a fraction in frames per second.
+func rateRatio(rate int) (num, den int) {
+	if rate < 0 {
+		return 1, -rate
+	}
+	return rate, 1
+}
+
+// rateLess reports whether DATA_RATE value a is a lower rate than b.
+func rateLess(a, b int) bool {
+	aNum, aDen := rateRatio(a)
+	bNum, bDen := rateRatio(b)
+	return aNum*bDen < bNum*aDen
+}
+
+// Keep reports whether the next frame of the stream should be forwarded.
+func (r *Reducer) Keep() bool {
+	if r.out <= 0 || r.in <= 0 || r.out >= r.in {
+		return true
+	}
+	r.acc += r.out
+	if r.acc >= r.in {
+		r.acc -= r.in
+		return true
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package pipeline
+
+import "testing"
+
+func TestRateRatio(t *testing.T) {
+	tests := []struct {
+		rate     int
+		num, den int
+	}{
+		{50, 50, 1},
+		{1, 1, 1},
+		{0, 0, 1},
+		{-2, 1, 2}, // one frame per 2 s
+		{-10, 1, 10},
+	}
+	for _, tt := range tests {
+		if num, den := rateRatio(tt.rate); num != tt.num || den != tt.den {
+			t.Errorf("rateRatio(%d) = %d/%d, want %d/%d", tt.rate, num, den, tt.num, tt.den)
+		}
+	}
+}
+
+func TestRateLess(t *testing.T) {
+	tests := []struct {
+		a, b int
+		want bool
+	}{
+		{10, 25, true},
+		{25, 10, false},
+		{25, 25, false},
+		{-2, 1, true},   // 0.5 fps < 1 fps
+		{-10, -2, true}, // 0.1 fps < 0.5 fps
+		{-2, -10, false},
+		{1, -2, false},
+	}
+	for _, tt := range tests {
+		if got := rateLess(tt.a, tt.b); got != tt.want {
+			t.Errorf("rateLess(%d, %d) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestReducerKeep(t *testing.T) {
+	tests := []struct {
+		name             string
+		inRate, outRate  int
+		frames, wantKept int
+	}{
+		{"even-division", 50, 10, 50, 10},
+		{"uneven-division", 50, 3, 50, 3},
+		{"passthrough-equal", 25, 25, 25, 25},
+		{"passthrough-higher", 25, 50, 25, 25},
+		{"passthrough-zero", 25, 0, 25, 25},
+		{"sub-hertz", 25, -2, 100, 2}, // 0.5 fps from 25 fps
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewReducer(tt.inRate, tt.outRate)
+			kept := 0
+			for i := 0; i < tt.frames; i++ {
+				if r.Keep() {
+					kept++
+				}
+			}
+			if kept != tt.wantKept {
+				t.Errorf("kept %d of %d frames at %d->%d, want %d",
+					kept, tt.frames, tt.inRate, tt.outRate, tt.wantKept)
+			}
+		})
+	}
+}
+
+// TestReducerSpacing checks that the error accumulator spreads kept frames
+// out instead of bunching them at the start of each second.
+func TestReducerSpacing(t *testing.T) {
+	r := NewReducer(50, 10)
+	last := -1
+	for i := 0; i < 50; i++ {
+		if !r.Keep() {
+			continue
+		}
+		if last >= 0 && i-last != 5 {
+			t.Fatalf("kept frames %d and %d are %d apart, want 5", last, i, i-last)
+		}
+		last = i
+	}
+}
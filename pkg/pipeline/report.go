@@ -0,0 +1,119 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// ReportFile is an optional path the final statistics report is written
+// to as JSON, in addition to the stdout summary.
+var ReportFile string
+
+var startedAt = time.Now()
+
+// StreamReport is the per-PMU-stream section of the final report.
+type StreamReport struct {
+	IDCode       uint16  `json:"idcode"`
+	Version      uint8   `json:"version"`
+	FramesIn     uint64  `json:"frames_in"`
+	FramesOut    uint64  `json:"frames_out"`
+	DecodeErrors uint64  `json:"decode_errors"`
+	TQDrops      uint64  `json:"tq_drops,omitempty"`
+	LatencyMinMs float64 `json:"latency_min_ms"`
+	LatencyAvgMs float64 `json:"latency_avg_ms"`
+	LatencyMaxMs float64 `json:"latency_max_ms"`
+}
+
+// OutputReport is the per-output section of the final report.
+type OutputReport struct {
+	Protocol string `json:"protocol"`
+	Addr     string `json:"addr"`
+	Dropped  uint64 `json:"dropped"`
+}
+
+// FinalReport is the run summary written on shutdown.
+type FinalReport struct {
+	Started  time.Time         `json:"started"`
+	Duration float64           `json:"duration_s"`
+	Paths    map[int]PathStats `json:"paths,omitempty"`
+	Streams  []StreamReport    `json:"streams"`
+	Outputs  []OutputReport    `json:"outputs"`
+	Alarms   map[string]uint64 `json:"alarms,omitempty"`
+}
+
+// FinalStats collects the run summary from the stream, path, output and
+// alarm counters.
+func FinalStats(outs *Outputs) FinalReport {
+	rep := FinalReport{
+		Started:  startedAt,
+		Duration: time.Since(startedAt).Seconds(),
+		Paths:    PathHealth(),
+		Alarms:   AlarmCounts(),
+	}
+	pipelineMu.Lock()
+	for _, st := range Streams() {
+		sr := StreamReport{
+			IDCode:       st.IDCode,
+			Version:      st.Stats.Version,
+			FramesIn:     st.Stats.FramesIn,
+			FramesOut:    st.Stats.FramesOut,
+			DecodeErrors: st.Stats.DecodeErrors,
+			TQDrops:      st.Stats.TQDrops,
+		}
+		if st.Stats.latencyCount > 0 {
+			sr.LatencyMinMs = float64(st.Stats.LatencyMin) / float64(time.Millisecond)
+			sr.LatencyAvgMs = float64(st.Stats.latencySum) / float64(st.Stats.latencyCount) / float64(time.Millisecond)
+			sr.LatencyMaxMs = float64(st.Stats.LatencyMax) / float64(time.Millisecond)
+		}
+		rep.Streams = append(rep.Streams, sr)
+	}
+	pipelineMu.Unlock()
+	sort.Slice(rep.Streams, func(i, j int) bool { return rep.Streams[i].IDCode < rep.Streams[j].IDCode })
+	if outs != nil {
+		for _, os := range outs.Streams() {
+			rep.Outputs = append(rep.Outputs, OutputReport{
+				Protocol: os.Protocol,
+				Addr:     os.Addr(),
+				Dropped:  atomic.LoadUint64(&os.Dropped),
+			})
+		}
+	}
+	return rep
+}
+
+// WriteFinalReport prints the run summary to stdout and, with ReportFile
+// set, writes it there as JSON for post-run analysis.
+func WriteFinalReport(outs *Outputs) {
+	rep := FinalStats(outs)
+	fmt.Printf("run duration: %s\n", time.Duration(rep.Duration*float64(time.Second)).Round(time.Second))
+	for port, ps := range rep.Paths {
+		fmt.Printf("port %d: %d frames, %d duplicates\n", port, ps.Frames, ps.Duplicates)
+	}
+	for _, sr := range rep.Streams {
+		fmt.Printf("IDCODE %d (v%d): in %d, out %d, decode errors %d, tq drops %d, latency %.1f/%.1f/%.1f ms\n",
+			sr.IDCode, sr.Version, sr.FramesIn, sr.FramesOut, sr.DecodeErrors, sr.TQDrops,
+			sr.LatencyMinMs, sr.LatencyAvgMs, sr.LatencyMaxMs)
+	}
+	for _, or := range rep.Outputs {
+		fmt.Printf("output %s %s: %d dropped\n", or.Protocol, or.Addr, or.Dropped)
+	}
+	for key, n := range rep.Alarms {
+		fmt.Printf("alarm %s: tripped %d time(s)\n", key, n)
+	}
+	if ReportFile == "" {
+		return
+	}
+	data, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		log.Printf("cannot marshal final report: %v", err)
+		return
+	}
+	if err := os.WriteFile(ReportFile, append(data, '\n'), 0o644); err != nil {
+		log.Printf("cannot write final report: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package pipeline
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// Restamp replaces SOC/FRACSEC on emitted data frames with the
+// reductor's own output time aligned to the reporting interval, the
+// C37.244 PDC behavior. The original PMU timestamps stay available to
+// the analytics outputs via origTimeFor.
+var Restamp bool
+
+// origStamp is the source timestamp of one restamped frame.
+type origStamp struct {
+	soc     uint32
+	fracSec uint32
+}
+
+type origKey struct {
+	idCode  uint16
+	soc     uint32
+	fracSec uint32
+}
+
+// origTimes maps restamped output timestamps back to the source ones for
+// the analytics sinks, bounded FIFO so a sink that never looks up does
+// not leak.
+var (
+	origMu    sync.Mutex
+	origTimes = make(map[origKey]origStamp)
+	origOrder []origKey
+)
+
+const origTimesMax = 4096
+
+func recordOrigTime(key origKey, orig origStamp) {
+	origMu.Lock()
+	defer origMu.Unlock()
+	if _, ok := origTimes[key]; !ok {
+		origOrder = append(origOrder, key)
+	}
+	origTimes[key] = orig
+	for len(origOrder) > origTimesMax {
+		delete(origTimes, origOrder[0])
+		origOrder = origOrder[1:]
+	}
+}
+
+// origTimeFor returns the source timestamp of a restamped frame, if the
+// reductor restamped it.
+func origTimeFor(idCode uint16, soc, fracSec uint32) (origStamp, bool) {
+	origMu.Lock()
+	defer origMu.Unlock()
+	orig, ok := origTimes[origKey{idCode, soc, fracSec}]
+	return orig, ok
+}
+
+// restampFrame rewrites the SOC/FRACSEC of an outgoing data frame to the
+// wall clock truncated to the output reporting interval, keeping the
+// time-quality byte and fixing up the CRC. Other frame types pass
+// through unchanged.
+func restampFrame(st *StreamContext, frame []byte, outRate int) []byte {
+	if !Restamp {
+		return frame
+	}
+	h, err := c37.DecodeHeader(frame)
+	if err != nil || h.Type != c37.TypeData {
+		return frame
+	}
+	now := time.Now()
+	if outRate != 0 {
+		now = now.Truncate(rateInterval(outRate))
+	}
+	tb := uint32(1_000_000)
+	if st.Cfg != nil && st.Cfg.TimeBase != 0 {
+		tb = st.Cfg.TimeBase
+	}
+	soc := uint32(now.Unix())
+	fracSec := uint32(uint64(now.Nanosecond()) * uint64(tb) / 1e9)
+	out := append([]byte(nil), frame[:len(frame)-2]...)
+	h.SOC = soc
+	h.FracSec = h.FracSec&0xFF000000 | fracSec&0x00FFFFFF
+	h.Encode(out)
+	out = c37.AppendCRC(out)
+	recordOrigTime(origKey{h.IDCode, soc, fracSec & 0x00FFFFFF},
+		origStamp{
+			soc:     binary.BigEndian.Uint32(frame[6:10]),
+			fracSec: binary.BigEndian.Uint32(frame[10:14]) & 0x00FFFFFF,
+		})
+	return out
+}
@@ -0,0 +1,75 @@
+package pipeline
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// Retime decouples output cadence from input arrival: reduced data frames
+// are buffered briefly and released at exactly spaced wall-clock
+// intervals, so a bursty upstream still yields a steady downstream.
+var Retime bool
+
+// RetimeBuffer is how many data frames a retimer holds before the oldest
+// gives way. A small buffer bounds the added latency to a few reporting
+// intervals.
+var RetimeBuffer = 16
+
+// startRetimer interposes the retiming stage between Publish and the
+// stream's sender: Publish writes to ingress, the retimer drains it onto
+// Frames at the stream's data rate.
+func (s *OutputStream) startRetimer() {
+	s.ingress = make(chan []byte, cap(s.Frames))
+	s.quit = make(chan struct{})
+	go s.retime()
+}
+
+// retime forwards configuration frames immediately and data frames on an
+// exact wall-clock tick until the stream is removed.
+func (s *OutputStream) retime() {
+	tick := time.NewTicker(rateInterval(s.DataRate))
+	defer tick.Stop()
+	var queue [][]byte
+	for {
+		select {
+		case frame := <-s.ingress:
+			if h, err := c37.DecodeHeader(frame); err == nil && h.Type != c37.TypeData {
+				s.send(frame)
+				continue
+			}
+			if len(queue) >= RetimeBuffer {
+				queue = queue[1:]
+				atomic.AddUint64(&s.Dropped, 1)
+			}
+			queue = append(queue, frame)
+		case <-tick.C:
+			if len(queue) == 0 {
+				continue
+			}
+			s.send(queue[0])
+			queue = queue[1:]
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// rateInterval returns the reporting interval of a DATA_RATE value;
+// negative values are seconds per frame.
+func rateInterval(rate int) time.Duration {
+	if rate < 0 {
+		return time.Duration(-rate) * time.Second
+	}
+	return time.Second / time.Duration(rate)
+}
+
+// send hands a frame to the sender without ever blocking the retimer.
+func (s *OutputStream) send(frame []byte) {
+	select {
+	case s.Frames <- frame:
+	default:
+		atomic.AddUint64(&s.Dropped, 1)
+	}
+}
@@ -0,0 +1,24 @@
+package pipeline
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateInterval(t *testing.T) {
+	tests := []struct {
+		rate int
+		want time.Duration
+	}{
+		{50, 20 * time.Millisecond},
+		{25, 40 * time.Millisecond},
+		{1, time.Second},
+		{-2, 2 * time.Second},
+		{-60, time.Minute},
+	}
+	for _, tt := range tests {
+		if got := rateInterval(tt.rate); got != tt.want {
+			t.Errorf("rateInterval(%d) = %v, want %v", tt.rate, got, tt.want)
+		}
+	}
+}
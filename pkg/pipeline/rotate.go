@@ -0,0 +1,199 @@
+package pipeline
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// RotateSize rotates the capture file once it grows past this many bytes
+// (0 disables size-based rotation).
+var RotateSize int64
+
+// RotateInterval rotates the capture file after it has been open this long
+// (0 disables time-based rotation).
+var RotateInterval time.Duration
+
+// RotateKeep is how many rotated capture files are retained; older ones
+// are deleted. 0 keeps everything.
+var RotateKeep int
+
+// CaptureCompression compresses capture files on the fly: "gzip", "zstd"
+// or "" for plain text. Hex dumps of high-rate streams compress well.
+var CaptureCompression string
+
+// compressSuffix is the filename extension the active compression adds to
+// the capture path.
+func compressSuffix() string {
+	switch CaptureCompression {
+	case "gzip":
+		return ".gz"
+	case "zstd":
+		return ".zst"
+	}
+	return ""
+}
+
+// flusher is the subset of gzip.Writer and zstd.Encoder the capture writer
+// needs to push buffered data to disk between frames.
+type flusher interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// rotatingFile is a capture file that renames itself to a timestamped name
+// and starts fresh when the configured size or age limit is reached, so
+// long captures never produce one giant file.
+type rotatingFile struct {
+	path   string // path as configured, without the compression suffix
+	f      *os.File
+	c      flusher // compressor between w and f, nil for plain captures
+	w      *bufio.Writer
+	size   int64
+	opened time.Time
+}
+
+func newRotatingFile(path string) (*rotatingFile, error) {
+	r := &rotatingFile{path: path}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path+compressSuffix(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f = f
+	r.c = nil
+	var sink io.Writer = f
+	switch CaptureCompression {
+	case "gzip":
+		r.c = gzip.NewWriter(f)
+		sink = r.c
+	case "zstd":
+		enc, err := zstd.NewWriter(f)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		r.c = enc
+		sink = r.c
+	}
+	r.w = bufio.NewWriter(sink)
+	r.size = info.Size()
+	r.opened = time.Now()
+	return nil
+}
+
+// WriteLine appends one line to the capture, rotating first if the current
+// file has hit a limit.
+func (r *rotatingFile) WriteLine(line string) error {
+	if r.needsRotation() {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+	// The size limit tracks bytes written before compression; the file on
+	// disk is only smaller.
+	n, err := fmt.Fprintf(r.w, "%s\n", line)
+	r.size += int64(n)
+	if err != nil {
+		return err
+	}
+	if err := r.w.Flush(); err != nil {
+		return err
+	}
+	if r.c != nil {
+		return r.c.Flush()
+	}
+	return nil
+}
+
+func (r *rotatingFile) needsRotation() bool {
+	if RotateSize > 0 && r.size >= RotateSize {
+		return true
+	}
+	if RotateInterval > 0 && time.Since(r.opened) >= RotateInterval {
+		return true
+	}
+	return false
+}
+
+// rotate closes the current file, renames it to a timestamped sibling and
+// opens a fresh file under the original path.
+func (r *rotatingFile) rotate() error {
+	if err := r.Close(); err != nil {
+		return err
+	}
+	rotated := rotatedName(r.path, time.Now()) + compressSuffix()
+	if err := os.Rename(r.path+compressSuffix(), rotated); err != nil {
+		return err
+	}
+	log.Printf("rotated capture file to %s", rotated)
+	go uploadRotated(rotated)
+	r.prune()
+	return r.open()
+}
+
+func (r *rotatingFile) Close() error {
+	if r.f == nil {
+		return nil
+	}
+	if err := r.w.Flush(); err != nil {
+		r.f.Close()
+		return err
+	}
+	if r.c != nil {
+		if err := r.c.Close(); err != nil {
+			r.f.Close()
+			return err
+		}
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
+
+// rotatedName inserts a timestamp before the path's extension, e.g.
+// frames.txt becomes frames-20060102T150405.txt.
+func rotatedName(path string, t time.Time) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	return fmt.Sprintf("%s-%s%s", base, t.Format("20060102T150405"), ext)
+}
+
+// prune deletes the oldest rotated files beyond the retention count.
+func (r *rotatingFile) prune() {
+	if RotateKeep <= 0 {
+		return
+	}
+	ext := filepath.Ext(r.path)
+	base := strings.TrimSuffix(r.path, ext)
+	matches, err := filepath.Glob(base + "-*" + ext + compressSuffix())
+	if err != nil || len(matches) <= RotateKeep {
+		return
+	}
+	sort.Strings(matches) // timestamped names sort chronologically
+	for _, old := range matches[:len(matches)-RotateKeep] {
+		if err := os.Remove(old); err != nil {
+			log.Printf("cannot remove rotated capture %s: %v", old, err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package pipeline
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Route is a dedicated output destination for one input IDCODE, so a
+// single process can serve several independent reduced streams to
+// different consumers.
+type Route struct {
+	IDCode   uint16
+	Protocol string // "udp" or "tcp"
+	Addr     string
+	frames   chan []byte
+}
+
+// routes maps input IDCODEs to their dedicated outputs. Frames of IDCODEs
+// without a route go to the default output.
+var routes = make(map[uint16]*Route)
+
+// ParseRoutes parses a routing table of the form
+// "41=udp:10.0.0.5:7420,42=tcp:10.0.0.6:7421" and starts one sender per
+// route.
+func ParseRoutes(spec string) error {
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		idPart, dest, ok := strings.Cut(item, "=")
+		if !ok {
+			return fmt.Errorf("invalid route %q, want IDCODE=proto:host:port", item)
+		}
+		id, err := strconv.ParseUint(strings.TrimSpace(idPart), 10, 16)
+		if err != nil {
+			return fmt.Errorf("invalid IDCODE in route %q: %w", item, err)
+		}
+		proto, addr, ok := strings.Cut(dest, ":")
+		if !ok || (proto != "udp" && proto != "tcp") {
+			return fmt.Errorf("invalid destination in route %q, want udp:host:port or tcp:host:port", item)
+		}
+		r := &Route{
+			IDCode:   uint16(id),
+			Protocol: proto,
+			Addr:     addr,
+			frames:   make(chan []byte, 256),
+		}
+		routes[r.IDCode] = r
+		go r.run()
+	}
+	return nil
+}
+
+// routeFrame delivers a frame to its IDCODE-specific output if one is
+// configured; it reports whether the frame was consumed by a route.
+func routeFrame(idCode uint16, frame []byte) bool {
+	r, ok := routes[idCode]
+	if !ok {
+		return false
+	}
+	select {
+	case r.frames <- frame:
+	default:
+		log.Printf("route for IDCODE %d is congested, dropping frame", idCode)
+	}
+	return true
+}
+
+// run connects to the route's destination and writes its frames, redialing
+// with the default backoff after failures.
+func (r *Route) run() {
+	attempt := 0
+	for {
+		conn, err := net.Dial(r.Protocol, r.Addr)
+		if err != nil {
+			attempt++
+			delay := DefaultBackoff.Delay(attempt)
+			log.Printf("route IDCODE %d: cannot connect to %s %s: %v, retrying in %s",
+				r.IDCode, r.Protocol, r.Addr, err, delay)
+			time.Sleep(delay)
+			continue
+		}
+		attempt = 0
+		applyDSCP(conn)
+		log.Printf("route IDCODE %d: connected to %s %s", r.IDCode, r.Protocol, r.Addr)
+		for frame := range r.frames {
+			paceOutput()
+			if _, err := conn.Write(frame); err != nil {
+				log.Printf("route IDCODE %d: write failed: %v", r.IDCode, err)
+				break
+			}
+		}
+		conn.Close()
+	}
+}
@@ -0,0 +1,130 @@
+package pipeline
+
+import (
+	"log"
+	"math"
+	"sync/atomic"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// SelfCheck re-decodes every frame the reductor emits and compares it
+// field by field against the intended content, so an encoder bug is
+// logged at runtime instead of corrupting downstream data silently.
+var SelfCheck bool
+
+// SelfCheckFailures counts emitted frames that failed the self-check.
+var SelfCheckFailures uint64
+
+// selfCheckWarned suppresses the repeated notice when the emitted layout
+// diverges from the source layout and only the structural check applies.
+var selfCheckWarned bool
+
+// selfCheckData verifies an emitted data frame: CRC and FRAMESIZE always,
+// and the decoded values against df when the frame still carries the
+// source channel layout. Injected or format-converted frames change the
+// layout, so for those only the structural check applies.
+func selfCheckData(st *StreamContext, df *c37.DataFrame, out []byte) {
+	if !selfCheckFrameStructure(out) {
+		return
+	}
+	if len(deriveChannels) > 0 || len(powerMappingsFor(st.IDCode)) > 0 || convertFormat() {
+		if !selfCheckWarned {
+			selfCheckWarned = true
+			log.Printf("selfcheck: output layout differs from source, data frames get the structural check only")
+		}
+		return
+	}
+	dec, err := c37.DecodeDataFrame(out, st.Cfg)
+	if err != nil {
+		selfCheckFail("emitted data frame does not re-decode: %v", err)
+		return
+	}
+	if len(dec.PMUs) != len(df.PMUs) {
+		selfCheckFail("emitted data frame has %d PMU blocks, intended %d", len(dec.PMUs), len(df.PMUs))
+		return
+	}
+	for i := range df.PMUs {
+		want, got := &df.PMUs[i], &dec.PMUs[i]
+		if got.Stat.Raw != want.Stat.Raw {
+			selfCheckFail("PMU %d STAT %04X, intended %04X", i, got.Stat.Raw, want.Stat.Raw)
+		}
+		for j := range want.Phasors {
+			if j >= len(got.Phasors) ||
+				!selfCheckClose(got.Phasors[j].Magnitude, want.Phasors[j].Magnitude) ||
+				!selfCheckClose(got.Phasors[j].Angle, want.Phasors[j].Angle) {
+				selfCheckFail("PMU %d phasor %d diverged after encode", i, j)
+			}
+		}
+		if !selfCheckClose(got.Freq, want.Freq) || !selfCheckClose(got.DFreq, want.DFreq) {
+			selfCheckFail("PMU %d FREQ/DFREQ diverged after encode", i)
+		}
+		for j := range want.Analogs {
+			if j >= len(got.Analogs) || !selfCheckClose(got.Analogs[j], want.Analogs[j]) {
+				selfCheckFail("PMU %d analog %d diverged after encode", i, j)
+			}
+		}
+		for j := range want.Digitals {
+			if j >= len(got.Digitals) || got.Digitals[j] != want.Digitals[j] {
+				selfCheckFail("PMU %d digital word %d diverged after encode", i, j)
+			}
+		}
+	}
+}
+
+// selfCheckConfig verifies an emitted configuration frame structurally
+// and against the intended configuration.
+func selfCheckConfig(intended *c37.CfgFrame2, out []byte) {
+	if !selfCheckFrameStructure(out) {
+		return
+	}
+	dec, err := c37.DecodeConfigFrame2(out)
+	if err != nil {
+		selfCheckFail("emitted config frame does not re-decode: %v", err)
+		return
+	}
+	if len(dec.PMUs) != len(intended.PMUs) {
+		selfCheckFail("emitted config has %d PMU blocks, intended %d", len(dec.PMUs), len(intended.PMUs))
+		return
+	}
+	for i := range intended.PMUs {
+		want, got := &intended.PMUs[i], &dec.PMUs[i]
+		if got.StationName != want.StationName ||
+			got.NumPhasors != want.NumPhasors ||
+			got.NumAnalogs != want.NumAnalogs ||
+			got.NumDigitals != want.NumDigitals {
+			selfCheckFail("config PMU %d diverged after encode", i)
+		}
+	}
+}
+
+// selfCheckFrameStructure checks FRAMESIZE and CRC of an emitted frame;
+// vendor quirk padding after the CRC is allowed for.
+func selfCheckFrameStructure(out []byte) bool {
+	h, err := c37.DecodeHeader(out)
+	if err != nil {
+		selfCheckFail("emitted frame has no decodable header: %v", err)
+		return false
+	}
+	if int(h.FrameSize) > len(out) {
+		selfCheckFail("emitted frame FRAMESIZE %d exceeds %d sent bytes", h.FrameSize, len(out))
+		return false
+	}
+	if err := c37.VerifyCRC(out[:h.FrameSize]); err != nil {
+		selfCheckFail("emitted frame: %v", err)
+		return false
+	}
+	return true
+}
+
+func selfCheckFail(format string, args ...any) {
+	atomic.AddUint64(&SelfCheckFailures, 1)
+	log.Printf("selfcheck: "+format, args...)
+}
+
+// selfCheckClose tolerates the float32 rounding and the integer
+// quantization of the encoders; the self-check hunts for gross encoder
+// bugs, not quantization noise.
+func selfCheckClose(got, want float64) bool {
+	return math.Abs(got-want) <= 0.01+0.001*math.Abs(want)
+}
@@ -0,0 +1,74 @@
+package pipeline
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// A tcpSession records one TCP input or output connection: connect time,
+// peer address, frames and bytes transferred, and the disconnect reason.
+// Every session logs a summary line when it closes, and the per-kind
+// totals are exposed via the control API.
+type tcpSession struct {
+	kind    string // "input", "output" or "server"
+	peer    string
+	started time.Time
+	frames  uint64
+	bytes   uint64
+}
+
+// SessionTotals aggregates the sessions of one kind.
+type SessionTotals struct {
+	Sessions uint64 `json:"sessions"`
+	Frames   uint64 `json:"frames"`
+	Bytes    uint64 `json:"bytes"`
+}
+
+var (
+	sessionMu     sync.Mutex
+	sessionTotals = make(map[string]*SessionTotals)
+)
+
+// newSession opens the session record for a freshly established
+// connection.
+func newSession(kind, peer string) *tcpSession {
+	sessionMu.Lock()
+	t := sessionTotals[kind]
+	if t == nil {
+		t = &SessionTotals{}
+		sessionTotals[kind] = t
+	}
+	t.Sessions++
+	sessionMu.Unlock()
+	return &tcpSession{kind: kind, peer: peer, started: time.Now()}
+}
+
+// count records one transferred frame of n bytes.
+func (s *tcpSession) count(n int) {
+	s.frames++
+	s.bytes += uint64(n)
+}
+
+// close logs the session summary and folds its counters into the totals.
+func (s *tcpSession) close(reason string) {
+	log.Printf("TCP %s session %s closed: duration=%s frames=%d bytes=%d reason=%q",
+		s.kind, s.peer, time.Since(s.started).Round(time.Millisecond), s.frames, s.bytes, reason)
+	sessionMu.Lock()
+	t := sessionTotals[s.kind]
+	t.Frames += s.frames
+	t.Bytes += s.bytes
+	sessionMu.Unlock()
+}
+
+// SessionStats returns the per-kind totals across all TCP sessions,
+// including the ones still open.
+func SessionStats() map[string]SessionTotals {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	out := make(map[string]SessionTotals, len(sessionTotals))
+	for k, t := range sessionTotals {
+		out[k] = *t
+	}
+	return out
+}
@@ -0,0 +1,55 @@
+package pipeline
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// MaxClockSkew is the largest tolerated offset between a PMU's timestamps
+// and the host clock before the skew alarm trips. The host is assumed to
+// be NTP/PTP disciplined, so a sustained offset usually means a GPS
+// antenna problem at the PMU. 0 disables the alarm; the offset itself is
+// always tracked.
+var MaxClockSkew time.Duration
+
+// checkClockSkew folds the offset of one data frame into the stream's
+// smoothed PMU-to-host clock skew and alarms when it exceeds
+// MaxClockSkew. An EWMA with 1/16 gain rides out network jitter without
+// hiding a genuine clock step for long.
+func checkClockSkew(st *StreamContext, h *c37.Header) {
+	var timeBase uint32
+	if st.Cfg != nil {
+		timeBase = st.Cfg.TimeBase
+	}
+	skew := time.Since(h.Timestamp(timeBase))
+	if st.Stats.latencyCount == 0 || skew < st.Stats.LatencyMin {
+		st.Stats.LatencyMin = skew
+	}
+	if skew > st.Stats.LatencyMax {
+		st.Stats.LatencyMax = skew
+	}
+	st.Stats.latencySum += skew
+	st.Stats.latencyCount++
+	if st.Stats.ClockSkew == 0 {
+		st.Stats.ClockSkew = skew
+	} else {
+		st.Stats.ClockSkew += (skew - st.Stats.ClockSkew) / 16
+	}
+	if MaxClockSkew <= 0 {
+		return
+	}
+	mag := st.Stats.ClockSkew
+	if mag < 0 {
+		mag = -mag
+	}
+	transitionAlarm(fmt.Sprintf("%d/skew", st.IDCode), alarmEvent{
+		IDCode:  st.IDCode,
+		Kind:    "skew",
+		Value:   st.Stats.ClockSkew.Seconds(),
+		Limit:   MaxClockSkew.Seconds(),
+		SOC:     h.SOC,
+		FracSec: h.FracSecValue(),
+	}, mag.Seconds(), MaxClockSkew.Seconds())
+}
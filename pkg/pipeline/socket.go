@@ -0,0 +1,23 @@
+package pipeline
+
+import (
+	"log"
+	"net"
+)
+
+// DSCP is the DiffServ code point (0-63) stamped on outgoing frames, so
+// network QoS policies can prioritize the synchrophasor traffic. A value
+// of -1 leaves the socket at the operating system default.
+var DSCP = -1
+
+// applyDSCP marks an output socket with the configured DSCP value. Failure
+// to mark is logged but does not stop the output; the traffic then simply
+// travels best-effort.
+func applyDSCP(conn net.Conn) {
+	if DSCP < 0 {
+		return
+	}
+	if err := setDSCP(conn, DSCP); err != nil {
+		log.Printf("cannot set DSCP %d on %s: %v", DSCP, conn.LocalAddr(), err)
+	}
+}
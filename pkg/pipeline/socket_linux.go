@@ -0,0 +1,37 @@
+//go:build linux
+
+package pipeline
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// setDSCP writes the DSCP value into the IP TOS / IPv6 traffic class field
+// of the connection's socket.
+func setDSCP(conn net.Conn, dscp int) error {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return fmt.Errorf("connection does not expose its socket")
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var opErr error
+	err = raw.Control(func(fd uintptr) {
+		// The TOS byte carries the DSCP in its upper six bits.
+		tos := dscp << 2
+		if err := syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS, tos); err != nil {
+			opErr = err
+		}
+		// Also set the IPv6 traffic class; harmless on IPv4 sockets where
+		// it fails with ENOPROTOOPT.
+		_ = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS, tos)
+	})
+	if err != nil {
+		return err
+	}
+	return opErr
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package pipeline
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+)
+
+func setDSCP(conn net.Conn, dscp int) error {
+	return fmt.Errorf("DSCP marking is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,109 @@
+package pipeline
+
+import (
+	"encoding/binary"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// SpoolDir enables disk-backed store-and-forward for the TCP client
+// output: frames arriving while the connection is down are spooled there
+// and replayed, flagged as late data, once it recovers. Empty disables
+// spooling.
+var SpoolDir string
+
+// SpoolRetention caps how much late data the spool retains; older frames
+// are discarded while the outage lasts.
+var SpoolRetention = 5 * time.Minute
+
+// frameSpool is the on-disk outage buffer of one TCP output. It writes
+// length-prefixed frames into a segment file and rotates to a fresh
+// segment every half retention period, keeping one old segment, so the
+// disk footprint stays bounded at roughly SpoolRetention of frames.
+type frameSpool struct {
+	base    string
+	f       *os.File
+	started time.Time
+}
+
+func newFrameSpool(addr string) *frameSpool {
+	name := "spool_" + strings.ReplaceAll(addr, ":", "_") + ".bin"
+	return &frameSpool{base: filepath.Join(SpoolDir, name)}
+}
+
+// Append spools one frame, rotating segments when the current one covers
+// half the retention period.
+func (s *frameSpool) Append(frame []byte) {
+	if s.f != nil && time.Since(s.started) > SpoolRetention/2 {
+		s.f.Close()
+		s.f = nil
+		if err := os.Rename(s.base, s.base+".old"); err != nil {
+			log.Printf("cannot rotate spool segment: %v", err)
+		}
+	}
+	if s.f == nil {
+		f, err := os.Create(s.base)
+		if err != nil {
+			log.Printf("cannot open spool segment: %v", err)
+			return
+		}
+		s.f = f
+		s.started = time.Now()
+	}
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(frame)))
+	if _, err := s.f.Write(size[:]); err == nil {
+		s.f.Write(frame)
+	}
+}
+
+// Replay writes all spooled frames to w in arrival order, each flagged as
+// late data, and clears the spool. It returns the number of frames
+// replayed.
+func (s *frameSpool) Replay(w io.Writer) (int, error) {
+	if s.f != nil {
+		s.f.Close()
+		s.f = nil
+	}
+	n := 0
+	for _, path := range []string{s.base + ".old", s.base} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		for off := 0; off+4 <= len(data); {
+			size := int(binary.BigEndian.Uint32(data[off : off+4]))
+			off += 4
+			if off+size > len(data) {
+				break
+			}
+			if _, err := w.Write(flagLateData(data[off : off+size])); err != nil {
+				return n, err
+			}
+			off += size
+			n++
+		}
+		os.Remove(path)
+	}
+	return n, nil
+}
+
+// flagLateData marks a replayed data frame as modified by post-processing
+// (STAT bit 9 of the first PMU block) and recomputes the CRC. Frames that
+// are not data frames pass through unchanged.
+func flagLateData(frame []byte) []byte {
+	h, err := c37.DecodeHeader(frame)
+	if err != nil || h.Type != c37.TypeData || len(frame) < c37.CommonHeaderSize+4 {
+		return frame
+	}
+	out := append([]byte(nil), frame[:len(frame)-2]...)
+	stat := binary.BigEndian.Uint16(out[c37.CommonHeaderSize:])
+	binary.BigEndian.PutUint16(out[c37.CommonHeaderSize:], stat|0x0200)
+	return c37.AppendCRC(out)
+}
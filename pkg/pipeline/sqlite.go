@@ -0,0 +1,68 @@
+package pipeline
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// sqliteSchema holds one row per frame: the raw bytes next to the decoded
+// header fields, indexed by IDCODE and timestamp so captured windows can
+// be queried offline without a C37.118 parser.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS frames (
+	id          INTEGER PRIMARY KEY,
+	idcode      INTEGER NOT NULL,
+	frame_type  INTEGER NOT NULL,
+	soc         INTEGER NOT NULL,
+	fracsec     INTEGER NOT NULL,
+	received_at INTEGER NOT NULL, -- unix milliseconds
+	raw         BLOB    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS frames_idcode ON frames (idcode);
+CREATE INDEX IF NOT EXISTS frames_time   ON frames (soc, fracsec);
+`
+
+// StartSQLiteWriter archives every frame from the stream into the SQLite
+// database at out.FilePath until ctx is cancelled.
+func StartSQLiteWriter(ctx context.Context, out *OutputStream) error {
+	db, err := sql.Open("sqlite", out.FilePath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, sqliteSchema); err != nil {
+		return err
+	}
+	insert, err := db.PrepareContext(ctx,
+		`INSERT INTO frames (idcode, frame_type, soc, fracsec, received_at, raw)
+		 VALUES (?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+	log.Printf("archiving frames to %s", out.FilePath)
+	for {
+		var frame []byte
+		select {
+		case frame = <-out.Frames:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		h, err := c37.DecodeHeader(frame)
+		if err != nil {
+			// The pipeline already counted the frame; an undecodable one
+			// cannot be indexed, so skip it here.
+			continue
+		}
+		if _, err := insert.ExecContext(ctx, h.IDCode, int(h.Type), h.SOC,
+			h.FracSecValue(), time.Now().UnixMilli(), frame); err != nil {
+			return err
+		}
+	}
+}
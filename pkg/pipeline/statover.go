@@ -0,0 +1,59 @@
+package pipeline
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// statSetMask and statClearMask are OR'd into and masked out of the STAT
+// word of every outgoing data frame, for downstream PDCs with validation
+// rules the source does not satisfy (e.g. always clear trigger bits, or
+// force data-sorted).
+var (
+	statSetMask   uint16
+	statClearMask uint16
+)
+
+// ParseStatOverride parses the -stat_set and -stat_clear flags: each is a
+// STAT bit mask, e.g. "0x1000" or "4096".
+func ParseStatOverride(set, clear string) error {
+	var err error
+	if statSetMask, err = parseStatMask(set); err != nil {
+		return fmt.Errorf("invalid -stat_set %q: %v", set, err)
+	}
+	if statClearMask, err = parseStatMask(clear); err != nil {
+		return fmt.Errorf("invalid -stat_clear %q: %v", clear, err)
+	}
+	return nil
+}
+
+func parseStatMask(spec string) (uint16, error) {
+	if spec == "" {
+		return 0, nil
+	}
+	mask, err := strconv.ParseUint(spec, 0, 16)
+	if err != nil {
+		return 0, err
+	}
+	return uint16(mask), nil
+}
+
+// applyStatOverride rewrites the STAT words of a decoded frame in place;
+// true means the frame must be re-encoded. Bits are cleared before the
+// forced bits are set, so a bit in both masks ends up set.
+func applyStatOverride(df *c37.DataFrame) bool {
+	if statSetMask == 0 && statClearMask == 0 {
+		return false
+	}
+	mutated := false
+	for i := range df.PMUs {
+		raw := df.PMUs[i].Stat.Raw&^statClearMask | statSetMask
+		if raw != df.PMUs[i].Stat.Raw {
+			df.PMUs[i].Stat.Raw = raw
+			mutated = true
+		}
+	}
+	return mutated
+}
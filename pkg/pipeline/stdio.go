@@ -0,0 +1,77 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"log"
+	"os"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// ProcessStdin feeds raw binary C37.118 frames from standard input
+// through the reduction pipeline until EOF or ctx is cancelled, so the
+// reductor composes in Unix pipelines (e.g. socat | frame_reductor).
+// Frames delimit themselves via FRAMESIZE; garbage is skipped one byte
+// at a time until the next SYNC byte.
+func ProcessStdin(ctx context.Context, outs *Outputs) error {
+	log.Printf("reading C37.118 frames from stdin")
+	r := bufio.NewReaderSize(os.Stdin, 64*1024)
+	var buf []byte
+	tmp := make([]byte, 4096)
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		n, err := r.Read(tmp)
+		if n > 0 {
+			buf = append(buf, tmp[:n]...)
+			for len(buf) >= c37.CommonHeaderSize {
+				size := int(binary.BigEndian.Uint16(buf[2:4]))
+				if buf[0] != c37.SyncLead || size < c37.CommonHeaderSize+2 {
+					buf = buf[1:]
+					continue
+				}
+				if len(buf) < size {
+					break
+				}
+				frame := make([]byte, size)
+				copy(frame, buf[:size])
+				buf = buf[size:]
+				HandleFrame(frame, nil, outs)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// StartStdoutWriter writes every emitted frame to standard output as raw
+// binary until ctx is cancelled, the downstream half of pipeline
+// composition. Logs stay on stderr.
+func StartStdoutWriter(ctx context.Context, out *OutputStream) error {
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	for {
+		var frame []byte
+		select {
+		case frame = <-out.Frames:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if _, err := w.Write(frame); err != nil {
+			return err
+		}
+		// Flush per frame: pipeline consumers expect frames promptly, not
+		// on buffer boundaries.
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+}
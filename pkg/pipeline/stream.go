@@ -0,0 +1,157 @@
+package pipeline
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// StreamStats counts per-stream traffic for diagnostics.
+type StreamStats struct {
+	FramesIn     uint64
+	FramesOut    uint64
+	DecodeErrors uint64
+	Warnings     uint64
+	TQDrops      uint64
+	LastFrame    time.Time
+	// ClockSkew is the smoothed offset of the PMU's timestamps behind the
+	// host clock (negative: PMU ahead).
+	ClockSkew time.Duration
+	// TimeQuality is the message time quality of the last received frame.
+	TimeQuality c37.TimeQualityInfo
+	// Version is the C37.118 version nibble of the last received frame
+	// (1 = 2005, 2 = 2011).
+	Version uint8
+	// Per-frame latency extremes and totals (PMU timestamp to arrival),
+	// reported in the final statistics summary.
+	LatencyMin   time.Duration
+	LatencyMax   time.Duration
+	latencySum   time.Duration
+	latencyCount uint64
+	// The most recent decode error of the stream and when it happened,
+	// kept for the control API.
+	LastError   string
+	LastErrorAt time.Time
+}
+
+// recordError notes the most recent per-stream error for introspection.
+func (s *StreamStats) recordError(err error) {
+	s.LastError = err.Error()
+	s.LastErrorAt = time.Now()
+}
+
+// StreamContext holds the decode and reduction state of one PMU stream,
+// identified by the IDCODE in the frame headers. Several PMUs sending to
+// the same port each get their own context instead of overwriting each
+// other's configuration.
+type StreamContext struct {
+	IDCode  uint16
+	Cfg     *c37.CfgFrame2
+	Cfg3    *c37.CfgFrame3
+	Reducer *Reducer
+	Stats   StreamStats
+	// LastData is the most recently decoded data frame, for poll-based
+	// outputs (DNP3) that serve current values rather than the stream.
+	LastData *c37.DataFrame
+	// Cfg3Frags reassembles fragmented CFG-3 frames for this stream.
+	Cfg3Frags c37.Cfg3Reassembler
+}
+
+// StaleAfter is how long a stream may go without a frame before the
+// control API reports it stale.
+var StaleAfter = 5 * time.Second
+
+// State summarizes the stream's condition for the control API: "live"
+// while frames arrive and decode, "no-config" while data frames cannot be
+// decoded yet, and "stale" once nothing has arrived for StaleAfter.
+func (s *StreamContext) State() string {
+	if time.Since(s.Stats.LastFrame) > StaleAfter {
+		return "stale"
+	}
+	if s.Cfg == nil {
+		return "no-config"
+	}
+	return "live"
+}
+
+var (
+	streamsMu sync.Mutex
+	streams   = make(map[uint16]*StreamContext)
+)
+
+// streamFor returns the context of the stream with the given IDCODE,
+// creating it on first sight.
+func streamFor(idCode uint16) *StreamContext {
+	streamsMu.Lock()
+	defer streamsMu.Unlock()
+	st, ok := streams[idCode]
+	if !ok {
+		st = &StreamContext{IDCode: idCode}
+		streams[idCode] = st
+	}
+	return st
+}
+
+// lookupStream returns the context of a known stream without creating
+// one, for consumers that only ever read existing state.
+func lookupStream(idCode uint16) *StreamContext {
+	streamsMu.Lock()
+	defer streamsMu.Unlock()
+	return streams[idCode]
+}
+
+// outputDecodeCfg returns the configuration for decoding a data frame
+// read back off an output channel, or nil when none is known yet. The
+// snapshot is taken under pipelineMu so the decoded sinks (proto, arrow,
+// zmq JSON) do not race frame handling; configurations are replaced
+// wholesale on adoption and never mutated afterwards, so the returned
+// pointer stays valid after the lock is released.
+func outputDecodeCfg(id uint16) *c37.CfgFrame2 {
+	pipelineMu.Lock()
+	defer pipelineMu.Unlock()
+	st := sourceStream(id)
+	if st == nil {
+		return nil
+	}
+	// With format conversion active the frames on the wire follow the
+	// converted configuration; a stream whose configuration has not been
+	// converted yet passes through unchanged, so st.Cfg still applies.
+	if fc := outFmtCfgs[st.IDCode]; fc != nil {
+		return fc
+	}
+	return st.Cfg
+}
+
+// sourceStream maps the IDCODE carried by an output frame back to the
+// input stream it came from. With -output_idcode every outgoing frame
+// carries the override instead of the source's IDCODE, so the registry
+// must be searched rather than indexed; when several inputs collapse onto
+// the rewritten IDCODE (decodable downstream only if their layouts agree
+// anyway) the lowest source IDCODE wins for determinism.
+func sourceStream(id uint16) *StreamContext {
+	if OutputIDCode == 0 || id != OutputIDCode {
+		return lookupStream(id)
+	}
+	var src *StreamContext
+	for _, st := range Streams() {
+		if st.Cfg == nil {
+			continue
+		}
+		if src == nil || st.IDCode < src.IDCode {
+			src = st
+		}
+	}
+	return src
+}
+
+// Streams returns a snapshot of all known stream contexts.
+func Streams() []*StreamContext {
+	streamsMu.Lock()
+	defer streamsMu.Unlock()
+	out := make([]*StreamContext, 0, len(streams))
+	for _, st := range streams {
+		out = append(out, st)
+	}
+	return out
+}
@@ -0,0 +1,77 @@
+package pipeline
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// MaxUnlocked is how long a PMU may run without time sync (PMUSync clear
+// or a non-zero unlocked-time code in STAT) before its frames are dropped
+// or, with UnlockedFlag, down-flagged. Zero only tracks sync state
+// without acting on it.
+var MaxUnlocked time.Duration
+
+// UnlockedFlag selects down-flagging over dropping for long-unlocked
+// PMUs: the PMU-error bit is set in STAT and the frame is forwarded.
+var UnlockedFlag bool
+
+var (
+	syncMu sync.Mutex
+	// syncLost records when each PMU (keyed "IDCODE/pmu") lost time sync;
+	// PMUs in sync have no entry.
+	syncLost = make(map[string]time.Time)
+)
+
+// checkSyncLoss tracks the PMUSync and UnlockedTime STAT fields of a
+// decoded frame and applies the MaxUnlocked policy. It reports whether
+// the frame must be dropped and whether any STAT word was down-flagged.
+func checkSyncLoss(st *StreamContext, df *c37.DataFrame) (drop, flagged bool) {
+	now := time.Now()
+	for i := range df.PMUs {
+		pmu := &df.PMUs[i]
+		key := fmt.Sprintf("%d/%d", st.IDCode, i)
+		inSync := pmu.Stat.PMUSync && pmu.Stat.UnlockedTime == 0
+		syncMu.Lock()
+		since, wasLost := syncLost[key]
+		if inSync {
+			if wasLost {
+				delete(syncLost, key)
+				log.Printf("PMU %s regained time sync after %s", key, now.Sub(since).Round(time.Second))
+			}
+			syncMu.Unlock()
+			continue
+		}
+		if !wasLost {
+			since = now
+			syncLost[key] = since
+			log.Printf("PMU %s lost time sync", key)
+		}
+		syncMu.Unlock()
+		if MaxUnlocked <= 0 || now.Sub(since) <= MaxUnlocked {
+			continue
+		}
+		if UnlockedFlag {
+			pmu.Stat.Raw |= 0x4000 // DataError = PMU error
+			flagged = true
+		} else {
+			drop = true
+		}
+	}
+	return drop, flagged
+}
+
+// SyncLost returns a snapshot of the PMUs currently without time sync,
+// keyed "IDCODE/pmu", with the time each one lost it.
+func SyncLost() map[string]time.Time {
+	syncMu.Lock()
+	defer syncMu.Unlock()
+	out := make(map[string]time.Time, len(syncLost))
+	for k, t := range syncLost {
+		out[k] = t
+	}
+	return out
+}
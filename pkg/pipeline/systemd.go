@@ -0,0 +1,128 @@
+package pipeline
+
+import (
+	"context"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// systemd integration: listeners prefer sockets pre-bound by the service
+// manager (LISTEN_FDS socket activation), and the service reports
+// READY/WATCHDOG through NOTIFY_SOCKET. Both are no-ops outside systemd,
+// so the same binary runs unchanged in the foreground.
+
+var (
+	sdOnce  sync.Once
+	sdFiles []*os.File
+)
+
+// sdListenFDs collects the file descriptors passed by systemd, once.
+// Descriptors start at 3 by the socket activation protocol.
+func sdListenFDs() []*os.File {
+	sdOnce.Do(func() {
+		defer func() {
+			os.Unsetenv("LISTEN_PID")
+			os.Unsetenv("LISTEN_FDS")
+			os.Unsetenv("LISTEN_FDNAMES")
+		}()
+		pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+		if err != nil || pid != os.Getpid() {
+			return
+		}
+		n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+		if err != nil || n <= 0 {
+			return
+		}
+		for i := 0; i < n; i++ {
+			sdFiles = append(sdFiles, os.NewFile(uintptr(3+i), "LISTEN_FD_"+strconv.Itoa(3+i)))
+		}
+	})
+	return sdFiles
+}
+
+// activatedUDPConn returns a systemd-activated UDP socket bound to the
+// given port, or nil when none was passed.
+func activatedUDPConn(port int) *net.UDPConn {
+	for _, f := range sdListenFDs() {
+		pc, err := net.FilePacketConn(f)
+		if err != nil {
+			continue
+		}
+		uc, ok := pc.(*net.UDPConn)
+		if !ok {
+			pc.Close()
+			continue
+		}
+		if addr, ok := uc.LocalAddr().(*net.UDPAddr); ok && addr.Port == port {
+			return uc
+		}
+		uc.Close()
+	}
+	return nil
+}
+
+// activatedTCPListener returns a systemd-activated TCP listener bound to
+// the given port, or nil when none was passed.
+func activatedTCPListener(port int) net.Listener {
+	for _, f := range sdListenFDs() {
+		ln, err := net.FileListener(f)
+		if err != nil {
+			continue
+		}
+		if addr, ok := ln.Addr().(*net.TCPAddr); ok && addr.Port == port {
+			return ln
+		}
+		ln.Close()
+	}
+	return nil
+}
+
+// sdNotify sends one state message to the service manager. Missing
+// NOTIFY_SOCKET (not running under systemd) is not an error.
+func sdNotify(state string) {
+	sock := os.Getenv("NOTIFY_SOCKET")
+	if sock == "" {
+		return
+	}
+	conn, err := net.Dial("unixgram", sock)
+	if err != nil {
+		log.Printf("cannot reach systemd notify socket: %v", err)
+		return
+	}
+	defer conn.Close()
+	conn.Write([]byte(state))
+}
+
+// SDNotifyReady reports startup completion to the service manager.
+func SDNotifyReady() {
+	sdNotify("READY=1")
+}
+
+// StartSDWatchdog pets the systemd watchdog at half the configured
+// interval until ctx is cancelled. Without WATCHDOG_USEC it returns
+// immediately.
+func StartSDWatchdog(ctx context.Context) {
+	usec, err := strconv.Atoi(os.Getenv("WATCHDOG_USEC"))
+	if err != nil || usec <= 0 {
+		return
+	}
+	if pid, err := strconv.Atoi(os.Getenv("WATCHDOG_PID")); err == nil && pid != os.Getpid() {
+		return
+	}
+	interval := time.Duration(usec) * time.Microsecond / 2
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			sdNotify("STOPPING=1")
+			return
+		case <-ticker.C:
+			sdNotify("WATCHDOG=1")
+		}
+	}
+}
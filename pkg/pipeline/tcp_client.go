@@ -0,0 +1,210 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"time"
+)
+
+// IdleTimeout closes the outgoing connection when no frame has been sent
+// for this long, so half-open connections are detected. Zero disables the
+// timeout entirely, which is needed for sub-1 fps reduced streams.
+var IdleTimeout = 10 * time.Second
+
+// IdleHeartbeat, when enabled, re-sends the cached output configuration
+// frame on idle expiry instead of disconnecting, keeping low-rate streams
+// alive through NATs and half-open detection on the peer.
+var IdleHeartbeat bool
+
+// Backoff is the reconnect policy of the TCP client output.
+type Backoff struct {
+	Base        time.Duration // delay before the first retry
+	Max         time.Duration // upper bound for the exponential delay
+	Jitter      float64       // fraction of the delay randomized, 0..1
+	MaxAttempts int           // consecutive failures before giving up; 0 retries forever
+}
+
+// DefaultBackoff matches the historical behaviour as closely as possible:
+// retry quickly at first, back off to half a minute, never give up.
+var DefaultBackoff = Backoff{Base: time.Second, Max: 30 * time.Second, Jitter: 0.2}
+
+// Delay returns the sleep duration before retry number attempt (1-based).
+func (b Backoff) Delay(attempt int) time.Duration {
+	d := b.Base << (attempt - 1)
+	if d > b.Max || d <= 0 {
+		d = b.Max
+	}
+	if b.Jitter > 0 {
+		d += time.Duration(rand.Float64() * b.Jitter * float64(d))
+	}
+	return d
+}
+
+// OnOutputDown, when set, is called once the TCP output is considered down,
+// i.e. after MaxAttempts consecutive connection attempts have failed.
+var OnOutputDown func(addr string, attempts int, lastErr error)
+
+// ReconnectCount counts connection attempts that did not succeed, for
+// statistics reporting.
+var ReconnectCount uint64
+
+// StartTCPClient connects to the configured output destination and writes
+// every frame from frames to it until ctx is cancelled. Lost connections
+// are re-established following the backoff policy; frames arriving while
+// disconnected are dropped. bindAddr optionally pins the local address
+// used for dialing.
+func StartTCPClient(ctx context.Context, out *OutputStream, bindAddr string, bo Backoff) error {
+	return runTCPClient(ctx, out, out.Addr(), bindAddr, bo)
+}
+
+// runTCPClient is StartTCPClient for an explicit destination address, so
+// the failover wrapper can point the same stream at a backup.
+func runTCPClient(ctx context.Context, out *OutputStream, addr, bindAddr string, bo Backoff) error {
+	dialer := net.Dialer{Timeout: 5 * time.Second}
+	if bindAddr != "" {
+		ip := net.ParseIP(bindAddr)
+		if ip == nil {
+			return fmt.Errorf("invalid bind address %q", bindAddr)
+		}
+		dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+
+	var spool *frameSpool
+	if SpoolDir != "" {
+		spool = newFrameSpool(addr)
+	}
+	attempt := 0
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			attempt++
+			ReconnectCount++
+			out.recordSendError(err)
+			if bo.MaxAttempts > 0 && attempt >= bo.MaxAttempts {
+				log.Printf("TCP output %s is down after %d attempts: %v", addr, attempt, err)
+				if OnOutputDown != nil {
+					OnOutputDown(addr, attempt, err)
+				}
+				return err
+			}
+			delay := bo.Delay(attempt)
+			log.Printf("cannot connect to %s (attempt %d): %v, retrying in %s", addr, attempt, err, delay)
+			if err := spoolWhileDown(ctx, spool, out.Frames, delay); err != nil {
+				return err
+			}
+			continue
+		}
+		applyDSCP(conn)
+		log.Printf("connected to TCP output %s", addr)
+		attempt = 0
+		if spool != nil {
+			n, err := spool.Replay(conn)
+			if n > 0 {
+				log.Printf("replayed %d spooled frames to %s", n, addr)
+			}
+			if err != nil {
+				log.Printf("spool replay to %s failed: %v", addr, err)
+				conn.Close()
+				continue
+			}
+		}
+		sess := newSession("output", addr)
+		err = sendLoop(ctx, conn, out.Frames, sess)
+		switch {
+		case err != nil:
+			out.recordSendError(err)
+			sess.close(err.Error())
+		case ctx.Err() != nil:
+			sess.close("shutdown")
+		default:
+			sess.close("idle timeout")
+		}
+		conn.Close()
+	}
+}
+
+// spoolWhileDown waits out the reconnect delay. With spooling enabled it
+// diverts frames arriving meanwhile into the spool instead of letting the
+// publisher drop them.
+func spoolWhileDown(ctx context.Context, spool *frameSpool, frames <-chan []byte, delay time.Duration) error {
+	deadline := time.NewTimer(delay)
+	defer deadline.Stop()
+	for {
+		if spool == nil {
+			select {
+			case <-deadline.C:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		select {
+		case frame := <-frames:
+			spool.Append(frame)
+		case <-deadline.C:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// sendLoop writes frames until the connection fails, ctx is cancelled or,
+// with a non-zero IdleTimeout, the stream stays idle for too long. It
+// returns the write error that ended the connection, or nil for a clean
+// exit. With IdleHeartbeat the cached configuration frame is sent on idle
+// expiry instead of disconnecting.
+func sendLoop(ctx context.Context, conn net.Conn, frames <-chan []byte, sess *tcpSession) error {
+	timeout := IdleTimeout
+	if timeout == 0 {
+		for {
+			select {
+			case frame := <-frames:
+				paceOutput()
+				if _, err := conn.Write(frame); err != nil {
+					log.Printf("write to %s failed: %v", conn.RemoteAddr(), err)
+					return err
+				}
+				sess.count(len(frame))
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+	idle := time.NewTimer(timeout)
+	defer idle.Stop()
+	for {
+		idle.Reset(timeout)
+		select {
+		case frame := <-frames:
+			paceOutput()
+			if _, err := conn.Write(frame); err != nil {
+				log.Printf("write to %s failed: %v", conn.RemoteAddr(), err)
+				return err
+			}
+			sess.count(len(frame))
+		case <-ctx.Done():
+			return nil
+		case <-idle.C:
+			if hb := LastOutputCfg(); IdleHeartbeat && hb != nil {
+				if _, err := conn.Write(hb); err != nil {
+					log.Printf("heartbeat to %s failed: %v", conn.RemoteAddr(), err)
+					return err
+				}
+				sess.count(len(hb))
+				continue
+			}
+			log.Printf("closing idle connection to %s", conn.RemoteAddr())
+			return nil
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package pipeline
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	bo := Backoff{Base: time.Second, Max: 30 * time.Second}
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{5, 16 * time.Second},
+		{6, 30 * time.Second},  // capped
+		{64, 30 * time.Second}, // shift overflow falls back to Max
+	}
+	for _, tt := range tests {
+		if got := bo.Delay(tt.attempt); got != tt.want {
+			t.Errorf("Delay(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestBackoffDelayJitter(t *testing.T) {
+	bo := Backoff{Base: time.Second, Max: 30 * time.Second, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		d := bo.Delay(2)
+		if d < 2*time.Second || d > 3*time.Second {
+			t.Fatalf("Delay(2) with 0.5 jitter = %v, want within [2s, 3s]", d)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// StartTCPInput accepts inbound TCP connections from PMUs on port and
+// feeds the frames they carry through the reduction pipeline until ctx
+// is cancelled. Every connection keeps its own framing buffer and the
+// per-IDCODE stream contexts keep the fleet's configurations apart, so
+// many PMUs can push to one reductor endpoint.
+func StartTCPInput(ctx context.Context, port int, bindAddr string, outs *Outputs) error {
+	ln, err := net.Listen("tcp", fmt.Sprintf("%s:%d", bindAddr, port))
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+	stop := context.AfterFunc(ctx, func() { ln.Close() })
+	defer stop()
+	log.Printf("accepting C37.118 TCP connections on %s", ln.Addr())
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		ip := remoteIP(conn)
+		if !sourceAllowed(ip) {
+			conn.Close()
+			continue
+		}
+		go serveTCPInput(ctx, conn, ip, outs)
+	}
+}
+
+// remoteIP extracts the peer address of a connection for the allowlist
+// and source-pinning checks.
+func remoteIP(conn net.Conn) net.IP {
+	if a, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		return a.IP
+	}
+	return nil
+}
+
+// serveTCPInput reads frames from one PMU connection until it closes.
+// Frames delimit themselves via FRAMESIZE; garbage is skipped one byte
+// at a time until the next SYNC byte.
+func serveTCPInput(ctx context.Context, conn net.Conn, ip net.IP, outs *Outputs) {
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	log.Printf("PMU connected from %s", conn.RemoteAddr())
+	sess := newSession("input", conn.RemoteAddr().String())
+	var buf []byte
+	tmp := make([]byte, 4096)
+	for {
+		n, err := conn.Read(tmp)
+		if n > 0 {
+			buf = append(buf, tmp[:n]...)
+			for len(buf) >= c37.CommonHeaderSize {
+				size := int(binary.BigEndian.Uint16(buf[2:4]))
+				if buf[0] != c37.SyncLead || size < c37.CommonHeaderSize+2 {
+					buf = buf[1:]
+					continue
+				}
+				if len(buf) < size {
+					break
+				}
+				frame := make([]byte, size)
+				copy(frame, buf[:size])
+				buf = buf[size:]
+				sess.count(size)
+				if !sourceWithinRate(ip) {
+					continue
+				}
+				HandleFrame(frame, ip, outs)
+			}
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				sess.close("shutdown")
+			} else {
+				sess.close(err.Error())
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/binary"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// AuthToken, when non-empty, requires every connecting client to present
+// the shared secret before any frames are sent. This is a minimal access
+// control for deployments that cannot terminate TLS in front of the
+// reductor; it does not protect the frames themselves.
+var AuthToken string
+
+// authTimeout bounds how long a client may take to authenticate.
+const authTimeout = 5 * time.Second
+
+// authenticate performs the token handshake on a freshly accepted client:
+// the client sends "AUTH <token>\n" and receives "+OK\n" on success.
+func authenticate(conn net.Conn) bool {
+	if AuthToken == "" {
+		return true
+	}
+	conn.SetReadDeadline(time.Now().Add(authTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+	line, err := bufio.NewReaderSize(conn, 256).ReadString('\n')
+	if err != nil {
+		log.Printf("client %s failed to authenticate: %v", conn.RemoteAddr(), err)
+		return false
+	}
+	token, ok := strings.CutPrefix(strings.TrimRight(line, "\r\n"), "AUTH ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(AuthToken)) != 1 {
+		log.Printf("client %s presented an invalid token", conn.RemoteAddr())
+		conn.Write([]byte("-DENIED\n"))
+		return false
+	}
+	if _, err := conn.Write([]byte("+OK\n")); err != nil {
+		return false
+	}
+	return true
+}
+
+// Commanded makes the TCP server wait for a "data on" command frame from
+// each client before streaming data frames to it, the integration style
+// of PDCs that poll for configuration first. The default is spontaneous
+// streaming on connect.
+var Commanded bool
+
+// tcpClient is the server-side state of one connected downstream client.
+type tcpClient struct {
+	streaming bool
+	// profile and reducer hold the client's own reduction when one is
+	// configured for its IP; both are nil for default clients.
+	profile *ClientProfile
+	reducer *Reducer
+	// sess records the connection for the session summary; its counters
+	// are only touched under tcpClientsMu.
+	sess *tcpSession
+}
+
+// tcpClients tracks the currently connected downstream clients.
+var (
+	tcpClientsMu sync.Mutex
+	tcpClients   = make(map[net.Conn]*tcpClient)
+)
+
+// handleClientCommands reads command frames from a connected client until
+// it disconnects: DATA ON and DATA OFF toggle streaming, SEND CFG-2
+// returns the cached output configuration. Unparseable bytes are skipped
+// one at a time to resynchronize on the next SYNC byte.
+func handleClientCommands(conn net.Conn, cl *tcpClient) {
+	var buf []byte
+	tmp := make([]byte, 256)
+	for {
+		n, err := conn.Read(tmp)
+		if err != nil {
+			return
+		}
+		buf = append(buf, tmp[:n]...)
+		for len(buf) >= c37.CommonHeaderSize {
+			size := int(binary.BigEndian.Uint16(buf[2:4]))
+			if buf[0] != c37.SyncLead || size < c37.CommonHeaderSize+2 {
+				buf = buf[1:]
+				continue
+			}
+			if len(buf) < size {
+				break
+			}
+			cmd, err := c37.DecodeCommandFrame(buf[:size])
+			buf = buf[size:]
+			if err != nil {
+				continue
+			}
+			switch cmd.Command {
+			case c37.CmdDataOn:
+				log.Printf("client %s turned data on", conn.RemoteAddr())
+				tcpClientsMu.Lock()
+				cl.streaming = true
+				tcpClientsMu.Unlock()
+			case c37.CmdDataOff:
+				log.Printf("client %s turned data off", conn.RemoteAddr())
+				tcpClientsMu.Lock()
+				cl.streaming = false
+				tcpClientsMu.Unlock()
+			case c37.CmdSendCfg2:
+				if cfg := LastOutputCfg(); cfg != nil {
+					conn.Write(cfg)
+				}
+			}
+		}
+	}
+}
+
+// StartTCPServer listens on the configured output port and broadcasts every
+// frame from frames to all connected clients until ctx is cancelled.
+func StartTCPServer(ctx context.Context, out *OutputStream) error {
+	ln := activatedTCPListener(out.Port)
+	if ln != nil {
+		log.Printf("using systemd-activated listener for port %d", out.Port)
+	} else {
+		var err error
+		ln, err = net.Listen("tcp", out.Addr())
+		if err != nil {
+			return err
+		}
+	}
+	defer ln.Close()
+	stop := context.AfterFunc(ctx, func() { ln.Close() })
+	defer stop()
+	log.Printf("TCP server output listening on %s", out.Addr())
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				log.Printf("accept failed: %v", err)
+				return
+			}
+			applyDSCP(conn)
+			go func(conn net.Conn) {
+				if !authenticate(conn) {
+					conn.Close()
+					return
+				}
+				log.Printf("client %s connected", conn.RemoteAddr())
+				cl := &tcpClient{streaming: !Commanded, sess: newSession("server", conn.RemoteAddr().String())}
+				if cl.profile = profileFor(conn.RemoteAddr()); cl.profile != nil && cl.profile.Rate > 0 {
+					cl.reducer = NewReducer(out.DataRate, cl.profile.Rate)
+				}
+				tcpClientsMu.Lock()
+				tcpClients[conn] = cl
+				tcpClientsMu.Unlock()
+				// Most PDCs never decode the stream without the
+				// configuration first; send the cached one right away.
+				if cfg := LastOutputCfg(); cfg != nil {
+					if h, err := c37.DecodeHeader(cfg); err == nil && cl.profile != nil &&
+						(h.Type == c37.TypeCfg1 || h.Type == c37.TypeCfg2) {
+						cfg = cl.profile.rewriteConfig(cfg, h)
+					}
+					if _, err := conn.Write(cfg); err != nil {
+						log.Printf("client %s dropped: %v", conn.RemoteAddr(), err)
+						tcpClientsMu.Lock()
+						delete(tcpClients, conn)
+						tcpClientsMu.Unlock()
+						cl.sess.close(err.Error())
+						conn.Close()
+						return
+					}
+					tcpClientsMu.Lock()
+					cl.sess.count(len(cfg))
+					tcpClientsMu.Unlock()
+				}
+				handleClientCommands(conn, cl)
+				// The read side saw the disconnect first; close the
+				// session here unless a failed write already did.
+				tcpClientsMu.Lock()
+				_, present := tcpClients[conn]
+				delete(tcpClients, conn)
+				tcpClientsMu.Unlock()
+				conn.Close()
+				if present {
+					cl.sess.close("disconnected")
+				}
+			}(conn)
+		}
+	}()
+
+	for {
+		var frame []byte
+		select {
+		case frame = <-out.Frames:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		paceOutput()
+		h, herr := c37.DecodeHeader(frame)
+		isData := herr == nil && h.Type == c37.TypeData
+		tcpClientsMu.Lock()
+		for conn, cl := range tcpClients {
+			// Configuration and other non-data frames go out regardless,
+			// so a commanded client can learn the stream layout first.
+			if isData && !cl.streaming {
+				continue
+			}
+			buf := frame
+			if cl.profile != nil && herr == nil {
+				switch {
+				case isData:
+					if cl.reducer != nil && !cl.reducer.Keep() {
+						continue
+					}
+					buf = cl.profile.rewriteData(frame, h)
+				case h.Type == c37.TypeCfg1 || h.Type == c37.TypeCfg2:
+					buf = cl.profile.rewriteConfig(frame, h)
+				}
+			}
+			if _, err := conn.Write(buf); err != nil {
+				log.Printf("client %s dropped: %v", conn.RemoteAddr(), err)
+				cl.sess.close(err.Error())
+				conn.Close()
+				delete(tcpClients, conn)
+			} else {
+				cl.sess.count(len(buf))
+			}
+		}
+		tcpClientsMu.Unlock()
+	}
+}
@@ -0,0 +1,134 @@
+package pipeline
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// MaxTimeQuality is the worst acceptable time quality code, applied both
+// to the FRACSEC message time quality and the STAT PMU time quality.
+// Codes grow with the time error, so frames from GPS-unlocked PMUs exceed
+// the threshold. -1 disables the filter.
+var MaxTimeQuality = -1
+
+// TQFlag selects down-flagging instead of dropping: offending PMU blocks
+// get the PMU-error bit set in STAT and the frame is forwarded, leaving
+// the drop decision to the consumer.
+var TQFlag bool
+
+// checkTimeQuality applies the time quality filter to a decoded data
+// frame. It reports whether the frame must be dropped and whether any
+// STAT word was down-flagged (requiring a re-encode before forwarding).
+func checkTimeQuality(st *StreamContext, h *c37.Header, df *c37.DataFrame) (drop, flagged bool) {
+	if MaxTimeQuality < 0 {
+		return false, false
+	}
+	msgBad := int(h.TimeQualityInfo().Code) > MaxTimeQuality
+	anyBad := msgBad
+	for i := range df.PMUs {
+		bad := msgBad || int(df.PMUs[i].Stat.TimeQuality) > MaxTimeQuality
+		if !bad {
+			continue
+		}
+		anyBad = true
+		if TQFlag {
+			df.PMUs[i].Stat.Raw |= 0x4000 // DataError = PMU error
+			flagged = true
+		}
+	}
+	if !anyBad {
+		return false, false
+	}
+	if !TQFlag {
+		st.Stats.TQDrops++
+		if st.Stats.TQDrops == 1 {
+			log.Printf("dropping frames from IDCODE %d: time quality worse than %d", st.IDCode, MaxTimeQuality)
+		}
+		return true, false
+	}
+	return false, flagged
+}
+
+// tqPolicy selects how the FRACSEC message time-quality byte of emitted
+// frames is produced: forwarded as received, derived worst-of across the
+// sources of an aggregated frame, or stamped with a fixed value.
+var tqPolicy = tqCopy
+
+const (
+	tqCopy = iota
+	tqWorst
+	tqFixed
+)
+
+// tqFixedByte is the byte stamped by the fixed policy.
+var tqFixedByte uint8
+
+// ParseTQPolicy parses the -tq_policy flag: "copy" (the default),
+// "worst", or "fixed=N" with N the byte to stamp, e.g. "fixed=0x02".
+func ParseTQPolicy(spec string) error {
+	switch {
+	case spec == "" || spec == "copy":
+		tqPolicy = tqCopy
+	case spec == "worst":
+		tqPolicy = tqWorst
+	case strings.HasPrefix(spec, "fixed="):
+		v, err := strconv.ParseUint(spec[len("fixed="):], 0, 8)
+		if err != nil {
+			return fmt.Errorf("invalid fixed time quality in %q", spec)
+		}
+		tqPolicy, tqFixedByte = tqFixed, uint8(v)
+	default:
+		return fmt.Errorf("invalid time quality policy %q, want copy, worst or fixed=N", spec)
+	}
+	return nil
+}
+
+// applyTQPolicy rewrites the message time-quality byte of a decoded data
+// frame per the configured policy; true means the frame must be
+// re-encoded. Outside aggregation only the fixed policy changes
+// anything: with a single source "worst" degenerates to "copy".
+func applyTQPolicy(h *c37.Header) bool {
+	if tqPolicy != tqFixed || h.TimeQuality() == tqFixedByte {
+		return false
+	}
+	h.FracSec = h.FracSec&0x00FFFFFF | uint32(tqFixedByte)<<24
+	return true
+}
+
+// stampFixedTQ applies the fixed time-quality byte to a header about to
+// be encoded; the other policies leave it alone.
+func stampFixedTQ(h *c37.Header) {
+	if tqPolicy == tqFixed {
+		h.FracSec = h.FracSec&0x00FFFFFF | uint32(tqFixedByte)<<24
+	}
+}
+
+// aggregateTQ derives the combined time-quality byte of an aggregated
+// frame: the leap-second flags are ORed and the largest-error indicator
+// code wins, with fault and reserved codes worst of all.
+func aggregateTQ(dfs []*c37.DataFrame) uint8 {
+	worst := dfs[0].Header.TimeQualityInfo()
+	for _, df := range dfs[1:] {
+		q := df.Header.TimeQualityInfo()
+		worst.LeapPending = worst.LeapPending || q.LeapPending
+		worst.LeapOccurred = worst.LeapOccurred || q.LeapOccurred
+		worst.LeapDelete = worst.LeapDelete || q.LeapDelete
+		if tqRank(q.Code) > tqRank(worst.Code) {
+			worst.Code = q.Code
+		}
+	}
+	return worst.Encode()
+}
+
+// tqRank orders indicator codes by the time error they admit; codes past
+// 11 (fault and reserved) rank above all bounded ones.
+func tqRank(code uint8) int {
+	if code > 11 {
+		return 12
+	}
+	return int(code)
+}
@@ -0,0 +1,130 @@
+package pipeline
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// Transform is one stage of a per-stream processing chain for decoded
+// data frames. Apply reports whether the frame is kept and whether it was
+// mutated, in which case the frame is re-encoded before forwarding.
+type Transform interface {
+	Name() string
+	Apply(st *StreamContext, df *c37.DataFrame) (keep, mutated bool)
+}
+
+// transformFunc adapts a plain function to the Transform interface.
+type transformFunc struct {
+	name string
+	fn   func(st *StreamContext, df *c37.DataFrame) (keep, mutated bool)
+}
+
+func (t transformFunc) Name() string { return t.name }
+
+func (t transformFunc) Apply(st *StreamContext, df *c37.DataFrame) (keep, mutated bool) {
+	return t.fn(st, df)
+}
+
+// newTransform returns the transform with the given name: a built-in
+// one, a registered custom transform, or an "exec:/path" external
+// transformer process.
+func newTransform(name string) (Transform, error) {
+	if t, ok := pluginTransform(name); ok {
+		return t, nil
+	}
+	switch name {
+	case "downsample":
+		// Rate reduction via the stream's reducer, the historical fixed
+		// stage.
+		return transformFunc{name, func(st *StreamContext, df *c37.DataFrame) (bool, bool) {
+			return st.Reducer != nil && st.Reducer.Keep(), false
+		}}, nil
+	case "deadband":
+		return transformFunc{name, func(st *StreamContext, df *c37.DataFrame) (bool, bool) {
+			return checkDeadband(st, df), false
+		}}, nil
+	case "restamp":
+		// Replaces the frame timestamp with the host clock, keeping the
+		// time-quality byte; useful when a PDC downstream insists on
+		// current timestamps from replayed or delayed feeds.
+		return transformFunc{name, func(st *StreamContext, df *c37.DataFrame) (bool, bool) {
+			timeBase := uint32(1000000)
+			if st.Cfg != nil && st.Cfg.TimeBase > 0 {
+				timeBase = st.Cfg.TimeBase
+			}
+			now := time.Now().UTC()
+			df.Header.SOC = uint32(now.Unix())
+			frac := uint32(int64(now.Nanosecond()) * int64(timeBase) / int64(time.Second))
+			df.Header.FracSec = df.Header.FracSec&0xFF000000 | frac&0x00FFFFFF
+			return true, true
+		}}, nil
+	}
+	return nil, fmt.Errorf("unknown transform %q", name)
+}
+
+// defaultChain reproduces the fixed reduce-then-deadband flow used when a
+// stream has no configured chain.
+var defaultChain = []Transform{
+	transformFunc{"downsample", func(st *StreamContext, df *c37.DataFrame) (bool, bool) {
+		return st.Reducer != nil && st.Reducer.Keep(), false
+	}},
+	transformFunc{"deadband", func(st *StreamContext, df *c37.DataFrame) (bool, bool) {
+		return checkDeadband(st, df), false
+	}},
+}
+
+var transformChains = make(map[uint16][]Transform)
+
+// ParseTransforms parses the -transforms flag: comma-separated
+// "IDCODE=name+name+..." entries applied in the given order, e.g.
+// "41=deadband+downsample,42=downsample+restamp". IDCODE 0 replaces the
+// default chain. Available transforms: downsample, deadband, restamp.
+func ParseTransforms(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		id, names, ok := strings.Cut(entry, "=")
+		if !ok {
+			return fmt.Errorf("invalid transform entry %q, want IDCODE=name+name", entry)
+		}
+		idCode, err := strconv.ParseUint(id, 10, 16)
+		if err != nil {
+			return fmt.Errorf("invalid IDCODE in transform entry %q: %v", entry, err)
+		}
+		var chain []Transform
+		for _, name := range strings.Split(names, "+") {
+			t, err := newTransform(name)
+			if err != nil {
+				return fmt.Errorf("transform entry %q: %v", entry, err)
+			}
+			chain = append(chain, t)
+		}
+		if idCode == 0 {
+			defaultChain = chain
+		} else {
+			transformChains[uint16(idCode)] = chain
+		}
+	}
+	return nil
+}
+
+// runTransforms passes a decoded data frame through its stream's chain.
+func runTransforms(st *StreamContext, df *c37.DataFrame) (keep, mutated bool) {
+	chain, ok := transformChains[st.IDCode]
+	if !ok {
+		chain = defaultChain
+	}
+	for _, t := range chain {
+		k, m := t.Apply(st, df)
+		if !k {
+			return false, mutated
+		}
+		mutated = mutated || m
+	}
+	return true, mutated
+}
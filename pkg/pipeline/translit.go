@@ -0,0 +1,125 @@
+package pipeline
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// CFG-2 carries names in 16-byte ASCII fields, while CFG-3 names are
+// free-length UTF-8. When a CFG-2 is regenerated from a CFG-3 the names
+// are transliterated with the rules below, truncated to 16 bytes, and
+// de-collided with a numeric suffix so the result stays unambiguous.
+
+// translitRules maps non-ASCII runes to ASCII replacements. The built-in
+// table covers the Latin diacritics common in European station names;
+// -translit entries extend or override it. Unmapped non-ASCII runes
+// become '_'.
+var translitRules = map[rune]string{
+	'ä': "a", 'á': "a", 'à': "a", 'â': "a", 'ã': "a", 'å': "a",
+	'ë': "e", 'é': "e", 'è': "e", 'ê': "e",
+	'ï': "i", 'í': "i", 'ì': "i", 'î': "i",
+	'ö': "o", 'ó': "o", 'ò': "o", 'ô': "o", 'õ': "o", 'ø': "o",
+	'ü': "u", 'ú': "u", 'ù': "u", 'û': "u",
+	'ý': "y", 'ñ': "n", 'ç': "c", 'ß': "ss", 'æ': "ae", 'œ': "oe",
+	'Ä': "A", 'Á': "A", 'À': "A", 'Â': "A", 'Ã': "A", 'Å': "A",
+	'Ë': "E", 'É': "E", 'È': "E", 'Ê': "E",
+	'Ï': "I", 'Í': "I", 'Ì': "I", 'Î': "I",
+	'Ö': "O", 'Ó': "O", 'Ò': "O", 'Ô': "O", 'Õ': "O", 'Ø': "O",
+	'Ü': "U", 'Ú': "U", 'Ù': "U", 'Û': "U",
+	'Ñ': "N", 'Ç': "C", 'Æ': "AE", 'Œ': "OE",
+	'ą': "a", 'ć': "c", 'ę': "e", 'ł': "l", 'ń': "n", 'ś': "s", 'ż': "z", 'ź': "z",
+	'Ą': "A", 'Ć': "C", 'Ę': "E", 'Ł': "L", 'Ń': "N", 'Ś': "S", 'Ż': "Z", 'Ź': "Z",
+}
+
+// ParseTranslitRules parses the -translit flag: comma-separated
+// "rune=replacement" entries extending the built-in table, e.g.
+// "µ=u,Ω=Ohm".
+func ParseTranslitRules(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		from, to, ok := strings.Cut(entry, "=")
+		runes := []rune(from)
+		if !ok || len(runes) != 1 {
+			return fmt.Errorf("invalid transliteration entry %q, want rune=replacement", entry)
+		}
+		for _, r := range to {
+			if r > 0x7E {
+				return fmt.Errorf("replacement in %q is not ASCII", entry)
+			}
+		}
+		translitRules[runes[0]] = to
+	}
+	return nil
+}
+
+// asciiName folds a UTF-8 name into a printable 16-byte ASCII name.
+func asciiName(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= 0x20 && r <= 0x7E:
+			b.WriteRune(r)
+		default:
+			if rep, ok := translitRules[r]; ok {
+				b.WriteString(rep)
+			} else {
+				b.WriteByte('_')
+			}
+		}
+		if b.Len() >= 16 {
+			break
+		}
+	}
+	out := b.String()
+	if len(out) > 16 {
+		out = out[:16]
+	}
+	return out
+}
+
+// transliterateNames rewrites the names of a CFG-2 regenerated from a
+// CFG-3 into unambiguous 16-byte ASCII: station names are de-collided
+// across PMU blocks, channel names within each block.
+func transliterateNames(cfg *c37.CfgFrame2) {
+	stations := make(map[string]bool)
+	for i := range cfg.PMUs {
+		pc := &cfg.PMUs[i]
+		folded := uniqueName(asciiName(pc.StationName), stations)
+		if folded != pc.StationName {
+			log.Printf("station name %q becomes %q in regenerated CFG-2", pc.StationName, folded)
+			pc.StationName = folded
+		}
+		seen := make(map[string]bool)
+		for _, names := range [][]string{pc.PhasorNames, pc.AnalogNames, pc.DigitalNames} {
+			for j, name := range names {
+				names[j] = uniqueName(asciiName(name), seen)
+			}
+		}
+	}
+}
+
+// uniqueName registers a name in seen, appending "~N" (shortening the
+// base as needed) when it collides with an earlier one.
+func uniqueName(name string, seen map[string]bool) string {
+	if !seen[name] {
+		seen[name] = true
+		return name
+	}
+	for i := 1; ; i++ {
+		suffix := fmt.Sprintf("~%d", i)
+		cand := name
+		if len(cand)+len(suffix) > 16 {
+			cand = cand[:16-len(suffix)]
+		}
+		cand += suffix
+		if !seen[cand] {
+			seen[cand] = true
+			return cand
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package pipeline
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// TriggerDir is the directory triggered event captures are written to;
+// empty disables triggered capture.
+var TriggerDir string
+
+// TriggerPre and TriggerPost size the capture window in frames around
+// the trigger.
+var (
+	TriggerPre  = 200
+	TriggerPost = 200
+)
+
+// trigCap is the rolling pre-trigger buffer and, while an event is open,
+// the file the post-trigger frames stream into.
+var trigCap struct {
+	sync.Mutex
+	ring [][]byte
+	post int
+	f    *os.File
+}
+
+// captureFrame feeds one received frame through the rolling pre-trigger
+// buffer and, while an event is open, appends it to the event file.
+func captureFrame(data []byte) {
+	if TriggerDir == "" {
+		return
+	}
+	trigCap.Lock()
+	defer trigCap.Unlock()
+	trigCap.ring = append(trigCap.ring, data)
+	if len(trigCap.ring) > TriggerPre {
+		trigCap.ring = trigCap.ring[1:]
+	}
+	if trigCap.f == nil {
+		return
+	}
+	fmt.Fprintln(trigCap.f, hex.EncodeToString(data))
+	trigCap.post--
+	if trigCap.post <= 0 {
+		log.Printf("event capture complete: %s", trigCap.f.Name())
+		trigCap.f.Close()
+		trigCap.f = nil
+	}
+}
+
+// TriggerEvent starts a triggered capture: the pre-trigger window is
+// dumped to a fresh timestamped file (same hex-line format as capture
+// files, so it replays with -file) and the next TriggerPost frames are
+// appended as they arrive. A trigger during an open event extends its
+// post window instead of starting a second file.
+func TriggerEvent(reason string) {
+	if TriggerDir == "" {
+		return
+	}
+	trigCap.Lock()
+	defer trigCap.Unlock()
+	if trigCap.f != nil {
+		trigCap.post = TriggerPost
+		return
+	}
+	name := filepath.Join(TriggerDir, time.Now().UTC().Format("event_20060102T150405.000Z.txt"))
+	f, err := os.Create(name)
+	if err != nil {
+		log.Printf("cannot open event capture: %v", err)
+		return
+	}
+	for _, frame := range trigCap.ring {
+		fmt.Fprintln(f, hex.EncodeToString(frame))
+	}
+	trigCap.f = f
+	trigCap.post = TriggerPost
+	log.Printf("capture triggered (%s): %s", reason, name)
+}
@@ -0,0 +1,104 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"time"
+)
+
+// CfgInterval re-sends the reduced configuration frame this often on UDP
+// outputs, so consumers that start late still receive it. 0 leaves the
+// configuration event-driven only.
+var CfgInterval time.Duration
+
+// UDPKeepalive sends a small datagram whenever the UDP output has been
+// idle this long, so NAT and firewall pinholes stay open during low-rate
+// output. The configuration frame doubles as the keepalive payload since
+// downstream consumers already expect it; before one exists a single
+// zero byte goes out instead. 0 disables keepalives.
+var UDPKeepalive time.Duration
+
+// StartUDPSender sends every frame from frames as one datagram to the
+// configured output destination until ctx is cancelled. bindAddr
+// optionally pins the local source address of the outgoing datagrams.
+func StartUDPSender(ctx context.Context, out *OutputStream, bindAddr string) error {
+	raddr, err := net.ResolveUDPAddr("udp", out.Addr())
+	if err != nil {
+		return err
+	}
+	var laddr *net.UDPAddr
+	if bindAddr != "" {
+		ip := net.ParseIP(bindAddr)
+		if ip == nil {
+			return fmt.Errorf("invalid bind address %q", bindAddr)
+		}
+		laddr = &net.UDPAddr{IP: ip}
+	}
+	conn, err := net.DialUDP("udp", laddr, raddr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	applyDSCP(conn)
+	log.Printf("sending reduced stream to UDP %s", out.Addr())
+	var resend <-chan time.Time
+	if CfgInterval > 0 {
+		t := time.NewTicker(CfgInterval)
+		defer t.Stop()
+		resend = t.C
+	}
+	var keepalive *time.Timer
+	var idle <-chan time.Time
+	if UDPKeepalive > 0 {
+		keepalive = time.NewTimer(UDPKeepalive)
+		defer keepalive.Stop()
+		idle = keepalive.C
+	}
+	// touch restarts the idle timer after every datagram sent.
+	touch := func() {
+		if keepalive == nil {
+			return
+		}
+		if !keepalive.Stop() {
+			select {
+			case <-keepalive.C:
+			default:
+			}
+		}
+		keepalive.Reset(UDPKeepalive)
+	}
+	for {
+		select {
+		case frame := <-out.Frames:
+			paceOutput()
+			if _, err := conn.Write(frame); err != nil {
+				out.recordSendError(err)
+				log.Printf("UDP send to %s failed: %v", out.Addr(), err)
+			}
+			touch()
+		case <-resend:
+			if cfg := LastOutputCfg(); cfg != nil {
+				paceOutput()
+				if _, err := conn.Write(cfg); err != nil {
+					out.recordSendError(err)
+					log.Printf("UDP send to %s failed: %v", out.Addr(), err)
+				}
+				touch()
+			}
+		case <-idle:
+			payload := LastOutputCfg()
+			if payload == nil {
+				payload = []byte{0}
+			}
+			if _, err := conn.Write(payload); err != nil {
+				out.recordSendError(err)
+				log.Printf("UDP keepalive to %s failed: %v", out.Addr(), err)
+			}
+			keepalive.Reset(UDPKeepalive)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
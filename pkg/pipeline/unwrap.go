@@ -0,0 +1,98 @@
+package pipeline
+
+import (
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// UnwrapAngles adds continuous (unwrapped) and relative-to-reference
+// angles to the analytic outputs. Raw C37.118 angles wrap at +/-pi, which
+// makes them useless for trending without post-processing.
+var UnwrapAngles bool
+
+// AngleReference is the channel relative angles are measured against, or
+// nil when none is configured (relative angles then equal the continuous
+// ones).
+var AngleReference *ChannelRef
+
+// ParseAngleReference parses the -angle_ref flag, an "IDCODE:pmu:phasor"
+// channel reference.
+func ParseAngleReference(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	ref, err := parseChannelRef(spec)
+	if err != nil {
+		return err
+	}
+	AngleReference = &ref
+	return nil
+}
+
+// unwrapState is the unwrap history of one phasor channel.
+type unwrapState struct {
+	last float64 // last wrapped sample, radians
+	cont float64 // continuous angle, radians
+}
+
+// AngleUnwrapper turns the wrapped phase angles of a frame sequence into
+// continuous series, one per channel. Each sink owns its own unwrapper,
+// so sinks attached at different times stay internally consistent.
+type AngleUnwrapper struct {
+	state    map[ChannelRef]*unwrapState
+	refAngle float64
+	refSeen  bool
+}
+
+// NewAngleUnwrapper returns an unwrapper with empty history.
+func NewAngleUnwrapper() *AngleUnwrapper {
+	return &AngleUnwrapper{state: make(map[ChannelRef]*unwrapState)}
+}
+
+// unwrap folds the latest wrapped sample of ref into its continuous angle.
+func (u *AngleUnwrapper) unwrap(ref ChannelRef, angle float64) float64 {
+	s, ok := u.state[ref]
+	if !ok {
+		u.state[ref] = &unwrapState{last: angle, cont: angle}
+		return angle
+	}
+	s.cont += wrapAngle(angle - s.last)
+	s.last = angle
+	return s.cont
+}
+
+// UnwrapFrame returns the continuous and relative-to-reference angles of
+// every phasor in the frame, shaped like df.PMUs. The reference channel is
+// advanced first when the frame carries it, so same-frame channels are
+// measured against the same instant; until the reference has been seen,
+// relative angles equal the continuous ones.
+func (u *AngleUnwrapper) UnwrapFrame(idCode uint16, df *c37.DataFrame) (cont, rel [][]float64) {
+	if AngleReference != nil && AngleReference.IDCode == idCode &&
+		AngleReference.PMU < len(df.PMUs) &&
+		AngleReference.Phasor < len(df.PMUs[AngleReference.PMU].Phasors) {
+		u.refAngle = u.unwrap(*AngleReference,
+			df.PMUs[AngleReference.PMU].Phasors[AngleReference.Phasor].Angle)
+		u.refSeen = true
+	}
+	cont = make([][]float64, len(df.PMUs))
+	rel = make([][]float64, len(df.PMUs))
+	for i := range df.PMUs {
+		cont[i] = make([]float64, len(df.PMUs[i].Phasors))
+		rel[i] = make([]float64, len(df.PMUs[i].Phasors))
+		for j, ph := range df.PMUs[i].Phasors {
+			ref := ChannelRef{IDCode: idCode, PMU: i, Phasor: j}
+			c := ph.Angle
+			if AngleReference == nil || ref != *AngleReference {
+				c = u.unwrap(ref, ph.Angle)
+			} else {
+				c = u.refAngle
+			}
+			cont[i][j] = c
+			if u.refSeen {
+				rel[i][j] = c - u.refAngle
+			} else {
+				rel[i][j] = c
+			}
+		}
+	}
+	return cont, rel
+}
@@ -0,0 +1,103 @@
+package pipeline
+
+import (
+	"log"
+	"net"
+	"sync"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// Strict rejects frames with any conformance violation instead of letting
+// the decoders cope with them.
+var Strict bool
+
+// Lenient decodes as much as possible from slightly malformed vendor
+// frames, collecting warnings per frame instead of dropping them. Strict
+// and Lenient are mutually exclusive.
+var Lenient bool
+
+// violationCounts tallies every violation class seen on the input.
+var (
+	violationMu     sync.Mutex
+	violationCounts = make(map[c37.Violation]uint64)
+)
+
+// ViolationCounts returns a snapshot of the per-class violation counters.
+func ViolationCounts() map[c37.Violation]uint64 {
+	violationMu.Lock()
+	defer violationMu.Unlock()
+	out := make(map[c37.Violation]uint64, len(violationCounts))
+	for k, n := range violationCounts {
+		out[k] = n
+	}
+	return out
+}
+
+func countViolations(vs []c37.Violation) {
+	violationMu.Lock()
+	for _, v := range vs {
+		violationCounts[v]++
+	}
+	violationMu.Unlock()
+}
+
+// malformedBySource counts frames per source address that failed to
+// decode, so a misbehaving device is identifiable at a glance.
+var (
+	malformedMu       sync.Mutex
+	malformedBySource = make(map[string]uint64)
+)
+
+// MalformedBySource returns a snapshot of the per-source malformed-frame
+// counters.
+func MalformedBySource() map[string]uint64 {
+	malformedMu.Lock()
+	defer malformedMu.Unlock()
+	out := make(map[string]uint64, len(malformedBySource))
+	for k, n := range malformedBySource {
+		out[k] = n
+	}
+	return out
+}
+
+func countMalformed(src net.IP) {
+	key := "file"
+	if src != nil {
+		key = src.String()
+	}
+	malformedMu.Lock()
+	malformedBySource[key]++
+	malformedMu.Unlock()
+}
+
+// checkFrame validates a received frame and reports whether it may enter
+// the pipeline. In strict mode any violation drops the frame; otherwise
+// violations are only counted.
+func checkFrame(data []byte, st *StreamContext) bool {
+	vs := c37.ValidateFrame(data)
+	if len(vs) == 0 {
+		return true
+	}
+	countViolations(vs)
+	if Strict {
+		st.Stats.DecodeErrors++
+		log.Printf("strict mode: rejecting frame for IDCODE %d: %v", st.IDCode, vs)
+		return false
+	}
+	return true
+}
+
+// checkConfig validates a decoded configuration in the same way.
+func checkConfig(vs []c37.Violation, st *StreamContext) bool {
+	if len(vs) == 0 {
+		return true
+	}
+	countViolations(vs)
+	if Strict {
+		st.Stats.DecodeErrors++
+		log.Printf("strict mode: rejecting configuration for IDCODE %d: %v", st.IDCode, vs)
+		return false
+	}
+	return true
+}
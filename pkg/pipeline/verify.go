@@ -0,0 +1,217 @@
+package pipeline
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// RunVerify replays every reference capture (*.hex, one hex frame per
+// line) in dir through decode and re-encode and compares the decoded
+// fields against the expected JSON stored next to it (same name, .json).
+// Captures without an expected file have one recorded, seeding the
+// corpus. The returned error summarizes any mismatches.
+func RunVerify(dir string) error {
+	captures, err := filepath.Glob(filepath.Join(dir, "*.hex"))
+	if err != nil {
+		return err
+	}
+	if len(captures) == 0 {
+		return fmt.Errorf("no *.hex captures in %s", dir)
+	}
+	sort.Strings(captures)
+	failed := 0
+	for _, path := range captures {
+		mismatches, err := verifyCapture(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if len(mismatches) == 0 {
+			fmt.Printf("ok   %s\n", path)
+			continue
+		}
+		failed++
+		fmt.Printf("FAIL %s\n", path)
+		for _, m := range mismatches {
+			fmt.Printf("     %s\n", m)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d captures mismatched", failed, len(captures))
+	}
+	return nil
+}
+
+// verifyCapture decodes one capture and diffs it against its expected
+// JSON.
+func verifyCapture(path string) ([]string, error) {
+	got, mismatches, err := decodeCapture(path)
+	if err != nil {
+		return nil, err
+	}
+	expPath := strings.TrimSuffix(path, ".hex") + ".json"
+	expData, err := os.ReadFile(expPath)
+	if os.IsNotExist(err) {
+		data, err := json.MarshalIndent(got, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(expPath, append(data, '\n'), 0o644); err != nil {
+			return nil, err
+		}
+		fmt.Printf("new  %s: recorded %s\n", path, expPath)
+		return mismatches, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var want any
+	if err := json.Unmarshal(expData, &want); err != nil {
+		return nil, fmt.Errorf("%s: %w", expPath, err)
+	}
+	// Normalize the decoded frames through JSON so both sides use the
+	// same generic types.
+	raw, err := json.Marshal(got)
+	if err != nil {
+		return nil, err
+	}
+	var have any
+	if err := json.Unmarshal(raw, &have); err != nil {
+		return nil, err
+	}
+	diffJSON("frames", want, have, &mismatches)
+	return mismatches, nil
+}
+
+// decodeCapture decodes all frames of one capture, collecting re-encode
+// mismatches along the way.
+func decodeCapture(path string) ([]map[string]any, []string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	var frames []map[string]any
+	var mismatches []string
+	cfgs := make(map[uint16]*c37.CfgFrame2)
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 1024*1024), 1024*1024)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		data, err := hex.DecodeString(text)
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: not a hex frame: %v", line, err)
+		}
+		h, err := c37.DecodeHeader(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		frame := map[string]any{
+			"type":    h.Type.String(),
+			"version": h.Version,
+			"idcode":  h.IDCode,
+			"soc":     h.SOC,
+			"fracsec": h.FracSec,
+		}
+		switch h.Type {
+		case c37.TypeCfg1, c37.TypeCfg2:
+			cfg, err := c37.DecodeConfigFrame2(data)
+			if err != nil {
+				return nil, nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			cfgs[h.IDCode] = cfg
+			frame["time_base"] = cfg.TimeBase
+			frame["data_rate"] = cfg.DataRate
+			frame["pmus"] = cfg.PMUs
+			if enc := c37.EncodeConfigFrame2(cfg); string(enc) != string(data) {
+				mismatches = append(mismatches,
+					fmt.Sprintf("line %d: config frame does not survive decode-encode (%d vs %d bytes)", line, len(enc), len(data)))
+			}
+		case c37.TypeData:
+			cfg := cfgs[h.IDCode]
+			if cfg == nil {
+				return nil, nil, fmt.Errorf("line %d: data frame before configuration for IDCODE %d", line, h.IDCode)
+			}
+			df, err := c37.DecodeDataFrame(data, cfg)
+			if err != nil {
+				return nil, nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			frame["pmus"] = df.PMUs
+		}
+		frames = append(frames, frame)
+	}
+	return frames, mismatches, sc.Err()
+}
+
+// diffJSON reports field-level differences between two decoded JSON
+// values.
+func diffJSON(path string, want, have any, out *[]string) {
+	switch w := want.(type) {
+	case map[string]any:
+		h, ok := have.(map[string]any)
+		if !ok {
+			*out = append(*out, fmt.Sprintf("%s: expected object, decoded %T", path, have))
+			return
+		}
+		keys := make([]string, 0, len(w))
+		for k := range w {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			hv, ok := h[k]
+			if !ok {
+				*out = append(*out, fmt.Sprintf("%s.%s: missing in decoded output", path, k))
+				continue
+			}
+			diffJSON(path+"."+k, w[k], hv, out)
+		}
+	case []any:
+		h, ok := have.([]any)
+		if !ok || len(h) != len(w) {
+			*out = append(*out, fmt.Sprintf("%s: expected %d elements, decoded %d", path, len(w), jsonLen(have)))
+			return
+		}
+		for i := range w {
+			diffJSON(fmt.Sprintf("%s[%d]", path, i), w[i], h[i], out)
+		}
+	case float64:
+		h, ok := have.(float64)
+		if !ok || !floatClose(w, h) {
+			*out = append(*out, fmt.Sprintf("%s: expected %v, decoded %v", path, want, have))
+		}
+	default:
+		if fmt.Sprint(want) != fmt.Sprint(have) {
+			*out = append(*out, fmt.Sprintf("%s: expected %v, decoded %v", path, want, have))
+		}
+	}
+}
+
+func jsonLen(v any) int {
+	if a, ok := v.([]any); ok {
+		return len(a)
+	}
+	return -1
+}
+
+// floatClose tolerates the float32 round trip of the encoders.
+func floatClose(a, b float64) bool {
+	if a == b {
+		return true
+	}
+	diff := math.Abs(a - b)
+	return diff <= 1e-6*math.Max(math.Abs(a), math.Abs(b))
+}
@@ -0,0 +1,279 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/rapido-liebre/frame_reductor/pkg/c37"
+)
+
+// The zmq output is a minimal hand-rolled ZMTP 3.0 PUB socket (NULL
+// security), enough for research consumers that subscribe via ZeroMQ.
+// Messages are two parts: the IDCODE as topic, then the raw frame or,
+// with -zmq_json, the decoded frame as JSON.
+
+// ZMQJSON switches the zmq output payload from raw frames to decoded
+// JSON.
+var ZMQJSON bool
+
+// zmqSubscriber is one connected SUB peer and its subscriptions.
+type zmqSubscriber struct {
+	conn net.Conn
+	mu   sync.Mutex
+	subs [][]byte
+}
+
+// matches reports whether the peer subscribed to a prefix of topic.
+func (s *zmqSubscriber) matches(topic []byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sub := range s.subs {
+		if len(sub) <= len(topic) && string(sub) == string(topic[:len(sub)]) {
+			return true
+		}
+	}
+	return false
+}
+
+// StartZMQPublisher serves emitted frames as a ZMTP PUB socket on the
+// output's host:port until ctx is cancelled.
+func StartZMQPublisher(ctx context.Context, out *OutputStream) error {
+	addr := fmt.Sprintf("%s:%d", out.Host, out.Port)
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+	stop := context.AfterFunc(ctx, func() { ln.Close() })
+	defer stop()
+	log.Printf("publishing frames on ZMQ PUB %s", addr)
+
+	var mu sync.Mutex
+	var subscribers []*zmqSubscriber
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				sub, err := zmqHandshake(conn)
+				if err != nil {
+					log.Printf("zmq handshake with %s: %v", conn.RemoteAddr(), err)
+					conn.Close()
+					return
+				}
+				mu.Lock()
+				subscribers = append(subscribers, sub)
+				mu.Unlock()
+				zmqReadSubscriptions(sub)
+				mu.Lock()
+				for i, s := range subscribers {
+					if s == sub {
+						subscribers = append(subscribers[:i], subscribers[i+1:]...)
+						break
+					}
+				}
+				mu.Unlock()
+				conn.Close()
+			}()
+		}
+	}()
+
+	for {
+		var frame []byte
+		select {
+		case frame = <-out.Frames:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		h, err := c37.DecodeHeader(frame)
+		if err != nil {
+			continue
+		}
+		payload := frame
+		if ZMQJSON {
+			if payload = zmqJSONPayload(h, frame); payload == nil {
+				continue
+			}
+		}
+		topic := []byte(strconv.Itoa(int(h.IDCode)))
+		mu.Lock()
+		for _, sub := range subscribers {
+			if sub.matches(topic) {
+				if err := zmqSendMessage(sub.conn, topic, payload); err != nil {
+					sub.conn.Close()
+				}
+			}
+		}
+		mu.Unlock()
+	}
+}
+
+// zmqJSONPayload decodes a frame for the JSON payload mode; frames
+// without a decode context yet are skipped.
+func zmqJSONPayload(h *c37.Header, frame []byte) []byte {
+	switch h.Type {
+	case c37.TypeData:
+		cfg := outputDecodeCfg(h.IDCode)
+		if cfg == nil {
+			return nil
+		}
+		df, err := c37.DecodeDataFrame(frame, cfg)
+		if err != nil {
+			return nil
+		}
+		out, err := json.Marshal(df)
+		if err != nil {
+			return nil
+		}
+		return out
+	case c37.TypeCfg1, c37.TypeCfg2:
+		cfg, err := c37.DecodeConfigFrame2(frame)
+		if err != nil {
+			return nil
+		}
+		cfg.Raw = nil
+		out, err := json.Marshal(cfg)
+		if err != nil {
+			return nil
+		}
+		return out
+	}
+	return nil
+}
+
+// zmqGreeting is the 64-byte ZMTP 3.0 greeting for a NULL-security peer.
+func zmqGreeting() []byte {
+	g := make([]byte, 64)
+	g[0] = 0xFF
+	g[9] = 0x7F
+	g[10] = 3 // version major
+	copy(g[12:], "NULL")
+	return g
+}
+
+// zmqHandshake exchanges the greeting and READY commands with a
+// connecting peer.
+func zmqHandshake(conn net.Conn) (*zmqSubscriber, error) {
+	if _, err := conn.Write(zmqGreeting()); err != nil {
+		return nil, err
+	}
+	peer := make([]byte, 64)
+	if _, err := io.ReadFull(conn, peer); err != nil {
+		return nil, err
+	}
+	if peer[0] != 0xFF || peer[9] != 0x7F || peer[10] < 3 {
+		return nil, fmt.Errorf("not a ZMTP 3.x peer")
+	}
+	// READY with Socket-Type PUB.
+	var meta []byte
+	meta = append(meta, 5)
+	meta = append(meta, "READY"...)
+	meta = append(meta, 11)
+	meta = append(meta, "Socket-Type"...)
+	meta = binary.BigEndian.AppendUint32(meta, 3)
+	meta = append(meta, "PUB"...)
+	if err := zmqWriteFrame(conn, 0x04, meta); err != nil {
+		return nil, err
+	}
+	// The peer's READY; its metadata is irrelevant to a publisher.
+	flags, _, err := zmqReadFrame(conn)
+	if err != nil {
+		return nil, err
+	}
+	if flags&0x04 == 0 {
+		return nil, fmt.Errorf("expected READY command")
+	}
+	return &zmqSubscriber{conn: conn}, nil
+}
+
+// zmqReadSubscriptions consumes subscribe/cancel messages from a SUB
+// peer until it disconnects.
+func zmqReadSubscriptions(sub *zmqSubscriber) {
+	for {
+		flags, body, err := zmqReadFrame(sub.conn)
+		if err != nil {
+			return
+		}
+		if flags&0x04 != 0 || len(body) == 0 {
+			continue
+		}
+		topic := append([]byte(nil), body[1:]...)
+		sub.mu.Lock()
+		if body[0] == 1 {
+			sub.subs = append(sub.subs, topic)
+		} else {
+			for i, s := range sub.subs {
+				if string(s) == string(topic) {
+					sub.subs = append(sub.subs[:i], sub.subs[i+1:]...)
+					break
+				}
+			}
+		}
+		sub.mu.Unlock()
+	}
+}
+
+// zmqSendMessage writes a two-part message: topic (more flag set), then
+// payload.
+func zmqSendMessage(conn net.Conn, topic, payload []byte) error {
+	if err := zmqWriteFrame(conn, 0x01, topic); err != nil {
+		return err
+	}
+	return zmqWriteFrame(conn, 0x00, payload)
+}
+
+// zmqWriteFrame writes one ZMTP frame with the given flags.
+func zmqWriteFrame(conn net.Conn, flags byte, body []byte) error {
+	var hdr []byte
+	if len(body) > 255 {
+		hdr = append(hdr, flags|0x02)
+		hdr = binary.BigEndian.AppendUint64(hdr, uint64(len(body)))
+	} else {
+		hdr = append(hdr, flags, byte(len(body)))
+	}
+	if _, err := conn.Write(hdr); err != nil {
+		return err
+	}
+	_, err := conn.Write(body)
+	return err
+}
+
+// zmqReadFrame reads one ZMTP frame.
+func zmqReadFrame(conn net.Conn) (byte, []byte, error) {
+	var hdr [1]byte
+	if _, err := io.ReadFull(conn, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	flags := hdr[0]
+	var size uint64
+	if flags&0x02 != 0 {
+		var sz [8]byte
+		if _, err := io.ReadFull(conn, sz[:]); err != nil {
+			return 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(sz[:])
+	} else {
+		var sz [1]byte
+		if _, err := io.ReadFull(conn, sz[:]); err != nil {
+			return 0, nil, err
+		}
+		size = uint64(sz[0])
+	}
+	if size > 1<<20 {
+		return 0, nil, fmt.Errorf("zmq frame of %d bytes exceeds the limit", size)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(conn, body); err != nil {
+		return 0, nil, err
+	}
+	return flags, body, nil
+}